@@ -2,10 +2,25 @@
 #include <DMXSerial.h>
 
 #define SLAVE_ADDRESS 0x04
-#define DMX_COUNT 12
-char dmxs[DMX_COUNT+1];
 
-// chan 0 : new dmx update
+// I2C protocol version 1
+// request from the Pi : magic, version, channel count
+// frame to the Pi     : magic, version, flags, sequence, channel count, channels..., CRC-16 high, CRC-16 low
+// the CRC-16/CCITT-FALSE covers all of the frame bytes before it
+#define PROTOCOL_MAGIC 0xA5
+#define PROTOCOL_VERSION 1
+#define HEADER_LEN 5
+#define CRC_LEN 2
+// the whole frame has to fit in the 32 byte buffer of the Wire library
+#define DMX_MAX (BUFFER_LENGTH - HEADER_LEN - CRC_LEN)
+#define DMX_DEFAULT 12
+// flags
+#define FLAG_SIGNAL 0x01
+// milliseconds without a DMX packet after which the signal flag is cleared
+#define SIGNAL_TIMEOUT 1000
+
+// The channels from 1 are sent as they are, their meaning is set by the DMX personality on the Pi
+// with the default personality:
 // chan 1 : display mode with inverse MSB for white dots (0 - on, 1 - off)
 // chan 2 : color palette, if 0 then solid color
 // chan 3 : palette angle, 0-360 mapped to 0-255
@@ -19,10 +34,15 @@ char dmxs[DMX_COUNT+1];
 // chan 11: lyric ID least significant byte
 // chan 12: lyric display progress 0-255
 
+uint8_t frame[BUFFER_LENGTH];
+volatile uint8_t channelCount = DMX_DEFAULT;
+uint8_t sequence = 0;
+
 void setup()
 {
   Wire.begin(SLAVE_ADDRESS); // join i2c bus
-  Wire.onRequest(requestEvent); // register event
+  Wire.onReceive(receiveEvent); // register events
+  Wire.onRequest(requestEvent);
   DMXSerial.init(DMXReceiver);
 }
 
@@ -31,16 +51,51 @@ void loop()
   delay(10000);
 }
 
+// CRC-16/CCITT-FALSE, the same one is checked by the Pi
+uint16_t crc16(const uint8_t *data, uint8_t len) {
+  uint16_t crc = 0xFFFF;
+  for (uint8_t i = 0; i < len; i++) {
+    crc ^= (uint16_t)data[i] << 8;
+    for (uint8_t b = 0; b < 8; b++) {
+      if (crc & 0x8000)
+        crc = (crc << 1) ^ 0x1021;
+      else
+        crc <<= 1;
+    }
+  }
+  return crc;
+}
+
+// The request negotiates the channel count, the requests of the other versions are ignored
+void receiveEvent(int len) {
+  uint8_t request[3];
+  uint8_t n = 0;
+  while (Wire.available()) {
+    uint8_t b = Wire.read();
+    if (n < sizeof(request))
+      request[n++] = b;
+  }
+  if (n == sizeof(request) && request[0] == PROTOCOL_MAGIC && request[1] == PROTOCOL_VERSION)
+    channelCount = request[2] < DMX_MAX ? request[2] : DMX_MAX;
+}
+
 void requestEvent() {
+  uint8_t count = channelCount;
   if (DMXSerial.dataUpdated()){
-    dmxs[0] = 255;
+    sequence++;
     DMXSerial.resetUpdated();
   }
-  else
-    dmxs[0] = 0;
 
-  for (int i = 0; i < DMX_COUNT; i++) {
-    dmxs[i+1] = DMXSerial.read(i+1);
+  frame[0] = PROTOCOL_MAGIC;
+  frame[1] = PROTOCOL_VERSION;
+  frame[2] = DMXSerial.noDataSince() < SIGNAL_TIMEOUT ? FLAG_SIGNAL : 0;
+  frame[3] = sequence;
+  frame[4] = count;
+  for (uint8_t i = 0; i < count; i++) {
+    frame[HEADER_LEN+i] = DMXSerial.read(i+1);
   }
-  Wire.write(dmxs, DMX_COUNT+1);
+  uint16_t crc = crc16(frame, HEADER_LEN+count);
+  frame[HEADER_LEN+count] = crc >> 8;
+  frame[HEADER_LEN+count+1] = crc & 0xFF;
+  Wire.write(frame, HEADER_LEN+count+CRC_LEN);
 }
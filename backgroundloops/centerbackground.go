@@ -2,19 +2,24 @@ package backgroundloops
 
 import (
 	"image/color"
+	"image/draw"
 	"math"
 	"time"
 
 	"github.com/TFK1410/go-rpi-fftwave/dmx"
-	rgbmatrix "github.com/tfk1410/go-rpi-rgb-led-matrix"
+	"github.com/TFK1410/go-rpi-fftwave/modes"
 )
 
 // CenterBackground defines the values used for the display of the wave that are specific to this pattern type
 type CenterBackground struct {
+	params                *modes.Params
+	version               uint64
 	dataWidth, dataHeight int
 	min, max              float64
 	hueRotation           time.Duration
 	timeIncrementSpan     time.Duration
+	saturation            float64
+	brightness            float64
 	radiusIndexes         [][]int
 	delayIndexes          []int
 	centerX, centerY      float64
@@ -26,22 +31,24 @@ func (cb *CenterBackground) InitBackgroundLoop(displayWidth int, displayHeight i
 	cb.dataHeight = displayHeight
 	cb.min = minVal
 	cb.max = maxVal
-	if cb.timeIncrementSpan == 0 {
-		cb.timeIncrementSpan = 20 * time.Millisecond
-	}
-	if cb.hueRotation == 0 {
-		cb.hueRotation = 10 * time.Second
-	}
-	if cb.centerX == 0 && cb.centerY == 0 {
-		cb.centerX = float64(displayWidth)/2 - 0.5
-		cb.centerY = float64(displayHeight)/2 - 0.5
-	}
-	cb.radiusIndexes = calculateDistance(displayWidth, displayHeight, cb.centerX, cb.centerY)
+	cb.setup()
+}
+
+// setup calculates the values derived from the current parameters
+func (cb *CenterBackground) setup() {
+	cb.version = cb.params.Version()
+	cb.timeIncrementSpan = cb.params.Duration("timeIncrementSpan")
+	cb.hueRotation = cb.params.Duration("hueRotation")
+	cb.saturation = cb.params.Float("saturation")
+	cb.brightness = cb.params.Float("brightness")
+	cb.centerX = float64(cb.dataWidth)*cb.params.Float("centerX") - 0.5
+	cb.centerY = float64(cb.dataHeight)*cb.params.Float("centerY") - 0.5
+	cb.radiusIndexes = calculateDistance(cb.dataWidth, cb.dataHeight, cb.centerX, cb.centerY)
 
 	// get max radiusIndex
-	mx := 0
-	for x := 0; x < displayWidth; x++ {
-		for y := 0; y < displayHeight; y++ {
+	mx := 1
+	for x := 0; x < cb.dataWidth; x++ {
+		for y := 0; y < cb.dataHeight; y++ {
 			if cb.radiusIndexes[x][y] > mx {
 				mx = cb.radiusIndexes[x][y]
 			}
@@ -51,18 +58,26 @@ func (cb *CenterBackground) InitBackgroundLoop(displayWidth int, displayHeight i
 }
 
 // Draw adds the background details to the canvas on the matrix
-func (cb *CenterBackground) Draw(c *rgbmatrix.Canvas, dmxData dmx.DMXData, soundHistory []SoundEnergyTriBand) {
+func (cb *CenterBackground) Draw(c draw.Image, dmxData dmx.DMXData, soundHistory []SoundEnergyTriBand) {
 	var H, S, V, soundEnergy float64
 	var clr color.RGBA
-	H = float64(time.Now().UnixMilli()%cb.hueRotation.Milliseconds()) / float64(cb.hueRotation.Milliseconds())
-	S = 1
+	if cb.params.Version() != cb.version {
+		cb.setup()
+	}
+	// The newest history entry holds the time of the frame being drawn
+	H = float64(soundHistory[0].Tm.UnixMilli()%cb.hueRotation.Milliseconds()) / float64(cb.hueRotation.Milliseconds())
+	S = cb.saturation
 	cb.updateDelays(soundHistory)
 
 	for y := 0; y < cb.dataHeight; y++ {
 		for x := 0; x < cb.dataWidth; x++ {
-			r, g, b, a := c.At(x, y).RGBA()
-			if r == 0 && g == 0 && b == 0 && a == 0 {
-				soundEnergy = maxTriBand(soundHistory[cb.delayIndexes[cb.radiusIndexes[x][y]-1]])
+			r, g, b, _ := c.At(x, y).RGBA()
+			if r == 0 && g == 0 && b == 0 {
+				radius := cb.radiusIndexes[x][y] - 1
+				if radius < 0 {
+					radius = 0
+				}
+				soundEnergy = maxTriBand(soundHistory[cb.delayIndexes[radius]])
 				V = (soundEnergy - cb.min) / (cb.max - cb.min)
 
 				if V < 0 {
@@ -70,7 +85,7 @@ func (cb *CenterBackground) Draw(c *rgbmatrix.Canvas, dmxData dmx.DMXData, sound
 				} else if V > 1 {
 					V = 1
 				}
-				V = V / 3
+				V = V * cb.brightness
 
 				clr = hsv2RGB(H, S, V)
 
@@ -82,7 +97,7 @@ func (cb *CenterBackground) Draw(c *rgbmatrix.Canvas, dmxData dmx.DMXData, sound
 
 // updateDelays finds the closest soundenergy datapoint to the time marker provided
 func (cb *CenterBackground) updateDelays(soundHistory []SoundEnergyTriBand) {
-	timeNow := time.Now()
+	timeNow := soundHistory[0].Tm
 	sI := 0
 	for i := 0; i < len(cb.delayIndexes); i++ {
 		// find the closest time point relative to the current time to display
@@ -2,14 +2,17 @@ package backgroundloops
 
 import (
 	"image/color"
+	"image/draw"
 	"time"
 
 	"github.com/TFK1410/go-rpi-fftwave/dmx"
-	rgbmatrix "github.com/tfk1410/go-rpi-rgb-led-matrix"
+	"github.com/TFK1410/go-rpi-fftwave/modes"
 )
 
 // CenterBackgroundInst defines the values used for the display of the wave that are specific to this pattern type
 type CenterBackgroundInst struct {
+	params                *modes.Params
+	version               uint64
 	dataWidth, dataHeight int
 	min, max              float64
 	hueRotation           time.Duration
@@ -24,19 +27,21 @@ func (cbi *CenterBackgroundInst) InitBackgroundLoop(displayWidth int, displayHei
 	cbi.dataHeight = displayHeight
 	cbi.min = minVal
 	cbi.max = maxVal
-	if cbi.hueRotation == 0 {
-		cbi.hueRotation = 10 * time.Second
-	}
-	if cbi.centerX == 0 && cbi.centerY == 0 {
-		cbi.centerX = float64(displayWidth)/2 - 0.5
-		cbi.centerY = float64(displayHeight)/2 - 0.5
-	}
-	cbi.radiusIndexes = calculateDistance(displayWidth, displayHeight, cbi.centerX, cbi.centerY)
+	cbi.setup()
+}
+
+// setup calculates the values derived from the current parameters
+func (cbi *CenterBackgroundInst) setup() {
+	cbi.version = cbi.params.Version()
+	cbi.hueRotation = cbi.params.Duration("hueRotation")
+	cbi.centerX = float64(cbi.dataWidth)*cbi.params.Float("centerX") - 0.5
+	cbi.centerY = float64(cbi.dataHeight)*cbi.params.Float("centerY") - 0.5
+	cbi.radiusIndexes = calculateDistance(cbi.dataWidth, cbi.dataHeight, cbi.centerX, cbi.centerY)
 
 	// get max radiusIndex
 	mx := 0
-	for x := 0; x < displayWidth; x++ {
-		for y := 0; y < displayHeight; y++ {
+	for x := 0; x < cbi.dataWidth; x++ {
+		for y := 0; y < cbi.dataHeight; y++ {
 			if cbi.radiusIndexes[x][y] > mx {
 				mx = cbi.radiusIndexes[x][y]
 			}
@@ -46,13 +51,17 @@ func (cbi *CenterBackgroundInst) InitBackgroundLoop(displayWidth int, displayHei
 }
 
 // Draw adds the background details to the canvas on the matrix
-func (cbi *CenterBackgroundInst) Draw(c *rgbmatrix.Canvas, dmxData dmx.DMXData, soundHistory []SoundEnergyTriBand) {
+func (cbi *CenterBackgroundInst) Draw(c draw.Image, dmxData dmx.DMXData, soundHistory []SoundEnergyTriBand) {
 	var H, S, V, soundEnergy float64
 	var energyHeight int
 	var clr color.RGBA
-	H = float64(time.Now().UnixMilli()%cbi.hueRotation.Milliseconds()) / float64(cbi.hueRotation.Milliseconds())
-	S = 1
-	V = 0.3
+	if cbi.params.Version() != cbi.version {
+		cbi.setup()
+	}
+	// The newest history entry holds the time of the frame being drawn
+	H = float64(soundHistory[0].Tm.UnixMilli()%cbi.hueRotation.Milliseconds()) / float64(cbi.hueRotation.Milliseconds())
+	S = cbi.params.Float("saturation")
+	V = cbi.params.Float("brightness")
 	clr = hsv2RGB(H, S, V)
 
 	soundEnergy = 0
@@ -63,8 +72,8 @@ func (cbi *CenterBackgroundInst) Draw(c *rgbmatrix.Canvas, dmxData dmx.DMXData,
 
 	for y := 0; y < cbi.dataHeight; y++ {
 		for x := 0; x < cbi.dataWidth; x++ {
-			r, g, b, a := c.At(x, y).RGBA()
-			if r == 0 && g == 0 && b == 0 && a == 0 && cbi.radiusIndexes[x][y] < energyHeight {
+			r, g, b, _ := c.At(x, y).RGBA()
+			if r == 0 && g == 0 && b == 0 && cbi.radiusIndexes[x][y] < energyHeight {
 				c.Set(x, y, clr)
 			}
 		}
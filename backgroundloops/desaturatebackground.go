@@ -2,13 +2,15 @@ package backgroundloops
 
 import (
 	"image/color"
+	"image/draw"
 
 	"github.com/TFK1410/go-rpi-fftwave/dmx"
-	rgbmatrix "github.com/tfk1410/go-rpi-rgb-led-matrix"
+	"github.com/TFK1410/go-rpi-fftwave/modes"
 )
 
 // DesaturateBackground defines the values used for the display of the wave that are specific to this pattern type
 type DesaturateBackground struct {
+	params                *modes.Params
 	dataWidth, dataHeight int
 	min, max              float64
 }
@@ -22,7 +24,7 @@ func (db *DesaturateBackground) InitBackgroundLoop(displayWidth int, displayHeig
 }
 
 // Draw adds the background details to the canvas on the matrix
-func (db *DesaturateBackground) Draw(c *rgbmatrix.Canvas, dmxData dmx.DMXData, soundHistory []SoundEnergyTriBand) {
+func (db *DesaturateBackground) Draw(c draw.Image, dmxData dmx.DMXData, soundHistory []SoundEnergyTriBand) {
 	var soundEnergy, energyDesat float64
 
 	soundEnergy = 0
@@ -35,6 +37,7 @@ func (db *DesaturateBackground) Draw(c *rgbmatrix.Canvas, dmxData dmx.DMXData, s
 	} else if energyDesat < 0 {
 		energyDesat = 0
 	}
+	energyDesat *= db.params.Float("strength")
 
 	for y := 0; y < db.dataHeight; y++ {
 		for x := 0; x < db.dataWidth; x++ {
@@ -2,15 +2,17 @@ package backgroundloops
 
 import (
 	"image/color"
+	"image/draw"
 	"math"
 	"time"
 
 	"github.com/TFK1410/go-rpi-fftwave/dmx"
-	rgbmatrix "github.com/tfk1410/go-rpi-rgb-led-matrix"
+	"github.com/TFK1410/go-rpi-fftwave/modes"
 )
 
 // HistoryBackground defines the values used for the display of the wave that are specific to this pattern type
 type HistoryBackground struct {
+	params                *modes.Params
 	dataWidth, dataHeight int
 	min, max, bandPoints  float64
 	timeSpan              time.Duration
@@ -23,13 +25,13 @@ func (b *HistoryBackground) InitBackgroundLoop(displayWidth int, displayHeight i
 	b.min = minVal
 	b.max = maxVal
 	b.bandPoints = float64(b.dataHeight) / 3.0
-	b.timeSpan = 1000 * time.Millisecond
 }
 
 // Draw adds the background details to the canvas on the matrix
-func (b *HistoryBackground) Draw(c *rgbmatrix.Canvas, dmxData dmx.DMXData, soundHistory []SoundEnergyTriBand) {
+func (b *HistoryBackground) Draw(c draw.Image, dmxData dmx.DMXData, soundHistory []SoundEnergyTriBand) {
 	sI := 0 //soundIndex
-	timeNow := time.Now()
+	b.timeSpan = b.params.Duration("timeSpan")
+	timeNow := soundHistory[0].Tm
 	for i := 0; i < b.dataWidth; i++ {
 		// find the closest time point relative to the current time to display
 		curTime := timeNow.Add(-time.Duration(float64(i) / float64(b.dataWidth) * float64(b.timeSpan)))
@@ -58,13 +60,13 @@ func (b *HistoryBackground) Draw(c *rgbmatrix.Canvas, dmxData dmx.DMXData, sound
 }
 
 // This function will mirror out a single pixel draw to multiple fields as required
-func (b *HistoryBackground) drawPixels(c *rgbmatrix.Canvas, x, y int, clr color.RGBA) {
-	r, g, bl, a := c.At(x, b.dataHeight+y).RGBA()
-	if r == 0 && g == 0 && bl == 0 && a == 0 {
+func (b *HistoryBackground) drawPixels(c draw.Image, x, y int, clr color.RGBA) {
+	r, g, bl, _ := c.At(x, b.dataHeight+y).RGBA()
+	if r == 0 && g == 0 && bl == 0 {
 		c.Set(x, b.dataHeight+y, clr)
 	}
-	r, g, bl, a = c.At(x, b.dataHeight-1-y).RGBA()
-	if r == 0 && g == 0 && bl == 0 && a == 0 {
+	r, g, bl, _ = c.At(x, b.dataHeight-1-y).RGBA()
+	if r == 0 && g == 0 && bl == 0 {
 		c.Set(x, b.dataHeight-1-y, clr)
 	}
 }
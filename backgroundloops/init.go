@@ -1,10 +1,13 @@
 package backgroundloops
 
 import (
+	"fmt"
+	"image/draw"
+	"sync"
 	"time"
 
 	"github.com/TFK1410/go-rpi-fftwave/dmx"
-	rgbmatrix "github.com/tfk1410/go-rpi-rgb-led-matrix"
+	"github.com/TFK1410/go-rpi-fftwave/modes"
 )
 
 type SoundEnergyTriBand struct {
@@ -15,24 +18,155 @@ type SoundEnergyTriBand struct {
 // Wave is used for the implementation of any possible display patterns
 type BackgroundLoop interface {
 	InitBackgroundLoop(int, int, float64, float64)
-	Draw(*rgbmatrix.Canvas, dmx.DMXData, []SoundEnergyTriBand)
+	Draw(draw.Image, dmx.DMXData, []SoundEnergyTriBand)
 }
 
+// iterator holds the index of the currently selected background and is shared by every way of switching the backgrounds
 var iterator int
+var iteratorMu sync.Mutex
 var backgroundLoops []BackgroundLoop
 
-// InitBackgroundLoops creates the BackgroundLoop types array and initializes every one of them
-func InitBackgroundLoops(displayWidth int, displayHeight int, minVal, maxVal float64) {
-	backgroundLoops = append(backgroundLoops, &NoBackground{})
-	backgroundLoops = append(backgroundLoops, &CenterBackground{})
-	backgroundLoops = append(backgroundLoops, &CenterBackgroundInst{})
-	backgroundLoops = append(backgroundLoops, &HistoryBackground{timeSpan: 1000 * time.Millisecond})
-	backgroundLoops = append(backgroundLoops, &ShiftHueBackground{})
-	backgroundLoops = append(backgroundLoops, &DesaturateBackground{})
+// infos and params hold the descriptions and the parameter sets of the BackgroundLoop instances
+// in the same order as the BackgroundLoops
+var infos []modes.Info
+var params []*modes.Params
 
-	for i := range backgroundLoops {
+// CheckPresets checks that every preset has a unique name which isn't taken by a background type and a known type
+func CheckPresets(presets []modes.Preset) error {
+	for i, preset := range presets {
+		if preset.Name == "" {
+			return fmt.Errorf("background preset of %q has no name", preset.Type)
+		}
+		if _, ok := lookup(preset.Name); ok {
+			return fmt.Errorf("background preset %q uses the name of a background type", preset.Name)
+		}
+		for _, other := range presets[:i] {
+			if other.Name == preset.Name {
+				return fmt.Errorf("background preset %q is defined twice", preset.Name)
+			}
+		}
+		if _, ok := lookup(preset.Type); !ok {
+			return fmt.Errorf("background preset %q: unknown background %q", preset.Name, preset.Type)
+		}
+	}
+	return nil
+}
+
+// ListBackgroundLoops returns the descriptions of all the selectable BackgroundLoops in their index order
+// these are all of the registered BackgroundLoop types followed by the presets
+func ListBackgroundLoops(presets []modes.Preset) ([]modes.Info, error) {
+	out := Available()
+	if err := CheckPresets(presets); err != nil {
+		return nil, err
+	}
+	for _, preset := range presets {
+		r, _ := lookup(preset.Type)
+		info := registry[r].info
+		info.Name = preset.Name
+		info.Description = preset.Description
+		if info.Description == "" {
+			info.Description = "Preset of " + preset.Type
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+// InitBackgroundLoops creates an instance of every registered BackgroundLoop type and every preset and initializes every one of them
+// shared holds the values applied to every BackgroundLoop with a parameter of the same name
+// before the values from its own section of the config which is keyed by the BackgroundLoop name
+func InitBackgroundLoops(displayWidth int, displayHeight int, minVal, maxVal float64, shared map[string]interface{}, config modes.Config, presets []modes.Preset) error {
+	for name := range config {
+		if _, ok := lookup(name); !ok {
+			return fmt.Errorf("unknown background %q in the config", name)
+		}
+	}
+
+	var err error
+	infos, err = ListBackgroundLoops(presets)
+	if err != nil {
+		return err
+	}
+
+	backgroundLoops = make([]BackgroundLoop, len(infos))
+	params = make([]*modes.Params, len(infos))
+	for i, info := range infos {
+		params[i] = modes.NewParams(info.Params)
+		if err := loadParams(params[i], info, shared, config, presets); err != nil {
+			return err
+		}
+
+		r, _ := lookup(info.Type)
+		backgroundLoops[i] = registry[r].new(params[i])
 		backgroundLoops[i].InitBackgroundLoop(displayWidth, displayHeight, minVal, maxVal)
 	}
+	return nil
+}
+
+// loadParams applies the shared values to the parameters that exist in the set
+// followed by the values from the config section of the BackgroundLoop type and the values of the preset
+func loadParams(p *modes.Params, info modes.Info, shared map[string]interface{}, config modes.Config, presets []modes.Preset) error {
+	merged := make(map[string]interface{})
+	for name, value := range shared {
+		if p.Has(name) {
+			merged[name] = value
+		}
+	}
+	for name, value := range config[info.Type] {
+		merged[name] = value
+	}
+	for _, preset := range presets {
+		if preset.Name == info.Name {
+			for name, value := range preset.Params {
+				merged[name] = value
+			}
+		}
+	}
+	if err := p.Load(merged); err != nil {
+		return fmt.Errorf("background %s: %v", info.Name, err)
+	}
+	return nil
+}
+
+// find returns the index of the BackgroundLoop instance with the given name
+func find(name string) (int, bool) {
+	for i := range infos {
+		if infos[i].Name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// SetParam changes a single parameter of the BackgroundLoop with the given name at runtime
+func SetParam(background, name, value string) error {
+	i, ok := find(background)
+	if !ok {
+		return fmt.Errorf("unknown background %q", background)
+	}
+	return params[i].Set(name, value)
+}
+
+// LoadParams changes the parameters of the BackgroundLoops and their presets at runtime from the values decoded from the config
+// presets can't be added or removed this way as that would change the background indexes
+func LoadParams(shared map[string]interface{}, config modes.Config, presets []modes.Preset) error {
+	for name := range config {
+		if _, ok := lookup(name); !ok {
+			return fmt.Errorf("unknown background %q", name)
+		}
+	}
+	for _, preset := range presets {
+		if i, ok := find(preset.Name); !ok || infos[i].Type != preset.Type {
+			return fmt.Errorf("background preset %q is new or changed its type, restart the application to apply it", preset.Name)
+		}
+	}
+
+	for i, info := range infos {
+		if err := loadParams(params[i], info, shared, config, presets); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // GetFirstWave returns the first BackgroundLoop type from the array
@@ -40,9 +174,12 @@ func GetFirstBackgroundLoop() BackgroundLoop {
 	return backgroundLoops[0]
 }
 
+// GetBackgroundLoopNum returns the BackgroundLoop with the given index, out of range indexes fall back to the first one
 func GetBackgroundLoopNum(i int) BackgroundLoop {
+	iteratorMu.Lock()
+	defer iteratorMu.Unlock()
 	iterator = i
-	if iterator >= len(backgroundLoops) {
+	if iterator >= len(backgroundLoops) || iterator < 0 {
 		iterator = 0
 	}
 	return backgroundLoops[iterator]
@@ -50,9 +187,27 @@ func GetBackgroundLoopNum(i int) BackgroundLoop {
 
 // GetNextWave returns the next BackgroundLoop type from the slice
 func GetNextBackgroundLoop() BackgroundLoop {
+	iteratorMu.Lock()
+	defer iteratorMu.Unlock()
 	iterator++
 	if iterator >= len(backgroundLoops) {
 		iterator = 0
 	}
 	return backgroundLoops[iterator]
 }
+
+// GetBackgroundLoopByName returns the BackgroundLoop type or preset with the given name
+func GetBackgroundLoopByName(name string) (BackgroundLoop, error) {
+	i, ok := find(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown background %q", name)
+	}
+	return GetBackgroundLoopNum(i), nil
+}
+
+// GetCurrentBackgroundLoopName returns the name of the last selected BackgroundLoop
+func GetCurrentBackgroundLoopName() string {
+	iteratorMu.Lock()
+	defer iteratorMu.Unlock()
+	return infos[iterator].Name
+}
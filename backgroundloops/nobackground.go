@@ -1,8 +1,9 @@
 package backgroundloops
 
 import (
+	"image/draw"
+
 	"github.com/TFK1410/go-rpi-fftwave/dmx"
-	rgbmatrix "github.com/tfk1410/go-rpi-rgb-led-matrix"
 )
 
 // MirrorWave defines the values used for the display of the wave that are specific to this pattern type
@@ -14,5 +15,5 @@ func (b *NoBackground) InitBackgroundLoop(displayWidth int, displayHeight int, m
 }
 
 // Draw adds the background details to the canvas on the matrix
-func (m *NoBackground) Draw(c *rgbmatrix.Canvas, dmxData dmx.DMXData, soundHistory []SoundEnergyTriBand) {
+func (m *NoBackground) Draw(c draw.Image, dmxData dmx.DMXData, soundHistory []SoundEnergyTriBand) {
 }
@@ -0,0 +1,103 @@
+package backgroundloops
+
+import (
+	"fmt"
+
+	"github.com/TFK1410/go-rpi-fftwave/modes"
+)
+
+type registration struct {
+	info modes.Info
+	new  func(*modes.Params) BackgroundLoop
+}
+
+// registry keeps the background types in the order of registration
+// this order defines the index used by the DMX background mode so new backgrounds should only be added at the end
+var registry []registration
+
+// Register adds a new background type to the registry under a unique name
+func Register(info modes.Info, new func(*modes.Params) BackgroundLoop) {
+	for _, r := range registry {
+		if r.info.Name == info.Name {
+			panic(fmt.Sprintf("backgroundloops: background %q registered twice", info.Name))
+		}
+	}
+	info.Type = info.Name
+	registry = append(registry, registration{info: info, new: new})
+}
+
+// Available returns the descriptions of all the registered background types in their index order
+func Available() []modes.Info {
+	out := make([]modes.Info, len(registry))
+	for i, r := range registry {
+		out[i] = r.info
+	}
+	return out
+}
+
+// lookup finds the index of the background type registered under the given name
+func lookup(name string) (int, bool) {
+	for i := range registry {
+		if registry[i].info.Name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Parameters shared by the backgrounds drawing the sound energy in rotating hue
+var (
+	hueRotationParam = modes.Param{Name: "hueRotation", Description: "Time of a full hue rotation", Kind: modes.Duration, Default: "10s", Min: 0.01, Max: 3600}
+	saturationParam  = modes.Param{Name: "saturation", Description: "Saturation of the colors", Kind: modes.Float, Default: "1", Min: 0, Max: 1}
+	centerXParam     = modes.Param{Name: "centerX", Description: "Horizontal position of the center as a fraction of the width", Kind: modes.Float, Default: "0.5", Min: 0, Max: 1}
+	centerYParam     = modes.Param{Name: "centerY", Description: "Vertical position of the center as a fraction of the height", Kind: modes.Float, Default: "0.5", Min: 0, Max: 1}
+)
+
+func init() {
+	Register(modes.Info{Name: "none", Description: "No background"},
+		func(*modes.Params) BackgroundLoop { return &NoBackground{} })
+	Register(modes.Info{
+		Name:        "center",
+		Description: "Sound energy history rippling out from the center with rotating hue",
+		Params: []modes.Param{
+			hueRotationParam,
+			{Name: "timeIncrementSpan", Description: "Time difference between two neighbouring rings", Kind: modes.Duration, Default: "20ms", Min: 0.001, Max: 1},
+			saturationParam,
+			{Name: "brightness", Description: "Brightness of the loudest ripples", Kind: modes.Float, Default: "0.333", Min: 0, Max: 1},
+			centerXParam,
+			centerYParam,
+		},
+	}, func(p *modes.Params) BackgroundLoop { return &CenterBackground{params: p} })
+	Register(modes.Info{
+		Name:        "center-instant",
+		Description: "Circle in the center sized by the current sound energy",
+		Params: []modes.Param{
+			hueRotationParam,
+			saturationParam,
+			{Name: "brightness", Description: "Brightness of the circle", Kind: modes.Float, Default: "0.3", Min: 0, Max: 1},
+			centerXParam,
+			centerYParam,
+		},
+	}, func(p *modes.Params) BackgroundLoop { return &CenterBackgroundInst{params: p} })
+	Register(modes.Info{
+		Name:        "history",
+		Description: "Scrolling history of the bass, mid and treble energy",
+		Params: []modes.Param{
+			{Name: "timeSpan", Description: "Time span of the history shown across the screen", Kind: modes.Duration, Default: "1s", Min: 0.01, Max: 60},
+		},
+	}, func(p *modes.Params) BackgroundLoop { return &HistoryBackground{params: p} })
+	Register(modes.Info{
+		Name:        "shift-hue",
+		Description: "Hue of the wave shifted with the sound energy",
+		Params: []modes.Param{
+			{Name: "maxAngle", Description: "Hue shift in degrees at the max sound energy", Kind: modes.Float, Default: "90", Min: 0, Max: 360},
+		},
+	}, func(p *modes.Params) BackgroundLoop { return &ShiftHueBackground{params: p} })
+	Register(modes.Info{
+		Name:        "desaturate",
+		Description: "Wave desaturated when the sound energy is low",
+		Params: []modes.Param{
+			{Name: "strength", Description: "Desaturation at the lowest sound energy", Kind: modes.Float, Default: "1", Min: 0, Max: 1},
+		},
+	}, func(p *modes.Params) BackgroundLoop { return &DesaturateBackground{params: p} })
+}
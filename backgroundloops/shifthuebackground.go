@@ -2,14 +2,16 @@ package backgroundloops
 
 import (
 	"image/color"
+	"image/draw"
 	"math"
 
 	"github.com/TFK1410/go-rpi-fftwave/dmx"
-	rgbmatrix "github.com/tfk1410/go-rpi-rgb-led-matrix"
+	"github.com/TFK1410/go-rpi-fftwave/modes"
 )
 
 // ShiftHueBackground defines the values used for the display of the wave that are specific to this pattern type
 type ShiftHueBackground struct {
+	params                *modes.Params
 	dataWidth, dataHeight int
 	min, max              float64
 	matrix                [3][3]float64
@@ -25,16 +27,17 @@ func (shb *ShiftHueBackground) InitBackgroundLoop(displayWidth int, displayHeigh
 }
 
 // Draw adds the background details to the canvas on the matrix
-func (shb *ShiftHueBackground) Draw(c *rgbmatrix.Canvas, dmxData dmx.DMXData, soundHistory []SoundEnergyTriBand) {
+func (shb *ShiftHueBackground) Draw(c draw.Image, dmxData dmx.DMXData, soundHistory []SoundEnergyTriBand) {
 	var soundEnergy, energyAngle float64
 
 	soundEnergy = 0
 	for i := 0; i < 5; i++ {
 		soundEnergy += maxTriBand(soundHistory[i]) / 5
 	}
-	energyAngle = float64((soundEnergy - float64(shb.min)) / float64((shb.max - shb.min)) * 90)
-	if energyAngle > 90 {
-		energyAngle = 90
+	maxAngle := shb.params.Float("maxAngle")
+	energyAngle = float64((soundEnergy - float64(shb.min)) / float64((shb.max - shb.min)) * maxAngle)
+	if energyAngle > maxAngle {
+		energyAngle = maxAngle
 	} else if energyAngle < 0 {
 		energyAngle = 0
 	}
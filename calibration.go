@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/TFK1410/go-rpi-fftwave/output"
+	"gopkg.in/yaml.v3"
+)
+
+var testPattern = flag.String("test-pattern", "", "Show a calibration test pattern instead of the visualization: "+strings.Join(testPatternNames, ", "))
+
+var testPatternNames = []string{"white", "gray", "bars", "ramps", "panels"}
+
+// colorBars are the bars of the bars test pattern from the left to the right
+var colorBars = []color.RGBA{
+	{255, 255, 255, 255},
+	{255, 255, 0, 255},
+	{0, 255, 255, 255},
+	{0, 255, 0, 255},
+	{255, 0, 255, 255},
+	{255, 0, 0, 255},
+	{0, 0, 255, 255},
+	{0, 0, 0, 255},
+}
+
+// calibration converts the color calibration config for a canvas made of the panels described by the matrix config
+func calibration(cc colorCalibrationConfig, mc matrixConfig) (output.Calibration, error) {
+	c := output.Calibration{
+		Gamma: cc.Gamma,
+		Gains: [3]float64{cc.WhiteBalance.Red, cc.WhiteBalance.Green, cc.WhiteBalance.Blue},
+	}
+	for _, pc := range cc.Panels {
+		r, err := panelRect(pc, mc)
+		if err != nil {
+			return c, err
+		}
+		p := output.PanelCalibration{Rect: r, Matrix: pc.Matrix}
+		// A panel without the matrix only has its own gains
+		if pc.Matrix == ([3][3]float64{}) {
+			p.Matrix = [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+		}
+		if pc.Gains != ([3]float64{}) {
+			for ch := range p.Matrix {
+				for i := range p.Matrix[ch] {
+					p.Matrix[ch][i] *= pc.Gains[ch]
+				}
+			}
+		}
+		c.Panels = append(c.Panels, p)
+	}
+	return c, nil
+}
+
+// panelRect returns the part of the canvas shown by the panel at the chain position
+// the pixel mappers move the panels around so with them the rectangle has to be given in the config
+func panelRect(pc panelCalibrationConfig, mc matrixConfig) (image.Rectangle, error) {
+	if pc.Width > 0 && pc.Height > 0 {
+		return image.Rect(pc.X, pc.Y, pc.X+pc.Width, pc.Y+pc.Height), nil
+	}
+	if mc.PixelMapperConfig != "" {
+		return image.Rectangle{}, fmt.Errorf("calibration: panel %d/%d needs the x, y, width and height with the pixel mapper", pc.Chain, pc.Parallel)
+	}
+	if pc.Chain < 0 || pc.Chain >= mc.Chain || pc.Parallel < 0 || pc.Parallel >= mc.Parallel {
+		return image.Rectangle{}, fmt.Errorf("calibration: there's no panel %d in the chain %d", pc.Chain, pc.Parallel)
+	}
+	x, y := pc.Chain*mc.Cols, pc.Parallel*mc.Rows
+	return image.Rect(x, y, x+mc.Cols, y+mc.Rows), nil
+}
+
+// loadCalibration reads the color calibration from the config file again, missing values fall back to the loaded ones
+func loadCalibration(cfg *Configuration, path string) (output.Calibration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return output.Calibration{}, fmt.Errorf("error opening the config file: %v", err)
+	}
+	defer f.Close()
+
+	cc := struct {
+		Calibration colorCalibrationConfig `yaml:"colorCalibration"`
+	}{cfg.Calibration}
+	if err := yaml.NewDecoder(f).Decode(&cc); err != nil {
+		return output.Calibration{}, fmt.Errorf("error parsing the config file: %v", err)
+	}
+	c, err := calibration(cc.Calibration, cfg.IntMatrix)
+	if err != nil {
+		return c, err
+	}
+	cfg.Calibration = cc.Calibration
+	return c, nil
+}
+
+// drawTestPattern draws one of the calibration patterns, the panels pattern marks every panel with its chain position
+func drawTestPattern(name string, frame *image.RGBA, mc matrixConfig) error {
+	b := frame.Bounds()
+	w, h := b.Dx(), b.Dy()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var c color.RGBA
+			switch name {
+			case "white":
+				c = color.RGBA{255, 255, 255, 255}
+			case "gray":
+				c = color.RGBA{128, 128, 128, 255}
+			case "bars":
+				c = colorBars[len(colorBars)*x/w]
+			case "ramps":
+				// Gray, red, green and blue ramps from the left to the right
+				v := byte(255 * x / (w - 1))
+				switch 4 * y / h {
+				case 0:
+					c = color.RGBA{v, v, v, 255}
+				case 1:
+					c = color.RGBA{v, 0, 0, 255}
+				case 2:
+					c = color.RGBA{0, v, 0, 255}
+				case 3:
+					c = color.RGBA{0, 0, v, 255}
+				}
+			case "panels":
+				c = color.RGBA{255, 255, 255, 255}
+			default:
+				return fmt.Errorf("unknown test pattern %q, the patterns are: %s", name, strings.Join(testPatternNames, ", "))
+			}
+			frame.SetRGBA(x, y, c)
+		}
+	}
+
+	if name == "panels" && mc.PixelMapperConfig == "" {
+		// Every panel gets a red frame and a row of blue dots with the count of its chain position plus one
+		for p := 0; p < mc.Chain; p++ {
+			for q := 0; q < mc.Parallel; q++ {
+				r, _ := panelRect(panelCalibrationConfig{Chain: p, Parallel: q}, mc)
+				r = r.Intersect(b)
+				for x := r.Min.X; x < r.Max.X; x++ {
+					frame.SetRGBA(x, r.Min.Y, color.RGBA{255, 0, 0, 255})
+					frame.SetRGBA(x, r.Max.Y-1, color.RGBA{255, 0, 0, 255})
+				}
+				for y := r.Min.Y; y < r.Max.Y; y++ {
+					frame.SetRGBA(r.Min.X, y, color.RGBA{255, 0, 0, 255})
+					frame.SetRGBA(r.Max.X-1, y, color.RGBA{255, 0, 0, 255})
+				}
+				for i := 0; i <= p && r.Min.X+3+3*i < r.Max.X-1; i++ {
+					frame.SetRGBA(r.Min.X+3+3*i, r.Min.Y+3, color.RGBA{0, 0, 255, 255})
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// runTestPattern shows the test pattern until the quit signal, the reload signal applies the calibration
+// from the config file again so it can be tuned while looking at the panels
+func runTestPattern(name string, s *output.Corrected, width, height int, quit, reload <-chan os.Signal) error {
+	frame := image.NewRGBA(image.Rect(0, 0, width, height))
+	if err := drawTestPattern(name, frame, cfg.IntMatrix); err != nil {
+		return err
+	}
+	log.Println("Showing the", name, "test pattern, send SIGHUP to reload the color calibration")
+	for {
+		if err := s.Send(frame); err != nil {
+			return err
+		}
+		select {
+		case <-reload:
+			c, err := loadCalibration(&cfg, *configPath)
+			if err == nil {
+				err = s.SetCalibration(c)
+			}
+			if err != nil {
+				log.Println("Reloading the color calibration failed:", err)
+			} else {
+				log.Println("Color calibration reloaded")
+			}
+		case <-quit:
+			return nil
+		}
+	}
+}
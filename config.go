@@ -1,9 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 
+	"github.com/TFK1410/go-rpi-fftwave/backgroundloops"
+	"github.com/TFK1410/go-rpi-fftwave/dmx"
+	"github.com/TFK1410/go-rpi-fftwave/drawloops"
+	"github.com/TFK1410/go-rpi-fftwave/midi"
+	"github.com/TFK1410/go-rpi-fftwave/modes"
+	"github.com/TFK1410/go-rpi-fftwave/output"
 	rgbmatrix "github.com/tfk1410/go-rpi-rgb-led-matrix"
 	"gopkg.in/yaml.v3"
 )
@@ -37,6 +44,8 @@ type displayConfig struct {
 	MaxHz          float64 `yaml:"maxHz,omitempty"`
 	MinVal         float64 `yaml:"minVal,omitempty"`
 	MaxVal         float64 `yaml:"maxVal,omitempty"`
+	Wave           string  `yaml:"wave,omitempty"`
+	Background     string  `yaml:"background,omitempty"`
 }
 
 type whiteDotConfig struct {
@@ -60,7 +69,137 @@ type encoderConfig struct {
 }
 
 type dmxConfig struct {
-	SlaveAddress byte `yaml:"slaveAddress,omitempty"`
+	Input        string             `yaml:"input,omitempty"`
+	SlaveAddress byte               `yaml:"slaveAddress,omitempty"`
+	Address      string             `yaml:"address,omitempty"`
+	Universe     int                `yaml:"universe,omitempty"`
+	StartAddress int                `yaml:"startAddress,omitempty"`
+	Merge        string             `yaml:"merge,omitempty"`
+	Personality  string             `yaml:"personality,omitempty"`
+	Failsafe     failsafeConfig     `yaml:"failsafe"`
+	Show         showPlaybackConfig `yaml:"show"`
+}
+
+type oscConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Address string `yaml:"address,omitempty"`
+}
+
+type midiConfig struct {
+	Input    string         `yaml:"input,omitempty"`
+	Name     string         `yaml:"name,omitempty"`
+	Connect  string         `yaml:"connect,omitempty"`
+	Device   string         `yaml:"device,omitempty"`
+	Bindings []midi.Binding `yaml:"bindings"`
+}
+
+type showPlaybackConfig struct {
+	File    string `yaml:"file"`
+	Loop    bool   `yaml:"loop,omitempty"`
+	StartAt string `yaml:"startAt,omitempty"`
+	Command string `yaml:"command,omitempty"`
+}
+
+type failsafeConfig struct {
+	Timeout  float64      `yaml:"timeout"`
+	Mode     string       `yaml:"mode,omitempty"`
+	FadeTime float64      `yaml:"fadeTime,omitempty"`
+	Show     failsafeShow `yaml:"show"`
+}
+
+// failsafeShow is the show file played by the failsafe or its look with the same values as the DMX channels
+type failsafeShow struct {
+	File               string  `yaml:"file,omitempty"`
+	DisplayMode        byte    `yaml:"displayMode"`
+	BackgroundMode     byte    `yaml:"backgroundMode"`
+	WhiteDots          bool    `yaml:"whiteDots"`
+	ColorPalette       byte    `yaml:"colorPalette"`
+	PaletteAngle       byte    `yaml:"paletteAngle"`
+	PalettePhaseOffset byte    `yaml:"palettePhaseOffset"`
+	Color              [3]byte `yaml:"color,flow"`
+}
+
+type captureConfig struct {
+	Format    string  `yaml:"format,omitempty"`
+	Path      string  `yaml:"path,omitempty"`
+	Command   string  `yaml:"command,omitempty"`
+	Duration  float64 `yaml:"duration,omitempty"`
+	Scale     int     `yaml:"scale,omitempty"`
+	FrameRate int     `yaml:"frameRate,omitempty"`
+	OnStart   bool    `yaml:"onStart,omitempty"`
+}
+
+type networkOutputConfig struct {
+	Protocol          string         `yaml:"protocol"`
+	Address           string         `yaml:"address"`
+	FrameRate         int            `yaml:"frameRate,omitempty"`
+	Timeout           int            `yaml:"timeout,omitempty"`
+	Universe          int            `yaml:"universe,omitempty"`
+	PixelsPerUniverse int            `yaml:"pixelsPerUniverse,omitempty"`
+	Order             string         `yaml:"order,omitempty"`
+	Priority          int            `yaml:"priority,omitempty"`
+	Mapping           output.Mapping `yaml:"mapping,omitempty"`
+}
+
+type localOutputConfig struct {
+	Matrix       bool               `yaml:"matrix"`
+	Framebuffer  framebufferConfig  `yaml:"framebuffer"`
+	SharedMemory sharedMemoryConfig `yaml:"sharedMemory"`
+}
+
+type framebufferConfig struct {
+	Device string `yaml:"device,omitempty"`
+	Scale  int    `yaml:"scale,omitempty"`
+}
+
+type sharedMemoryConfig struct {
+	Name  string `yaml:"name,omitempty"`
+	Slots int    `yaml:"slots,omitempty"`
+	Scale int    `yaml:"scale,omitempty"`
+}
+
+type previewConfig struct {
+	Address   string `yaml:"address,omitempty"`
+	Scale     int    `yaml:"scale,omitempty"`
+	FrameRate int    `yaml:"frameRate,omitempty"`
+	Quality   int    `yaml:"quality,omitempty"`
+}
+
+type colorCalibrationConfig struct {
+	Gamma        float64                  `yaml:"gamma,omitempty"`
+	WhiteBalance whiteBalanceConfig       `yaml:"whiteBalance"`
+	Panels       []panelCalibrationConfig `yaml:"panels"`
+}
+
+type whiteBalanceConfig struct {
+	Red   float64 `yaml:"red"`
+	Green float64 `yaml:"green"`
+	Blue  float64 `yaml:"blue"`
+}
+
+type panelCalibrationConfig struct {
+	Chain    int           `yaml:"chain"`
+	Parallel int           `yaml:"parallel"`
+	X        int           `yaml:"x,omitempty"`
+	Y        int           `yaml:"y,omitempty"`
+	Width    int           `yaml:"width,omitempty"`
+	Height   int           `yaml:"height,omitempty"`
+	Gains    [3]float64    `yaml:"gains,omitempty"`
+	Matrix   [3][3]float64 `yaml:"matrix,omitempty"`
+}
+
+type powerLimitConfig struct {
+	MaxCurrent     float64              `yaml:"maxCurrent,omitempty"`
+	ChannelCurrent channelCurrentConfig `yaml:"channelCurrent"`
+	Gamma          float64              `yaml:"gamma,omitempty"`
+	Release        float64              `yaml:"release,omitempty"`
+	ReportInterval float64              `yaml:"reportInterval,omitempty"`
+}
+
+type channelCurrentConfig struct {
+	Red   float64 `yaml:"red"`
+	Green float64 `yaml:"green"`
+	Blue  float64 `yaml:"blue"`
 }
 
 type lyricsOverlayConfig struct {
@@ -71,16 +210,39 @@ type lyricsOverlayConfig struct {
 // Configuration is a struct holding the config of the application
 // details regarding these fields can be found in config.yml
 type Configuration struct {
-	Matrix      *rgbmatrix.HardwareConfig
-	IntMatrix   matrixConfig        `yaml:"matrixConfig"`
-	SampleRate  int                 `yaml:"sampleRate"`
-	FFT         fftConfig           `yaml:"fftConfig"`
-	Display     displayConfig       `yaml:"displayConfig"`
-	WhiteDot    whiteDotConfig      `yaml:"whiteDotConfig"`
-	SoundEnergy soundEnergyConfig   `yaml:"soundEnergyConfig"`
-	Encoder     encoderConfig       `yaml:"encoderConfig"`
-	DMX         dmxConfig           `yaml:"dmxConfig"`
-	Lyrics      lyricsOverlayConfig `yaml:"lyricsOverlayConfig"`
+	Matrix           *rgbmatrix.HardwareConfig
+	IntMatrix        matrixConfig           `yaml:"matrixConfig"`
+	SampleRate       int                    `yaml:"sampleRate"`
+	FFT              fftConfig              `yaml:"fftConfig"`
+	Display          displayConfig          `yaml:"displayConfig"`
+	WhiteDot         whiteDotConfig         `yaml:"whiteDotConfig"`
+	SoundEnergy      soundEnergyConfig      `yaml:"soundEnergyConfig"`
+	Waves            modes.Config           `yaml:"waveConfig"`
+	Backgrounds      modes.Config           `yaml:"backgroundConfig"`
+	WavePresets      []modes.Preset         `yaml:"wavePresets"`
+	BgPresets        []modes.Preset         `yaml:"backgroundPresets"`
+	Layouts          []drawloops.Layout     `yaml:"layouts"`
+	Encoder          encoderConfig          `yaml:"encoderConfig"`
+	DMX              dmxConfig              `yaml:"dmxConfig"`
+	DMXPersonalities []dmx.Personality      `yaml:"dmxPersonalities"`
+	OSC              oscConfig              `yaml:"oscConfig"`
+	MIDI             midiConfig             `yaml:"midiConfig"`
+	Lyrics           lyricsOverlayConfig    `yaml:"lyricsOverlayConfig"`
+	Capture          captureConfig          `yaml:"captureConfig"`
+	Network          []networkOutputConfig  `yaml:"networkOutputs"`
+	Local            localOutputConfig      `yaml:"localOutputs"`
+	Preview          previewConfig          `yaml:"previewConfig"`
+	Calibration      colorCalibrationConfig `yaml:"colorCalibration"`
+	Power            powerLimitConfig       `yaml:"powerLimit"`
+}
+
+// defaultSoundEnergy is kept apart from the rest of the defaults as the reload of the mode parameters falls back to it
+var defaultSoundEnergy = soundEnergyConfig{
+	HistoryCount: 128,
+	MinBand:      100,
+	MaxBand:      200,
+	Saturation:   100,
+	HueTime:      10,
 }
 
 // This variable holds the default values
@@ -112,18 +274,14 @@ var cfg Configuration = Configuration{
 		MaxHz:          20000,
 		MinVal:         110,
 		MaxVal:         155,
+		Wave:           "single",
+		Background:     "none",
 	},
 	WhiteDot: whiteDotConfig{
 		HangTime:  0.5,
 		DropSpeed: 25,
 	},
-	SoundEnergy: soundEnergyConfig{
-		HistoryCount: 128,
-		MinBand:      100,
-		MaxBand:      200,
-		Saturation:   100,
-		HueTime:      10,
-	},
+	SoundEnergy: defaultSoundEnergy,
 	Encoder: encoderConfig{
 		DTPin:         16,
 		CLKPin:        20,
@@ -131,12 +289,62 @@ var cfg Configuration = Configuration{
 		LongPressTime: 2,
 	},
 	DMX: dmxConfig{
+		Input:        "i2c",
 		SlaveAddress: 0x04,
+		StartAddress: 1,
+		Merge:        "htp",
+		Personality:  "default",
+		Failsafe: failsafeConfig{
+			Timeout:  5,
+			Mode:     "hold",
+			FadeTime: 3,
+			Show:     failsafeShow{WhiteDots: true},
+		},
+	},
+	MIDI: midiConfig{
+		Name:   "fftwave",
+		Device: "/dev/snd/midiC1D0",
 	},
 	Lyrics: lyricsOverlayConfig{
 		RefreshRate: 30,
 		SqlitePath:  "./lyricsoverlay/lyrics.sqlite",
 	},
+	Capture: captureConfig{
+		Format:    "gif",
+		Path:      "./captures",
+		Duration:  10,
+		Scale:     4,
+		FrameRate: 30,
+	},
+	Local: localOutputConfig{
+		Matrix: true,
+		SharedMemory: sharedMemoryConfig{
+			Slots: 4,
+			Scale: 1,
+		},
+	},
+	Preview: previewConfig{
+		Scale:     4,
+		FrameRate: 15,
+		Quality:   80,
+	},
+	Calibration: colorCalibrationConfig{
+		Gamma: 1,
+		WhiteBalance: whiteBalanceConfig{
+			Red:   1,
+			Green: 1,
+			Blue:  1,
+		},
+	},
+	Power: powerLimitConfig{
+		ChannelCurrent: channelCurrentConfig{
+			Red:   0.65,
+			Green: 0.65,
+			Blue:  0.65,
+		},
+		Gamma:   2.2,
+		Release: 1,
+	},
 }
 
 func loadConfig(cfg *Configuration, path string) error {
@@ -157,6 +365,123 @@ func loadConfig(cfg *Configuration, path string) error {
 	return nil
 }
 
+// saveConfigValue replaces the value under the keys in the config file keeping the rest of the file with its comments
+// the missing keys are added at the end of their mappings
+func saveConfigValue(path string, value interface{}, keys ...string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error opening the config file: %v", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("error parsing the config file: %v", err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+
+	node := doc.Content[0]
+	for _, key := range keys {
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("error saving %s to the config file: not a mapping", key)
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, next)
+		}
+		node = next
+	}
+
+	var v yaml.Node
+	if err := v.Encode(value); err != nil {
+		return err
+	}
+	// The new items of a list take the style of the old ones so the flow style lists stay on a line per item
+	if v.Kind == yaml.SequenceNode && node.Kind == yaml.SequenceNode && len(node.Content) > 0 {
+		for _, item := range v.Content {
+			item.Style = node.Content[0].Style
+		}
+	}
+	v.HeadComment, v.LineComment, v.FootComment = node.HeadComment, node.LineComment, node.FootComment
+	*node = v
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	// The file is replaced at once so that a failure never leaves half of the config behind
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// modeParamsConfig is the part of the configuration that can be reloaded at runtime
+type modeParamsConfig struct {
+	SoundEnergy soundEnergyConfig `yaml:"soundEnergyConfig"`
+	Waves       modes.Config      `yaml:"waveConfig"`
+	Backgrounds modes.Config      `yaml:"backgroundConfig"`
+	WavePresets []modes.Preset    `yaml:"wavePresets"`
+	BgPresets   []modes.Preset    `yaml:"backgroundPresets"`
+}
+
+// loadModeParams reads the wave and background parameters and presets from the config file again and applies them
+// the parameters and the sound energy settings missing from the file go back to their defaults
+func loadModeParams(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening the config file: %v", err)
+	}
+	defer f.Close()
+
+	mp := modeParamsConfig{SoundEnergy: defaultSoundEnergy}
+	err = yaml.NewDecoder(f).Decode(&mp)
+	if err != nil {
+		return fmt.Errorf("error parsing the config file: %v", err)
+	}
+
+	err = drawloops.CheckPresets(mp.WavePresets)
+	if err != nil {
+		return err
+	}
+	err = backgroundloops.CheckPresets(mp.BgPresets)
+	if err != nil {
+		return err
+	}
+
+	err = drawloops.LoadParams(mp.Waves, mp.WavePresets)
+	if err != nil {
+		return err
+	}
+	return backgroundloops.LoadParams(backgroundSharedParams(mp.SoundEnergy), mp.Backgrounds, mp.BgPresets)
+}
+
+// backgroundSharedParams returns the sound energy settings as the default parameters for every background using them
+func backgroundSharedParams(se soundEnergyConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"saturation":  float64(se.Saturation) / 100,
+		"hueRotation": se.HueTime,
+	}
+}
+
 // createMatrixConfig converts the internal matrix config (with the yaml mappings) to the rgbmatrix.HardwareConfig format
 func createMatrixConfig(cfg *Configuration) {
 	cfg.Matrix = &rgbmatrix.DefaultConfig
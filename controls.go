@@ -0,0 +1,55 @@
+package main
+
+import (
+	"math"
+
+	"github.com/TFK1410/go-rpi-fftwave/backgroundloops"
+	"github.com/TFK1410/go-rpi-fftwave/dmx"
+	"github.com/TFK1410/go-rpi-fftwave/drawloops"
+)
+
+// maxBrightness is the highest matrix brightness set by the encoder and the control inputs
+const maxBrightness = 70
+
+// controls set the look from the OSC and MIDI inputs on the DMX state the same way the DMX input sets it
+type controls struct {
+	state       *dmx.State
+	waves       []string
+	backgrounds []string
+	// brightness passes the latest brightness on to the main loop which owns the matrix
+	brightness chan int
+}
+
+func newControls(state *dmx.State, brightness chan int) (*controls, error) {
+	waves, err := drawloops.ListWaves(cfg.WavePresets, cfg.Layouts)
+	if err != nil {
+		return nil, err
+	}
+	backgrounds, err := backgroundloops.ListBackgroundLoops(cfg.BgPresets)
+	if err != nil {
+		return nil, err
+	}
+	return &controls{
+		state:       state,
+		waves:       modeNames(waves),
+		backgrounds: modeNames(backgrounds),
+		brightness:  brightness,
+	}, nil
+}
+
+// setBrightness passes the brightness in percent on to the main loop limited the same way as the encoder
+// the main loop only needs the latest value so a pending one is replaced and the inputs never block
+func (c *controls) setBrightness(percent float64) {
+	bright := int(math.Round(math.Max(0, math.Min(percent, maxBrightness))))
+	for {
+		select {
+		case c.brightness <- bright:
+			return
+		default:
+		}
+		select {
+		case <-c.brightness:
+		default:
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package dmx
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/TFK1410/go-rpi-fftwave/dmxnet"
+)
+
+// ArtNetReceiver reads the DMX channels from the ArtDmx packets of a single universe
+type ArtNetReceiver struct {
+	conn     net.PacketConn
+	universe int
+	// start is the index of the first channel in the universe data
+	start       int
+	personality *Personality
+	channels    []byte
+}
+
+// NewArtNetReceiver listens for the Art-Net packets on the address, the port defaults to the Art-Net one
+// the start address is the DMX address of the first channel of the personality from 1 to 512
+func NewArtNetReceiver(address string, universe, startAddress int, p *Personality) (*ArtNetReceiver, error) {
+	if universe < 0 || universe > 0x7fff {
+		return nil, fmt.Errorf("artnet: universe %d is out of the range 0..32767", universe)
+	}
+	if startAddress < 1 || startAddress+p.Footprint()-1 > 512 {
+		return nil, fmt.Errorf("artnet: start address %d leaves no room for the %d channels", startAddress, p.Footprint())
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, fmt.Sprint(dmxnet.ArtNetPort))
+	}
+	conn, err := net.ListenPacket("udp4", address)
+	if err != nil {
+		return nil, err
+	}
+	return &ArtNetReceiver{
+		conn:        conn,
+		universe:    universe,
+		start:       startAddress - 1,
+		personality: p,
+		channels:    make([]byte, p.Footprint()),
+	}, nil
+}
+
+// Addr returns the address the receiver listens on
+func (r *ArtNetReceiver) Addr() net.Addr {
+	return r.conn.LocalAddr()
+}
+
+// Run sets the state from the received packets the same way InitDMX does it with the I2C bridge
+func (r *ArtNetReceiver) Run(state *State, wg *sync.WaitGroup, quit, pause, play <-chan struct{}) {
+	runReader("Art-Net", r.conn, r.handle, r.personality, state, wg, quit, pause, play)
+}
+
+func (r *ArtNetReceiver) handle(packet []byte, now time.Time) []byte {
+	p, err := dmxnet.ParseArtDmx(packet)
+	if err == dmxnet.ErrNotArtDmx {
+		return nil
+	}
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	if p.Universe != r.universe {
+		return nil
+	}
+	window(r.channels, p.Data, r.start)
+	return r.channels
+}
@@ -0,0 +1,68 @@
+package dmx
+
+import (
+	"image/color"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TFK1410/go-rpi-fftwave/dmxnet"
+)
+
+func TestArtNetReceiver(t *testing.T) {
+	r, err := NewArtNetReceiver("127.0.0.1:0", 3, 10, &DefaultPersonality)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := NewState()
+	changes, unsubscribe := state.Subscribe(FieldLyricsDMXInfo)
+	defer unsubscribe()
+	var wg sync.WaitGroup
+	quit, play := make(chan struct{}), make(chan struct{})
+	wg.Add(1)
+	go r.Run(state, &wg, quit, nil, play)
+	play <- struct{}{}
+
+	conn, err := net.Dial("udp", r.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	channels := make([]byte, 21)
+	// Display mode 2, background mode 3 and no white dots
+	channels[9] = 0x80 | 3<<4 | 2
+	channels[10] = 5 << 2
+	// Full red at the half brightness
+	copy(channels[13:], []byte{255, 0, 0, 128})
+	copy(channels[17:], []byte{0, 0, 1, 42})
+
+	// A packet of another universe is skipped
+	other, _ := dmxnet.AppendArtDmx(nil, 1, 4, make([]byte, 21))
+	packet, _ := dmxnet.AppendArtDmx(nil, 2, 3, channels)
+	conn.Write(other)
+	conn.Write(packet)
+
+	// The change of the lyrics info comes with the rest of the data
+	var data DMXData
+	select {
+	case c := <-changes:
+		data = c.Data
+		if data.LyricsDMXInfo != 1<<8|42 {
+			t.Errorf("lyrics info mismatch. Want: %v, Have: %v\n", 1<<8|42, data.LyricsDMXInfo)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no data received")
+	}
+	close(quit)
+	wg.Wait()
+
+	if data.DisplayMode != 2 || data.BackgroundMode != 3 || data.WhiteDots || data.ColorPalette != 5 {
+		t.Errorf("modes mismatch. Want: %v %v %v %v, Have: %v %v %v %v\n", 2, 3, false, 5, data.DisplayMode, data.BackgroundMode, data.WhiteDots, data.ColorPalette)
+	}
+	if data.Color != (color.RGBA{128, 0, 0, 255}) {
+		t.Errorf("color mismatch. Want: %v, Have: %v\n", color.RGBA{128, 0, 0, 255}, data.Color)
+	}
+}
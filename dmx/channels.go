@@ -0,0 +1,112 @@
+package dmx
+
+import (
+	"math"
+	"time"
+
+	"github.com/TFK1410/go-rpi-fftwave/palette"
+)
+
+// channelDecoder sets the state from the values of the DMX channels
+type channelDecoder struct {
+	personality       *Personality
+	state             *State
+	incomingLyricData uint
+}
+
+// apply decodes the channel values of the personality footprint
+func (d *channelDecoder) apply(channels []byte) {
+	var data DMXData
+
+	// The parameters missing from the personality keep the values of a reset
+	whiteDots, dimmer := true, 1.0
+	var rgb [3]float64
+	var displayMode, backgroundMode, colorPalette, angle, phase int
+	var lyricID, lyricProgress int
+	lyricGuard := false
+
+	for i := range d.personality.Channels {
+		m := &d.personality.Channels[i]
+		v, max := m.Value(channels)
+		// The continuous parameters are scaled to the full range of their fields
+		f := 0.0
+		if max > 0 {
+			f = math.Min(float64(v)/float64(max), 1)
+		}
+		switch m.Parameter {
+		case ParamDisplayMode:
+			displayMode = v
+		case ParamBackgroundMode:
+			backgroundMode = v
+		case ParamWhiteDots:
+			whiteDots = v != 0
+		case ParamColorPalette:
+			colorPalette = v
+		case ParamPaletteAngle:
+			angle = int(math.Round(f * 255))
+		case ParamPalettePhaseOffset:
+			phase = int(math.Round(f * 255))
+		case ParamRed:
+			rgb[0] = f * 255
+		case ParamGreen:
+			rgb[1] = f * 255
+		case ParamBlue:
+			rgb[2] = f * 255
+		case ParamDimmer:
+			dimmer = f
+		case ParamLyricID:
+			lyricID = v
+			// The bridge passes the channels one by one so a changing ID can show up with its lower bytes
+			// still at zero, such a partial update is skipped
+			last := channels[m.Channels[len(m.Channels)-1]-1]
+			for _, ch := range m.Channels[:len(m.Channels)-1] {
+				if channels[ch-1] > 0 && last == 0 {
+					lyricGuard = true
+				}
+			}
+		case ParamLyricProgress:
+			lyricProgress = v
+		}
+	}
+
+	data.DisplayMode = clampByte(displayMode)
+	data.BackgroundMode = clampByte(backgroundMode)
+	data.WhiteDots = whiteDots
+	// A palette past the end of the list would make the waves index out of range
+	if colorPalette >= len(palette.Palettes) {
+		colorPalette = 0
+	}
+	data.ColorPalette = byte(colorPalette)
+	data.PaletteAngle = clampByte(angle)
+	data.PalettePhaseOffset = clampByte(phase)
+
+	data.Color.R = uint8(math.Round(rgb[0] * dimmer))
+	data.Color.G = uint8(math.Round(rgb[1] * dimmer))
+	data.Color.B = uint8(math.Round(rgb[2] * dimmer))
+
+	if data.Color.R > 0 || data.Color.G > 0 || data.Color.B > 0 {
+		data.Color.A = 255
+	} else {
+		data.Color.A = 0
+	}
+
+	// lyricID bytes + 1 byte lyricProgress
+	if !lyricGuard {
+		d.incomingLyricData = uint(lyricID)<<8 + uint(lyricProgress&0xff)
+	}
+
+	data.LyricsDMXInfo = d.incomingLyricData
+
+	// All of the fields change at once so the consumers never see a half decoded packet
+	d.state.Received(data, time.Now())
+}
+
+func clampByte(v int) byte {
+	if v > 255 {
+		return 255
+	}
+	if v < 0 {
+		return 0
+	}
+	return byte(v)
+}
@@ -3,8 +3,8 @@ package dmx
 import (
 	"image/color"
 	"log"
-	"math"
 	"sync"
+	"time"
 
 	"periph.io/x/conn/v3/i2c"
 	"periph.io/x/conn/v3/i2c/i2creg"
@@ -22,7 +22,17 @@ type DMXData struct {
 	LyricsDMXInfo      uint
 }
 
-func InitDMX(slaveAddress byte, data *DMXData, lyricsDMXInfo chan<- uint, wg *sync.WaitGroup, quit, pause, play <-chan struct{}) {
+// The retry delays after the I2C errors, doubled with every error in a row
+const (
+	minRetryDelay = 10 * time.Millisecond
+	maxRetryDelay = 2 * time.Second
+)
+
+// i2cReportInterval is how often the I2C errors are summed up in the log
+const i2cReportInterval = time.Minute
+
+// InitDMX reads the DMX channels from the Arduino bridge on the I2C bus, the personality has to pass CheckI2CPersonality
+func InitDMX(slaveAddress byte, p *Personality, state *State, wg *sync.WaitGroup, quit, pause, play <-chan struct{}) {
 	defer wg.Done()
 
 	// Initialize the I2C communication using the periph package
@@ -32,10 +42,9 @@ func InitDMX(slaveAddress byte, data *DMXData, lyricsDMXInfo chan<- uint, wg *sy
 	}
 	defer bus.Close()
 
-	dev := i2c.Dev{Bus: bus, Addr: uint16(slaveAddress)}
-	bytes := make([]byte, 13)
-
-	data.WhiteDots = true
+	reader := newI2CReader(&i2c.Dev{Bus: bus, Addr: uint16(slaveAddress)}, p.Footprint())
+	report := time.NewTicker(i2cReportInterval)
+	defer report.Stop()
 
 	// Wait for the first signal to start the goroutine
 	select {
@@ -46,52 +55,38 @@ func InitDMX(slaveAddress byte, data *DMXData, lyricsDMXInfo chan<- uint, wg *sy
 		return
 	}
 
-	var incomingLyricData uint
+	dec := channelDecoder{personality: p, state: state}
+	var retryDelay time.Duration
 
 	for {
-		// Listen in on the I2CBus with the specified slave address, Tx is called with empty tx buffer to just receive
-		err = dev.Tx([]byte{}, bytes)
+		// Ask the bridge for the channels of the personality and read its frame back
+		err := reader.read(&dec, time.Now())
 		if err != nil {
-			log.Println(err)
-			continue
-		}
-
-		// if the first byte is not zero then new dmx data is being registered from the next 12 bytes
-		// this is closely coupled with the Arduino sketch that's bundled with this code
-		if len(bytes) > 0 && bytes[0] > 0 {
-
-			data.DisplayMode = bytes[1] & 0x7            //xxxxx000
-			data.BackgroundMode = (bytes[1] & 0x70) >> 4 //x000xxxx
-			data.WhiteDots = (bytes[1] & 0x80) == 0
-			data.ColorPalette = bytes[2] >> 2
-			data.PaletteAngle = bytes[3]
-			data.PalettePhaseOffset = bytes[4]
-
-			brightness := float64(bytes[8]) / 255.0
-			data.Color.R = uint8(math.Round(float64(bytes[5]) * brightness))
-			data.Color.G = uint8(math.Round(float64(bytes[6]) * brightness))
-			data.Color.B = uint8(math.Round(float64(bytes[7]) * brightness))
-
-			if data.Color.R > 0 || data.Color.G > 0 || data.Color.B > 0 {
-				data.Color.A = 255
-			} else {
-				data.Color.A = 0
-			}
-
-			// 3 bytes lyricID + 1 byte lyricProgress
-			// if checks if the bytes from the MSB and below are not zeros
-			if !((bytes[9] > 0 && bytes[10] == 0 && bytes[11] == 0) || (bytes[10] > 0 && bytes[11] == 0)) {
-				incomingLyricData = uint(bytes[9])<<24 + uint(bytes[10])<<16 + uint(bytes[11])<<8 + uint(bytes[12])
-			}
-
-			if incomingLyricData != data.LyricsDMXInfo {
-				data.LyricsDMXInfo = incomingLyricData
-				// Send the new data to the lyrics goroutine without blocking
-				select {
-				case lyricsDMXInfo <- incomingLyricData:
-				default:
+			// Back off so a missing bridge or one sending corrupted frames doesn't keep the CPU busy and the log full,
+			// the failsafe takes over meanwhile
+			if retryDelay == 0 {
+				log.Printf("%v - %d bus errors and %d bad frames so far\n", err, reader.stats.busErrors, reader.stats.frameErrors)
+				retryDelay = minRetryDelay
+			} else if retryDelay < maxRetryDelay {
+				retryDelay *= 2
+				if retryDelay >= maxRetryDelay {
+					retryDelay = maxRetryDelay
+					log.Println(err, "- retrying every", maxRetryDelay)
 				}
 			}
+			select {
+			case <-time.After(retryDelay):
+			case <-report.C:
+				reader.report()
+			case <-quit:
+				log.Println("Stopping dmx reader thread")
+				return
+			}
+			continue
+		}
+		if retryDelay > 0 {
+			log.Println("I2C bridge is responding again")
+			retryDelay = 0
 		}
 
 		// Enable pausing of the reader goroutine
@@ -104,6 +99,8 @@ func InitDMX(slaveAddress byte, data *DMXData, lyricsDMXInfo chan<- uint, wg *sy
 		// 		log.Println("Stopping dmx reader thread")
 		// 		return
 		// 	}
+		case <-report.C:
+			reader.report()
 		case <-quit:
 			// Close the goroutine letting the defers trigger
 			log.Println("Stopping dmx reader thread")
@@ -0,0 +1,188 @@
+package dmx
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/TFK1410/go-rpi-fftwave/dmxnet"
+)
+
+// sourceTimeout is the E1.31 network data loss timeout after which a silent source stops taking part in the merge
+const sourceTimeout = 2500 * time.Millisecond
+
+// Merge modes of the channels sent by multiple sources with the same priority
+const (
+	// MergeHTP takes the highest value of every channel
+	MergeHTP = "htp"
+	// MergeLTP takes the channels of the latest packet
+	MergeLTP = "ltp"
+)
+
+// e131Source is the state of a single sender of the universe
+type e131Source struct {
+	name     string
+	priority int
+	sequence byte
+	seen     time.Time
+	channels []byte
+}
+
+// E131Receiver reads the DMX channels of a single universe merged from all of the E1.31 sources
+type E131Receiver struct {
+	conn        net.PacketConn
+	universe    int
+	start       int
+	merge       string
+	personality *Personality
+	sources     map[dmxnet.CID]*e131Source
+	channels    []byte
+}
+
+// NewE131Receiver listens for the E1.31 packets of the universe, without the address the multicast group
+// of the universe is joined on the default interface, the unicast packets are received in both cases
+// the start address is the DMX address of the first channel of the personality from 1 to 512
+func NewE131Receiver(address string, universe, startAddress int, merge string, p *Personality) (*E131Receiver, error) {
+	if universe < 1 || universe > 63999 {
+		return nil, fmt.Errorf("e131: universe %d is out of the range 1..63999", universe)
+	}
+	if startAddress < 1 || startAddress+p.Footprint()-1 > 512 {
+		return nil, fmt.Errorf("e131: start address %d leaves no room for the %d channels", startAddress, p.Footprint())
+	}
+	if merge != MergeHTP && merge != MergeLTP {
+		return nil, fmt.Errorf("e131: unknown merge mode %q", merge)
+	}
+
+	var conn net.PacketConn
+	var err error
+	if address == "" {
+		conn, err = net.ListenMulticastUDP("udp4", nil, &net.UDPAddr{IP: dmxnet.E131Multicast(universe), Port: dmxnet.E131Port})
+	} else {
+		if _, _, serr := net.SplitHostPort(address); serr != nil {
+			address = net.JoinHostPort(address, fmt.Sprint(dmxnet.E131Port))
+		}
+		conn, err = net.ListenPacket("udp4", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newE131Receiver(conn, universe, startAddress, merge, p), nil
+}
+
+func newE131Receiver(conn net.PacketConn, universe, startAddress int, merge string, p *Personality) *E131Receiver {
+	return &E131Receiver{
+		conn:        conn,
+		universe:    universe,
+		start:       startAddress - 1,
+		merge:       merge,
+		personality: p,
+		sources:     make(map[dmxnet.CID]*e131Source),
+		channels:    make([]byte, p.Footprint()),
+	}
+}
+
+// Addr returns the address the receiver listens on
+func (r *E131Receiver) Addr() net.Addr {
+	return r.conn.LocalAddr()
+}
+
+// Run sets the state from the merged channels the same way InitDMX does it with the I2C bridge
+func (r *E131Receiver) Run(state *State, wg *sync.WaitGroup, quit, pause, play <-chan struct{}) {
+	runReader("E1.31", r.conn, r.handle, r.personality, state, wg, quit, pause, play)
+}
+
+func (r *E131Receiver) handle(packet []byte, now time.Time) []byte {
+	p, err := dmxnet.ParseE131Data(packet)
+	if err == dmxnet.ErrNotE131Data {
+		return nil
+	}
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	// Only the DMX levels with the zero start code are used and the preview data is meant for the visualizers only
+	if p.Universe != r.universe || p.StartCode != 0 || p.Options&dmxnet.E131OptionPreview != 0 {
+		return nil
+	}
+	if !r.update(p, now) {
+		return nil
+	}
+	return r.merged(now)
+}
+
+// update stores the packet data of its source, it returns false for the discarded packets
+func (r *E131Receiver) update(p dmxnet.E131Data, now time.Time) bool {
+	s, ok := r.sources[p.CID]
+	if p.Options&dmxnet.E131OptionTerminated != 0 {
+		if ok {
+			log.Println("E1.31 source", s.name, "terminated its stream")
+			delete(r.sources, p.CID)
+		}
+		return ok
+	}
+
+	if !ok {
+		s = &e131Source{name: p.SourceName, channels: make([]byte, len(r.channels))}
+		r.sources[p.CID] = s
+		log.Println("New E1.31 source", s.name, "with priority", p.Priority)
+	} else if diff := int8(p.Sequence - s.sequence); diff <= 0 && diff > -20 {
+		// Out of order packet, the large jumps back are taken as a restarted source
+		return false
+	}
+
+	s.priority = p.Priority
+	s.sequence = p.Sequence
+	s.seen = now
+	window(s.channels, p.Data, r.start)
+	return true
+}
+
+// merged returns the channels merged from the sources with the highest priority
+// or nil when no source is left
+func (r *E131Receiver) merged(now time.Time) []byte {
+	top := -1
+	for cid, s := range r.sources {
+		if now.Sub(s.seen) > sourceTimeout {
+			log.Println("E1.31 source", s.name, "timed out")
+			delete(r.sources, cid)
+			continue
+		}
+		if s.priority > top {
+			top = s.priority
+		}
+	}
+	if top < 0 {
+		return nil
+	}
+
+	// HTP takes the highest value of every channel and LTP the channels of the source with the latest packet
+	var latest *e131Source
+	first := true
+	for _, s := range r.sources {
+		if s.priority != top {
+			continue
+		}
+		if r.merge == MergeLTP {
+			if latest == nil || s.seen.After(latest.seen) {
+				latest = s
+			}
+			continue
+		}
+		if first {
+			copy(r.channels, s.channels)
+			first = false
+			continue
+		}
+		for i, v := range s.channels {
+			if v > r.channels[i] {
+				r.channels[i] = v
+			}
+		}
+	}
+	if latest != nil {
+		copy(r.channels, latest.channels)
+	}
+	return r.channels
+}
@@ -0,0 +1,75 @@
+package dmx
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/TFK1410/go-rpi-fftwave/dmxnet"
+)
+
+// e131Packet builds a packet of universe 1 with the channel values from the start address 1
+func e131Packet(t *testing.T, src dmxnet.E131Source, sequence, options byte, channels ...byte) []byte {
+	packet, err := src.AppendData(nil, sequence, 1, channels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet[112] = options
+	return packet
+}
+
+func TestE131Merge(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r := newE131Receiver(conn, 1, 1, MergeHTP, &DefaultPersonality)
+
+	a := dmxnet.E131Source{CID: dmxnet.NewCID("a"), Name: "a", Priority: 100}
+	b := dmxnet.E131Source{CID: dmxnet.NewCID("b"), Name: "b", Priority: 100}
+	now := time.Date(2021, 4, 4, 12, 0, 0, 0, time.UTC)
+	step := func(packet []byte) []byte {
+		now = now.Add(10 * time.Millisecond)
+		if channels := r.handle(packet, now); channels != nil {
+			return append([]byte(nil), channels[:3]...)
+		}
+		return nil
+	}
+
+	for _, tc := range []struct {
+		name   string
+		packet []byte
+		want   []byte
+	}{
+		{"first source", e131Packet(t, a, 10, 0, 10, 200, 0), []byte{10, 200, 0}},
+		{"HTP of both sources", e131Packet(t, b, 255, 0, 50, 20, 0), []byte{50, 200, 0}},
+		{"out of order packet", e131Packet(t, a, 9, 0, 255, 255, 255), nil},
+		{"sequence wrap", e131Packet(t, b, 0, 0, 60, 20, 0), []byte{60, 200, 0}},
+		{"stream terminated", e131Packet(t, b, 1, dmxnet.E131OptionTerminated), []byte{10, 200, 0}},
+		{"preview data", e131Packet(t, b, 2, dmxnet.E131OptionPreview, 99, 99, 99), nil},
+	} {
+		if have := step(tc.packet); !bytes.Equal(have, tc.want) {
+			t.Errorf("%s mismatch. Want: %v, Have: %v\n", tc.name, tc.want, have)
+		}
+	}
+
+	// A higher priority source takes over and the lower one is back after the higher one times out
+	b.Priority = 150
+	if have := step(e131Packet(t, b, 3, 0, 1, 2, 3)); !bytes.Equal(have, []byte{1, 2, 3}) {
+		t.Errorf("priority mismatch. Want: %v, Have: %v\n", []byte{1, 2, 3}, have)
+	}
+	now = now.Add(sourceTimeout)
+	if have := step(e131Packet(t, a, 11, 0, 10, 100, 0)); !bytes.Equal(have, []byte{10, 100, 0}) {
+		t.Errorf("timeout mismatch. Want: %v, Have: %v\n", []byte{10, 100, 0}, have)
+	}
+
+	// LTP takes the latest packet of the sources with the same priority
+	r.merge = MergeLTP
+	b.Priority = 100
+	step(e131Packet(t, b, 4, 0, 5, 5, 5))
+	if have := step(e131Packet(t, a, 12, 0, 1, 1, 1)); !bytes.Equal(have, []byte{1, 1, 1}) {
+		t.Errorf("LTP mismatch. Want: %v, Have: %v\n", []byte{1, 1, 1}, have)
+	}
+}
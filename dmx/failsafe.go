@@ -0,0 +1,176 @@
+package dmx
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// Failsafe modes applied when the DMX signal is lost
+const (
+	// FailsafeHold keeps the last look
+	FailsafeHold = "hold"
+	// FailsafeFade fades the colors out and switches to the defaults at the end of the fade
+	FailsafeFade = "fade"
+	// FailsafeShow plays the recorded standalone show in a loop or switches to the standalone look without one
+	FailsafeShow = "show"
+)
+
+// failsafeTick is how often the failsafe checks the signal and steps the fade
+const failsafeTick = 50 * time.Millisecond
+
+// FailsafeConfig describes what happens when no fresh DMX data comes for the timeout
+type FailsafeConfig struct {
+	Timeout  time.Duration
+	Mode     string
+	FadeTime time.Duration
+	// Show is the look of the show mode without a recorded show
+	Show DMXData
+	// Recorded is the show played by the show mode, it starts over at its end
+	Recorded *Show
+}
+
+// Failsafe watches the state for the signal loss
+type Failsafe struct {
+	state   *State
+	cfg     FailsafeConfig
+	started time.Time
+	// engaged is set from the signal loss until the signal comes back
+	engaged bool
+	// from and since are the look at the signal loss and the time the failsafe took over
+	from    DMXData
+	since   time.Time
+	fading  bool
+	playing bool
+}
+
+// NewFailsafe checks the config, with no data since the start the timeout is counted from the start
+// so the show mode also covers a missing console
+func NewFailsafe(state *State, cfg FailsafeConfig) (*Failsafe, error) {
+	return newFailsafe(state, cfg, time.Now())
+}
+
+func newFailsafe(state *State, cfg FailsafeConfig, now time.Time) (*Failsafe, error) {
+	switch cfg.Mode {
+	case FailsafeHold, FailsafeFade, FailsafeShow:
+	default:
+		return nil, fmt.Errorf("dmx: unknown failsafe mode %q", cfg.Mode)
+	}
+	return &Failsafe{state: state, cfg: cfg, started: now}, nil
+}
+
+// Run watches the DMX signal until the quit signal
+func (f *Failsafe) Run(wg *sync.WaitGroup, quit <-chan struct{}) {
+	defer wg.Done()
+	ticker := time.NewTicker(failsafeTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			f.step(now)
+		case <-quit:
+			log.Println("Stopping DMX failsafe thread")
+			return
+		}
+	}
+}
+
+func (f *Failsafe) step(now time.Time) {
+	if f.engaged {
+		if f.state.Present() {
+			log.Println("DMX signal is back")
+			f.engaged = false
+			f.fading = false
+			f.playing = false
+			return
+		}
+		// The control inputs win over the failsafe, their look is kept until the DMX signal comes back
+		if (f.fading || f.playing) && f.state.lastControlled().After(f.since) {
+			log.Println("The control inputs took over from the DMX failsafe")
+			f.fading = false
+			f.playing = false
+		}
+		if f.fading {
+			f.fade(now)
+		}
+		if f.playing {
+			f.play(now)
+		}
+		return
+	}
+
+	ref := f.state.lastReceived()
+	if ref.IsZero() {
+		ref = f.started
+	}
+	if f.cfg.Timeout <= 0 || now.Sub(ref) < f.cfg.Timeout || !f.state.lost(ref) {
+		return
+	}
+
+	log.Printf("No DMX data for %v, failsafe mode %s\n", now.Sub(ref).Round(time.Millisecond), f.cfg.Mode)
+	f.engaged = true
+	f.since = now
+	if f.state.lastControlled().After(ref) {
+		log.Println("The look of the control inputs is kept")
+		return
+	}
+	switch f.cfg.Mode {
+	case FailsafeFade:
+		f.from = f.state.Snapshot()
+		f.fading = true
+		f.fade(now)
+	case FailsafeShow:
+		if f.cfg.Recorded != nil {
+			f.playing = true
+			f.play(now)
+		} else {
+			f.state.setAbsent(f.cfg.Show)
+		}
+	}
+}
+
+// play sets the look of the recorded show from the time the failsafe took over, the show starts over at its end
+func (f *Failsafe) play(now time.Time) {
+	t := now.Sub(f.since)
+	if f.cfg.Recorded.Length > 0 {
+		t %= f.cfg.Recorded.Length
+	}
+	if !f.state.setAbsent(f.cfg.Recorded.At(t)) {
+		f.playing = false
+	}
+}
+
+// fade sets the look between the one at the signal loss and the defaults
+func (f *Failsafe) fade(now time.Time) {
+	t := 1.0
+	if f.cfg.FadeTime > 0 {
+		t = math.Min(float64(now.Sub(f.since))/float64(f.cfg.FadeTime), 1)
+	}
+	var to DMXData
+	ResetDMX(&to)
+	if !f.state.setAbsent(fadeData(f.from, to, t)) || t >= 1 {
+		f.fading = false
+	}
+}
+
+// fadeData blends the colors and the palette offsets, the rest of the fields switch at the end of the fade
+func fadeData(from, to DMXData, t float64) DMXData {
+	if t >= 1 {
+		return to
+	}
+	lerp := func(a, b byte) byte {
+		return byte(math.Round(float64(a) + (float64(b)-float64(a))*t))
+	}
+	d := from
+	d.PaletteAngle = lerp(from.PaletteAngle, to.PaletteAngle)
+	d.PalettePhaseOffset = lerp(from.PalettePhaseOffset, to.PalettePhaseOffset)
+	d.Color.R = lerp(from.Color.R, to.Color.R)
+	d.Color.G = lerp(from.Color.G, to.Color.G)
+	d.Color.B = lerp(from.Color.B, to.Color.B)
+	if d.Color.R == 0 && d.Color.G == 0 && d.Color.B == 0 {
+		d.Color.A = 0
+	}
+	return d
+}
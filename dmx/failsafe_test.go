@@ -0,0 +1,135 @@
+package dmx
+
+import (
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestFailsafe(t *testing.T) {
+	start := time.Date(2021, 4, 4, 12, 0, 0, 0, time.UTC)
+	look := DMXData{DisplayMode: 2, PaletteAngle: 100, Color: color.RGBA{200, 100, 0, 255}}
+	show := DMXData{DisplayMode: 5, BackgroundMode: 1, WhiteDots: true}
+
+	for _, tc := range []struct {
+		mode string
+		// want are the looks one and three seconds after the signal loss
+		want [2]DMXData
+	}{
+		{FailsafeHold, [2]DMXData{look, look}},
+		{FailsafeFade, [2]DMXData{
+			{DisplayMode: 2, PaletteAngle: 50, Color: color.RGBA{100, 50, 0, 255}},
+			{WhiteDots: true},
+		}},
+		{FailsafeShow, [2]DMXData{show, show}},
+	} {
+		s := NewState()
+		f, err := newFailsafe(s, FailsafeConfig{Timeout: 2 * time.Second, Mode: tc.mode, FadeTime: 2 * time.Second, Show: show}, start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes, unsubscribe := s.Subscribe(FieldPresent)
+
+		s.Received(look, start)
+		f.step(start.Add(time.Second))
+		if !s.Present() || (<-changes).Fields != FieldPresent {
+			t.Errorf("%s: signal not present\n", tc.mode)
+		}
+
+		// The signal is lost two seconds after the last data
+		f.step(start.Add(2 * time.Second))
+		if s.Present() {
+			t.Errorf("%s: signal still present\n", tc.mode)
+		}
+		if c := <-changes; c.Present {
+			t.Errorf("%s: loss not reported\n", tc.mode)
+		}
+		for i, at := range []time.Duration{3 * time.Second, 5 * time.Second} {
+			f.step(start.Add(at))
+			if have := s.Snapshot(); have != tc.want[i] {
+				t.Errorf("%s: look %d mismatch. Want: %v, Have: %v\n", tc.mode, i, tc.want[i], have)
+			}
+		}
+
+		// A returning signal takes over right away
+		s.Received(look, start.Add(6*time.Second))
+		f.step(start.Add(6 * time.Second))
+		if !s.Present() || s.Snapshot() != look {
+			t.Errorf("%s: signal not taken back\n", tc.mode)
+		}
+		unsubscribe()
+	}
+}
+
+func TestFailsafeWithoutSignal(t *testing.T) {
+	start := time.Date(2021, 4, 4, 12, 0, 0, 0, time.UTC)
+	show := DMXData{DisplayMode: 5, WhiteDots: true}
+	s := NewState()
+	f, err := newFailsafe(s, FailsafeConfig{Timeout: time.Second, Mode: FailsafeShow, Show: show}, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The show starts the timeout after the start when no console is sending
+	f.step(start.Add(500 * time.Millisecond))
+	if s.Snapshot().DisplayMode != 0 {
+		t.Errorf("show started early\n")
+	}
+	f.step(start.Add(time.Second))
+	if have := s.Snapshot(); have != show {
+		t.Errorf("show mismatch. Want: %v, Have: %v\n", show, have)
+	}
+
+	// The recorded show plays from the start of the failsafe and starts over at its end
+	recorded := &Show{Steps: []ShowStep{{0, DMXData{DisplayMode: 1}}, {time.Second, DMXData{DisplayMode: 2}}}, Length: 2 * time.Second}
+	s = NewState()
+	f, err = newFailsafe(s, FailsafeConfig{Timeout: time.Second, Mode: FailsafeShow, Show: show, Recorded: recorded}, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []struct {
+		at   time.Duration
+		want byte
+	}{{time.Second, 1}, {2500 * time.Millisecond, 2}, {3500 * time.Millisecond, 1}} {
+		f.step(start.Add(step.at))
+		if have := s.Snapshot().DisplayMode; have != step.want {
+			t.Errorf("recorded show at %v mismatch. Want: %v, Have: %v\n", step.at, step.want, have)
+		}
+	}
+
+	if _, err := newFailsafe(s, FailsafeConfig{Mode: "blackout"}, start); err == nil {
+		t.Errorf("unknown mode was accepted\n")
+	}
+}
+
+func TestFailsafeControls(t *testing.T) {
+	recorded := &Show{Steps: []ShowStep{{0, DMXData{DisplayMode: 1}}, {time.Second, DMXData{DisplayMode: 2}}}, Length: 2 * time.Second}
+	cfg := FailsafeConfig{Timeout: time.Second, Mode: FailsafeShow, Recorded: recorded}
+
+	// The control inputs take over from the playing show, the control changes are timed by the wall clock
+	start := time.Now().Add(-10 * time.Second)
+	s := NewState()
+	f, err := newFailsafe(s, cfg, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.step(start.Add(time.Second))
+	s.Update(func(data *DMXData) { data.DisplayMode = 7 })
+	f.step(start.Add(2500 * time.Millisecond))
+	if have := s.Snapshot().DisplayMode; have != 7 {
+		t.Errorf("controlled look mismatch. Want: %v, Have: %v\n", 7, have)
+	}
+
+	// The show doesn't start over the look set by the control inputs
+	start = time.Now().Add(-2 * time.Second)
+	s = NewState()
+	f, err = newFailsafe(s, cfg, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Update(func(data *DMXData) { data.DisplayMode = 7 })
+	f.step(time.Now())
+	if have := s.Snapshot().DisplayMode; have != 7 {
+		t.Errorf("controlled look mismatch. Want: %v, Have: %v\n", 7, have)
+	}
+}
@@ -0,0 +1,208 @@
+package dmx
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"periph.io/x/conn/v3"
+)
+
+// The I2C protocol of the bundled Arduino bridge, every transaction writes a request and reads a frame back
+//
+// request: magic, version, channel count
+// frame:   magic, version, flags, sequence, channel count, channels..., CRC-16 high byte, CRC-16 low byte
+//
+// the bridge answers with at most the requested channel count and the rest of the read is padding,
+// the sequence goes up with every DMX update received by the bridge and the CRC-16/CCITT-FALSE covers
+// all of the frame bytes before it
+const (
+	i2cMagic     = 0xa5
+	i2cVersion   = 1
+	i2cHeaderLen = 5
+	i2cCRCLen    = 2
+	// i2cFlagSignal is set while the bridge receives the DMX signal
+	i2cFlagSignal = 0x01
+
+	// I2CMaxChannels is the largest channel count fitting a frame into the 32 byte buffer of the Arduino Wire library
+	I2CMaxChannels = 32 - i2cHeaderLen - i2cCRCLen
+)
+
+// errI2CFrame is wrapped by all of the errors of the corrupted or unexpected frames
+var errI2CFrame = errors.New("i2c: bad frame")
+
+// i2cFrame is a validated frame of the bridge
+type i2cFrame struct {
+	flags    byte
+	sequence byte
+	channels []byte
+}
+
+// parseI2CFrame validates the frame read from the bridge, the channels point into the buffer
+func parseI2CFrame(buf []byte) (i2cFrame, error) {
+	var f i2cFrame
+	if len(buf) < i2cHeaderLen+i2cCRCLen {
+		return f, fmt.Errorf("%w: %d bytes are too short", errI2CFrame, len(buf))
+	}
+	if buf[0] != i2cMagic {
+		// The original sketch sends the update flag as the first byte instead of the magic
+		if buf[0] == 0 || buf[0] == 255 {
+			return f, fmt.Errorf("%w: no magic byte, the bridge probably runs the sketch from before the protocol version 1", errI2CFrame)
+		}
+		return f, fmt.Errorf("%w: magic byte %#x", errI2CFrame, buf[0])
+	}
+	if buf[1] != i2cVersion {
+		return f, fmt.Errorf("%w: protocol version %d, the supported one is %d", errI2CFrame, buf[1], i2cVersion)
+	}
+	n := int(buf[4])
+	if i2cHeaderLen+n+i2cCRCLen > len(buf) {
+		return f, fmt.Errorf("%w: %d channels don't fit the %d bytes read", errI2CFrame, n, len(buf))
+	}
+	end := i2cHeaderLen + n
+	if want, have := crc16(buf[:end]), uint16(buf[end])<<8|uint16(buf[end+1]); want != have {
+		return f, fmt.Errorf("%w: checksum %#04x, expected %#04x", errI2CFrame, have, want)
+	}
+	f.flags = buf[2]
+	f.sequence = buf[3]
+	f.channels = buf[i2cHeaderLen:end]
+	return f, nil
+}
+
+// crc16 is the CRC-16/CCITT-FALSE of the data, the same one is computed by the sketch
+func crc16(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// i2cStats counts the transactions with the bridge since the previous report
+type i2cStats struct {
+	updates     int
+	busErrors   int
+	frameErrors int
+	// skipped are the updates of the bridge missed between two reads
+	skipped int
+}
+
+// i2cReader polls the bridge for the new channel values
+type i2cReader struct {
+	dev     conn.Conn
+	request []byte
+	buf     []byte
+	// channels are the requested channels, the ones the bridge doesn't pass on stay zero
+	channels   []byte
+	negotiated int
+	signal     bool
+	started    bool
+	sequence   byte
+	stats      i2cStats
+}
+
+func newI2CReader(dev conn.Conn, count int) *i2cReader {
+	return &i2cReader{
+		dev:        dev,
+		request:    []byte{i2cMagic, i2cVersion, byte(count)},
+		buf:        make([]byte, i2cHeaderLen+count+i2cCRCLen),
+		channels:   make([]byte, count),
+		negotiated: -1,
+	}
+}
+
+// poll reads a frame and returns the channels when the bridge received a DMX update since the previous one
+func (r *i2cReader) poll() ([]byte, error) {
+	if err := r.dev.Tx(r.request, r.buf); err != nil {
+		r.stats.busErrors++
+		return nil, err
+	}
+	f, err := parseI2CFrame(r.buf)
+	if err != nil {
+		r.stats.frameErrors++
+		return nil, err
+	}
+
+	if len(f.channels) != r.negotiated {
+		r.negotiated = len(f.channels)
+		if r.negotiated < len(r.channels) {
+			log.Println("The I2C bridge passes on", r.negotiated, "of the", len(r.channels), "channels, the rest stays at zero")
+		} else {
+			log.Println("The I2C bridge passes on", r.negotiated, "channels")
+		}
+	}
+
+	if signal := f.flags&i2cFlagSignal != 0; signal != r.signal {
+		r.signal = signal
+		if signal {
+			log.Println("The I2C bridge receives the DMX signal")
+		} else {
+			log.Println("The I2C bridge has no DMX signal")
+		}
+	}
+
+	if r.started && f.sequence == r.sequence {
+		return nil, nil
+	}
+	if r.started {
+		r.stats.skipped += int(f.sequence-r.sequence) - 1
+	}
+	first := !r.started
+	r.started = true
+	r.sequence = f.sequence
+	// The values in the bridge right after the start only count while it has the signal
+	if first && !r.signal {
+		return nil, nil
+	}
+	r.stats.updates++
+
+	n := copy(r.channels, f.channels)
+	for i := n; i < len(r.channels); i++ {
+		r.channels[i] = 0
+	}
+	return r.channels, nil
+}
+
+// CheckI2CPersonality checks that the channels of the personality fit in a frame of the bridge
+func CheckI2CPersonality(p *Personality) error {
+	if p.Footprint() > I2CMaxChannels {
+		return fmt.Errorf("i2c: personality %s needs %d channels but the bridge passes on at most %d", p.Name, p.Footprint(), I2CMaxChannels)
+	}
+	return nil
+}
+
+// read polls a frame and passes it on to the state, the sketch only sends the changes of the channels
+// so the signal flag of the bridge keeps the signal present during a static look and its drop is the signal loss
+func (r *i2cReader) read(dec *channelDecoder, now time.Time) error {
+	hadSignal := r.signal
+	channels, err := r.poll()
+	if err != nil {
+		return err
+	}
+	switch {
+	case channels != nil:
+		dec.apply(channels)
+	case r.signal:
+		dec.state.refresh(now)
+	case hadSignal:
+		dec.state.lost(now)
+	}
+	return nil
+}
+
+// report logs and resets the counters when anything went wrong since the previous report
+func (r *i2cReader) report() {
+	s := r.stats
+	r.stats = i2cStats{}
+	if s.busErrors == 0 && s.frameErrors == 0 {
+		return
+	}
+	log.Printf("I2C bridge: %d updates, %d missed, %d bus errors, %d bad frames\n", s.updates, s.skipped, s.busErrors, s.frameErrors)
+}
@@ -0,0 +1,134 @@
+package dmx
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"periph.io/x/conn/v3/i2c"
+	"periph.io/x/conn/v3/i2c/i2ctest"
+)
+
+// i2cFrameBytes builds the frame the bridge sends for a read of the count channels
+func i2cFrameBytes(flags, sequence byte, count int, channels ...byte) []byte {
+	frame := []byte{i2cMagic, i2cVersion, flags, sequence, byte(len(channels))}
+	frame = append(frame, channels...)
+	crc := crc16(frame)
+	frame = append(frame, byte(crc>>8), byte(crc))
+	// The Wire library pads the read past the written bytes
+	for len(frame) < i2cHeaderLen+count+i2cCRCLen {
+		frame = append(frame, 0xff)
+	}
+	return frame
+}
+
+func TestI2CReader(t *testing.T) {
+	request := []byte{i2cMagic, i2cVersion, 4}
+	corrupted := i2cFrameBytes(i2cFlagSignal, 3, 4, 9, 9, 9, 9)
+	corrupted[6] ^= 0x10
+
+	bus := &i2ctest.Playback{Ops: []i2ctest.IO{
+		// No signal at the start
+		{Addr: 4, W: request, R: i2cFrameBytes(0, 1, 4, 0, 0, 0, 0)},
+		{Addr: 4, W: request, R: i2cFrameBytes(i2cFlagSignal, 2, 4, 1, 2, 3, 4)},
+		// The same update read again
+		{Addr: 4, W: request, R: i2cFrameBytes(i2cFlagSignal, 2, 4, 1, 2, 3, 4)},
+		{Addr: 4, W: request, R: corrupted},
+		// Two updates were missed and the bridge only passes on 3 channels
+		{Addr: 4, W: request, R: i2cFrameBytes(i2cFlagSignal, 5, 4, 5, 6, 7)},
+		// The sketch from before the protocol version 1
+		{Addr: 4, W: request, R: []byte{255, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+	}}
+	r := newI2CReader(&i2c.Dev{Bus: bus, Addr: 4}, 4)
+
+	for i, want := range []struct {
+		channels []byte
+		badFrame bool
+	}{
+		{nil, false},
+		{[]byte{1, 2, 3, 4}, false},
+		{nil, false},
+		{nil, true},
+		{[]byte{5, 6, 7, 0}, false},
+		{nil, true},
+	} {
+		channels, err := r.poll()
+		if errors.Is(err, errI2CFrame) != want.badFrame {
+			t.Errorf("read %d error mismatch. Want bad frame: %v, Have: %v\n", i, want.badFrame, err)
+		}
+		if !bytes.Equal(channels, want.channels) {
+			t.Errorf("read %d channels mismatch. Want: %v, Have: %v\n", i, want.channels, channels)
+		}
+	}
+	if err := bus.Close(); err != nil {
+		t.Error(err)
+	}
+
+	want := i2cStats{updates: 2, frameErrors: 2, skipped: 2}
+	if r.stats != want {
+		t.Errorf("stats mismatch. Want: %+v, Have: %+v\n", want, r.stats)
+	}
+	if r.negotiated != 3 {
+		t.Errorf("negotiated channels mismatch. Want: %v, Have: %v\n", 3, r.negotiated)
+	}
+}
+
+func TestI2CStaticLook(t *testing.T) {
+	request := []byte{i2cMagic, i2cVersion, 12}
+	bus := &i2ctest.Playback{Ops: []i2ctest.IO{
+		{Addr: 4, W: request, R: i2cFrameBytes(i2cFlagSignal, 1, 12, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)},
+		// The console holds the look so the sequence doesn't change
+		{Addr: 4, W: request, R: i2cFrameBytes(i2cFlagSignal, 1, 12, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)},
+		{Addr: 4, W: request, R: i2cFrameBytes(i2cFlagSignal, 1, 12, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)},
+		// The console is turned off
+		{Addr: 4, W: request, R: i2cFrameBytes(0, 1, 12, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)},
+	}}
+	r := newI2CReader(&i2c.Dev{Bus: bus, Addr: 4}, 12)
+	s := NewState()
+	dec := channelDecoder{personality: &DefaultPersonality, state: s}
+	start := time.Now()
+	f, err := newFailsafe(s, FailsafeConfig{Timeout: time.Second, Mode: FailsafeFade}, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, at := range []time.Duration{0, 2 * time.Second, 4 * time.Second} {
+		if err := r.read(&dec, start.Add(at)); err != nil {
+			t.Fatal(err)
+		}
+		f.step(start.Add(at + 500*time.Millisecond))
+		if !s.Present() || s.Snapshot().DisplayMode != 2 {
+			t.Errorf("read %d: static look mismatch. Want: %v %v, Have: %v %v\n", i, true, 2, s.Present(), s.Snapshot().DisplayMode)
+		}
+	}
+
+	// The drop of the signal flag is the signal loss right away
+	if err := r.read(&dec, start.Add(5*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if s.Present() {
+		t.Errorf("signal still present without the signal flag\n")
+	}
+	if err := bus.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseI2CFrame(t *testing.T) {
+	// The check value of the CRC-16/CCITT-FALSE
+	if crc := crc16([]byte("123456789")); crc != 0x29b1 {
+		t.Errorf("crc mismatch. Want: %#x, Have: %#x\n", 0x29b1, crc)
+	}
+
+	for name, buf := range map[string][]byte{
+		"short":    {i2cMagic, i2cVersion, 0},
+		"version":  append([]byte{i2cMagic, 2}, i2cFrameBytes(0, 0, 0)[2:]...),
+		"length":   i2cFrameBytes(0, 0, 2, 1, 2)[:8],
+		"checksum": append(i2cFrameBytes(0, 0, 1, 1)[:6], 0, 0),
+	} {
+		if _, err := parseI2CFrame(buf); !errors.Is(err, errI2CFrame) {
+			t.Errorf("%s frame error mismatch. Want: %v, Have: %v\n", name, errI2CFrame, err)
+		}
+	}
+}
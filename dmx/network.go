@@ -0,0 +1,64 @@
+package dmx
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// readTimeout is how often the network readers check the quit channel while no packets are coming
+const readTimeout = 250 * time.Millisecond
+
+// runReader reads the packets from the connection until the quit signal and passes them to the handler
+// the handler returns the channel values to apply or nil when the packet doesn't change them
+func runReader(name string, conn net.PacketConn, handle func(packet []byte, now time.Time) []byte, p *Personality,
+	state *State, wg *sync.WaitGroup, quit, pause, play <-chan struct{}) {
+	defer wg.Done()
+	defer conn.Close()
+
+	// Wait for the first signal to start the goroutine
+	select {
+	case <-play:
+	case <-quit:
+		log.Println("Stopping", name, "reader thread")
+		return
+	}
+
+	dec := channelDecoder{personality: p, state: state}
+	buf := make([]byte, 1500)
+
+	for {
+		select {
+		case <-quit:
+			log.Println("Stopping", name, "reader thread")
+			return
+		default:
+		}
+
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			var ne net.Error
+			if !errors.As(err, &ne) || !ne.Timeout() {
+				log.Println(err)
+			}
+			continue
+		}
+		if channels := handle(buf[:n], time.Now()); channels != nil {
+			dec.apply(channels)
+		}
+	}
+}
+
+// window copies the visualizer channels from the universe data into channels
+// the channels past the end of a short packet are zero
+func window(channels, universe []byte, start int) {
+	for i := range channels {
+		channels[i] = 0
+	}
+	if start < len(universe) {
+		copy(channels, universe[start:])
+	}
+}
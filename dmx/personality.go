@@ -0,0 +1,207 @@
+package dmx
+
+import (
+	"fmt"
+)
+
+// Parameters of the visualizer controlled by the DMX channels
+const (
+	ParamDisplayMode        = "displayMode"
+	ParamBackgroundMode     = "backgroundMode"
+	ParamWhiteDots          = "whiteDots"
+	ParamColorPalette       = "colorPalette"
+	ParamPaletteAngle       = "paletteAngle"
+	ParamPalettePhaseOffset = "palettePhaseOffset"
+	ParamRed                = "red"
+	ParamGreen              = "green"
+	ParamBlue               = "blue"
+	ParamDimmer             = "dimmer"
+	ParamLyricID            = "lyricID"
+	ParamLyricProgress      = "lyricProgress"
+)
+
+// paramInfo describes how the channel values of a parameter are used
+type paramInfo struct {
+	// continuous parameters are scaled to their 8 bit range, the others take the value as it is
+	continuous  bool
+	maxChannels int
+}
+
+var params = map[string]paramInfo{
+	ParamDisplayMode:        {maxChannels: 2},
+	ParamBackgroundMode:     {maxChannels: 2},
+	ParamWhiteDots:          {maxChannels: 1},
+	ParamColorPalette:       {maxChannels: 2},
+	ParamPaletteAngle:       {continuous: true, maxChannels: 2},
+	ParamPalettePhaseOffset: {continuous: true, maxChannels: 2},
+	ParamRed:                {continuous: true, maxChannels: 2},
+	ParamGreen:              {continuous: true, maxChannels: 2},
+	ParamBlue:               {continuous: true, maxChannels: 2},
+	ParamDimmer:             {continuous: true, maxChannels: 2},
+	ParamLyricID:            {maxChannels: 3},
+	ParamLyricProgress:      {maxChannels: 1},
+}
+
+// Personality maps the DMX channels from the start address to the parameters of the visualizer
+type Personality struct {
+	Name        string           `yaml:"name"`
+	Description string           `yaml:"description,omitempty"`
+	Channels    []ChannelMapping `yaml:"channels"`
+}
+
+// ChannelMapping sets a parameter from one or more channels
+type ChannelMapping struct {
+	Parameter string `yaml:"parameter"`
+	// Channels are the offsets from the start address counted from 1, two or three channels make
+	// a 16 or 24 bit value with the first channel as the most significant one
+	Channels []int `yaml:"channels"`
+	// Mask and Shift take a bit field out of the value, a zero mask takes the whole value
+	Mask  int `yaml:"mask,omitempty"`
+	Shift int `yaml:"shift,omitempty"`
+	// Ranges map the ranges of the value to the parameter values, the values outside of every range are zero
+	Ranges []ValueRange `yaml:"ranges,omitempty"`
+}
+
+// ValueRange maps the values from From to To inclusive to the Value
+type ValueRange struct {
+	From  int `yaml:"from"`
+	To    int `yaml:"to"`
+	Value int `yaml:"value"`
+}
+
+// DefaultPersonality is the 12 channel layout passed on by the bundled Arduino sketch
+var DefaultPersonality = Personality{
+	Name:        "default",
+	Description: "12 channels of the Arduino bridge with the modes packed in the first channel",
+	Channels: []ChannelMapping{
+		{Parameter: ParamDisplayMode, Channels: []int{1}, Mask: 0x0f},
+		{Parameter: ParamBackgroundMode, Channels: []int{1}, Mask: 0x70, Shift: 4},
+		{Parameter: ParamWhiteDots, Channels: []int{1}, Mask: 0x80, Shift: 7, Ranges: []ValueRange{{From: 0, To: 0, Value: 1}}},
+		{Parameter: ParamColorPalette, Channels: []int{2}, Shift: 2},
+		{Parameter: ParamPaletteAngle, Channels: []int{3}},
+		{Parameter: ParamPalettePhaseOffset, Channels: []int{4}},
+		{Parameter: ParamRed, Channels: []int{5}},
+		{Parameter: ParamGreen, Channels: []int{6}},
+		{Parameter: ParamBlue, Channels: []int{7}},
+		{Parameter: ParamDimmer, Channels: []int{8}},
+		{Parameter: ParamLyricID, Channels: []int{9, 10, 11}},
+		{Parameter: ParamLyricProgress, Channels: []int{12}},
+	},
+}
+
+// ExtendedPersonality gives every parameter its own channels so that all of the waves and backgrounds can be selected
+var ExtendedPersonality = Personality{
+	Name:        "extended",
+	Description: "15 channels with the full mode indexes and a 16 bit dimmer",
+	Channels: []ChannelMapping{
+		{Parameter: ParamDisplayMode, Channels: []int{1}},
+		{Parameter: ParamBackgroundMode, Channels: []int{2}},
+		{Parameter: ParamWhiteDots, Channels: []int{3}, Ranges: []ValueRange{{From: 0, To: 127, Value: 1}}},
+		{Parameter: ParamColorPalette, Channels: []int{4}},
+		{Parameter: ParamPaletteAngle, Channels: []int{5}},
+		{Parameter: ParamPalettePhaseOffset, Channels: []int{6}},
+		{Parameter: ParamRed, Channels: []int{7}},
+		{Parameter: ParamGreen, Channels: []int{8}},
+		{Parameter: ParamBlue, Channels: []int{9}},
+		{Parameter: ParamDimmer, Channels: []int{10, 11}},
+		{Parameter: ParamLyricID, Channels: []int{12, 13, 14}},
+		{Parameter: ParamLyricProgress, Channels: []int{15}},
+	},
+}
+
+// FindPersonality returns the personality with the name from the configured ones or the built-in ones
+func FindPersonality(name string, personalities []Personality) (*Personality, error) {
+	for _, list := range [][]Personality{personalities, {DefaultPersonality, ExtendedPersonality}} {
+		for i := range list {
+			if list[i].Name == name {
+				p := list[i]
+				if err := p.validate(); err != nil {
+					return nil, err
+				}
+				return &p, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("dmx: unknown personality %q", name)
+}
+
+// Personalities returns the configured personalities followed by the built-in ones they don't replace
+func Personalities(personalities []Personality) ([]Personality, error) {
+	var out []Personality
+	names := make(map[string]bool)
+	for _, p := range append(append([]Personality(nil), personalities...), DefaultPersonality, ExtendedPersonality) {
+		if names[p.Name] {
+			continue
+		}
+		names[p.Name] = true
+		if err := p.validate(); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func (p *Personality) validate() error {
+	seen := make(map[string]bool)
+	for _, m := range p.Channels {
+		info, ok := params[m.Parameter]
+		if !ok {
+			return fmt.Errorf("dmx: personality %s: unknown parameter %q", p.Name, m.Parameter)
+		}
+		if seen[m.Parameter] {
+			return fmt.Errorf("dmx: personality %s: parameter %s is mapped twice", p.Name, m.Parameter)
+		}
+		seen[m.Parameter] = true
+		if len(m.Channels) < 1 || len(m.Channels) > info.maxChannels {
+			return fmt.Errorf("dmx: personality %s: parameter %s takes 1 to %d channels", p.Name, m.Parameter, info.maxChannels)
+		}
+		for _, ch := range m.Channels {
+			if ch < 1 || ch > 512 {
+				return fmt.Errorf("dmx: personality %s: channel %d of %s is out of the range 1..512", p.Name, ch, m.Parameter)
+			}
+		}
+		if m.Mask < 0 || m.Shift < 0 || m.Shift >= 8*len(m.Channels) {
+			return fmt.Errorf("dmx: personality %s: mask or shift of %s doesn't fit the channels", p.Name, m.Parameter)
+		}
+	}
+	return nil
+}
+
+// Footprint returns the number of channels used by the personality
+func (p *Personality) Footprint() int {
+	n := 0
+	for _, m := range p.Channels {
+		for _, ch := range m.Channels {
+			if ch > n {
+				n = ch
+			}
+		}
+	}
+	return n
+}
+
+// Value returns the value of the mapping from the channel values of the footprint together with its largest possible value
+func (m *ChannelMapping) Value(channels []byte) (int, int) {
+	v, max := 0, 0
+	for _, ch := range m.Channels {
+		v = v<<8 | int(channels[ch-1])
+		max = max<<8 | 0xff
+	}
+	if m.Mask != 0 {
+		v &= m.Mask
+		max &= m.Mask
+	}
+	v >>= m.Shift
+	max >>= m.Shift
+
+	if len(m.Ranges) > 0 {
+		for _, r := range m.Ranges {
+			if v >= r.From && v <= r.To {
+				return r.Value, max
+			}
+		}
+		return 0, max
+	}
+	return v, max
+}
@@ -0,0 +1,107 @@
+package dmx
+
+import (
+	"image/color"
+	"testing"
+)
+
+func decode(t *testing.T, p *Personality, channels []byte) (DMXData, uint) {
+	dec := channelDecoder{personality: p, state: NewState()}
+	dec.apply(channels)
+	data := dec.state.Snapshot()
+	return data, data.LyricsDMXInfo
+}
+
+func TestDefaultPersonality(t *testing.T) {
+	p, err := FindPersonality("default", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Footprint() != 12 {
+		t.Errorf("footprint mismatch. Want: %v, Have: %v\n", 12, p.Footprint())
+	}
+
+	data, info := decode(t, p, []byte{0x80 | 3<<4 | 2, 5 << 2, 10, 20, 255, 0, 100, 128, 0, 0, 1, 42})
+	if data.DisplayMode != 2 || data.BackgroundMode != 3 || data.WhiteDots || data.ColorPalette != 5 {
+		t.Errorf("modes mismatch. Want: %v %v %v %v, Have: %v %v %v %v\n", 2, 3, false, 5, data.DisplayMode, data.BackgroundMode, data.WhiteDots, data.ColorPalette)
+	}
+	if data.PaletteAngle != 10 || data.PalettePhaseOffset != 20 {
+		t.Errorf("palette mismatch. Want: %v %v, Have: %v %v\n", 10, 20, data.PaletteAngle, data.PalettePhaseOffset)
+	}
+	if data.Color != (color.RGBA{128, 0, 50, 255}) {
+		t.Errorf("color mismatch. Want: %v, Have: %v\n", color.RGBA{128, 0, 50, 255}, data.Color)
+	}
+	if info != 1<<8|42 {
+		t.Errorf("lyrics info mismatch. Want: %v, Have: %v\n", 1<<8|42, info)
+	}
+
+	// The palettes past the end of the list are turned off
+	data, _ = decode(t, p, []byte{0, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	if !data.WhiteDots || data.ColorPalette != 0 {
+		t.Errorf("palette mismatch. Want: %v %v, Have: %v %v\n", true, 0, data.WhiteDots, data.ColorPalette)
+	}
+
+	// The fourth bit left free by the Arduino sketch reaches the waves from 8 up
+	data, _ = decode(t, p, []byte{3<<4 | 8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	if data.DisplayMode != 8 || data.BackgroundMode != 3 {
+		t.Errorf("modes mismatch. Want: %v %v, Have: %v %v\n", 8, 3, data.DisplayMode, data.BackgroundMode)
+	}
+}
+
+func TestCustomPersonality(t *testing.T) {
+	custom := []Personality{{
+		Name: "custom",
+		Channels: []ChannelMapping{
+			{Parameter: ParamDisplayMode, Channels: []int{1}, Ranges: []ValueRange{{From: 0, To: 9, Value: 0}, {From: 10, To: 19, Value: 4}}},
+			{Parameter: ParamRed, Channels: []int{3, 4}},
+			{Parameter: ParamDimmer, Channels: []int{5, 6}},
+			{Parameter: ParamLyricID, Channels: []int{20}},
+		},
+	}}
+	p, err := FindPersonality("custom", custom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Footprint() != 20 {
+		t.Errorf("footprint mismatch. Want: %v, Have: %v\n", 20, p.Footprint())
+	}
+
+	channels := make([]byte, 20)
+	channels[0] = 15
+	// Full red at the quarter brightness of the 16 bit dimmer
+	channels[2], channels[3] = 0xff, 0xff
+	channels[4], channels[5] = 0x40, 0x00
+	channels[19] = 7
+	data, info := decode(t, p, channels)
+	if data.DisplayMode != 4 || !data.WhiteDots {
+		t.Errorf("modes mismatch. Want: %v %v, Have: %v %v\n", 4, true, data.DisplayMode, data.WhiteDots)
+	}
+	if data.Color != (color.RGBA{64, 0, 0, 255}) {
+		t.Errorf("color mismatch. Want: %v, Have: %v\n", color.RGBA{64, 0, 0, 255}, data.Color)
+	}
+	if info != 7<<8 {
+		t.Errorf("lyrics info mismatch. Want: %v, Have: %v\n", 7<<8, info)
+	}
+
+	// Values outside of the ranges map to zero
+	channels[0] = 30
+	if data, _ = decode(t, p, channels); data.DisplayMode != 0 {
+		t.Errorf("display mode mismatch. Want: %v, Have: %v\n", 0, data.DisplayMode)
+	}
+}
+
+func TestInvalidPersonality(t *testing.T) {
+	for _, p := range []Personality{
+		{Name: "unknown", Channels: []ChannelMapping{{Parameter: "strobe", Channels: []int{1}}}},
+		{Name: "twice", Channels: []ChannelMapping{{Parameter: ParamRed, Channels: []int{1}}, {Parameter: ParamRed, Channels: []int{2}}}},
+		{Name: "wide", Channels: []ChannelMapping{{Parameter: ParamWhiteDots, Channels: []int{1, 2}}}},
+		{Name: "range", Channels: []ChannelMapping{{Parameter: ParamRed, Channels: []int{513}}}},
+	} {
+		if _, err := FindPersonality(p.Name, []Personality{p}); err == nil {
+			t.Errorf("personality %s was accepted\n", p.Name)
+		}
+	}
+	if _, err := FindPersonality("missing", nil); err == nil {
+		t.Errorf("missing personality was found\n")
+	}
+}
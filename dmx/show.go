@@ -0,0 +1,256 @@
+package dmx
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The show files are JSON lines, a header line with the format name and the version followed by
+// the looks with their time in seconds from the start and a last line marking the end of the show
+const (
+	showFormat  = "fftwave-dmx"
+	showVersion = 1
+	// showRefresh is how often the player sends the current look again so the failsafe doesn't take over during long looks
+	showRefresh = time.Second
+)
+
+type showHeader struct {
+	Format   string    `json:"format"`
+	Version  int       `json:"version"`
+	Recorded time.Time `json:"recorded"`
+}
+
+type showLine struct {
+	T    float64  `json:"t"`
+	Data *DMXData `json:"data,omitempty"`
+	End  bool     `json:"end,omitempty"`
+}
+
+// ShowStep is a look of the show starting at the time from the start of the show
+type ShowStep struct {
+	At   time.Duration
+	Data DMXData
+}
+
+// Show is a recorded sequence of the looks
+type Show struct {
+	Steps []ShowStep
+	// Length is the time from the start to the end of the recording
+	Length time.Duration
+}
+
+// ReadShow reads a show recorded by the ShowRecorder
+func ReadShow(r io.Reader) (*Show, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		return nil, errors.New("show: empty file")
+	}
+	var h showHeader
+	if err := json.Unmarshal(scanner.Bytes(), &h); err != nil || h.Format != showFormat {
+		return nil, errors.New("show: not a DMX show file")
+	}
+	if h.Version != showVersion {
+		return nil, fmt.Errorf("show: version %d, the supported one is %d", h.Version, showVersion)
+	}
+
+	s := &Show{}
+	for n := 2; scanner.Scan(); n++ {
+		var l showLine
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return nil, fmt.Errorf("show: line %d: %v", n, err)
+		}
+		at := time.Duration(l.T * float64(time.Second))
+		if l.End {
+			s.Length = at
+			break
+		}
+		if l.Data == nil {
+			return nil, fmt.Errorf("show: line %d has no data", n)
+		}
+		s.Steps = append(s.Steps, ShowStep{At: at, Data: *l.Data})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(s.Steps) == 0 {
+		return nil, errors.New("show: no looks recorded")
+	}
+	// The steps are written in order but a hand edited file might not be
+	sort.SliceStable(s.Steps, func(i, j int) bool { return s.Steps[i].At < s.Steps[j].At })
+	// A recording cut short ends with its last look
+	if last := s.Steps[len(s.Steps)-1].At; s.Length < last {
+		s.Length = last
+	}
+	return s, nil
+}
+
+// At returns the look of the show at the time from its start
+func (s *Show) At(t time.Duration) DMXData {
+	return s.Steps[s.index(t)].Data
+}
+
+// index returns the step shown at the time, the first one before its start
+func (s *Show) index(t time.Duration) int {
+	i := sort.Search(len(s.Steps), func(i int) bool { return s.Steps[i].At > t })
+	if i == 0 {
+		return 0
+	}
+	return i - 1
+}
+
+// ShowRecorder writes the changes of the data coming from the DMX reader to a show file
+type ShowRecorder struct {
+	w           *bufio.Writer
+	start       time.Time
+	unsubscribe func()
+	done        chan struct{}
+	err         error
+}
+
+// RecordShow starts recording the DMX input of the state with its latest look as the first one
+// the looks set by the failsafe and the other inputs aren't recorded
+func RecordShow(state *State, w io.Writer) (*ShowRecorder, error) {
+	r := &ShowRecorder{w: bufio.NewWriter(w), start: time.Now(), done: make(chan struct{})}
+	var changes <-chan Change
+	changes, r.unsubscribe = state.SubscribeReceived(FieldAll)
+	if err := r.write(showHeader{Format: showFormat, Version: showVersion, Recorded: r.start}); err != nil {
+		r.unsubscribe()
+		return nil, err
+	}
+	first := state.receivedData()
+	r.err = r.write(showLine{Data: &first})
+
+	go func() {
+		defer close(r.done)
+		for c := range changes {
+			if r.err == nil {
+				data := c.Data
+				r.err = r.write(showLine{T: time.Since(r.start).Seconds(), Data: &data})
+			}
+		}
+	}()
+	return r, nil
+}
+
+func (r *ShowRecorder) write(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = r.w.Write(append(b, '\n'))
+	return err
+}
+
+// Close ends the recording, the writer is left open
+func (r *ShowRecorder) Close() error {
+	r.unsubscribe()
+	<-r.done
+	if r.err != nil {
+		return r.err
+	}
+	if err := r.write(showLine{T: time.Since(r.start).Seconds(), End: true}); err != nil {
+		return err
+	}
+	return r.w.Flush()
+}
+
+// ShowPlayer plays a show back as a DMX source
+type ShowPlayer struct {
+	show *Show
+	loop bool
+	// startAt is the wall clock time the show starts at, zero starts it right away
+	startAt time.Time
+	// onStart is called at the start of the show, for example to start the audio with it
+	onStart func()
+}
+
+// NewShowPlayer returns a player of the show, the show starts at the startAt time or right away when it's zero
+func NewShowPlayer(show *Show, loop bool, startAt time.Time, onStart func()) *ShowPlayer {
+	return &ShowPlayer{show: show, loop: loop, startAt: startAt, onStart: onStart}
+}
+
+// Run sets the state from the show the same way the other DMX readers do it, after the end of the show
+// the player stops sending and the failsafe takes over
+func (p *ShowPlayer) Run(state *State, wg *sync.WaitGroup, quit, pause, play <-chan struct{}) {
+	defer wg.Done()
+
+	// Wait for the first signal to start the goroutine
+	select {
+	case <-play:
+	case <-quit:
+		log.Println("Stopping show player thread")
+		return
+	}
+
+	start := p.startAt
+	if start.IsZero() {
+		start = time.Now()
+	} else {
+		log.Println("The show starts at", start.Format("15:04:05"))
+	}
+	if !sleepUntil(start, quit) {
+		log.Println("Stopping show player thread")
+		return
+	}
+	if p.onStart != nil {
+		p.onStart()
+	}
+	log.Println("Playing the show of", p.show.Length.Round(time.Second))
+
+	steps := p.show.Steps
+	for {
+		// Every wake up sends the current look, either at its start or as the refresh of a long one
+		elapsed := time.Since(start)
+		i := p.show.index(elapsed)
+		state.Received(steps[i].Data, time.Now())
+
+		if elapsed >= p.show.Length {
+			if !p.loop || p.show.Length <= 0 {
+				log.Println("The show has ended")
+				<-quit
+				log.Println("Stopping show player thread")
+				return
+			}
+			start = start.Add(p.show.Length)
+			if p.onStart != nil {
+				p.onStart()
+			}
+			continue
+		}
+
+		next := start.Add(p.show.Length)
+		if i+1 < len(steps) {
+			next = start.Add(steps[i+1].At)
+		}
+		if !sleepUntil(minTime(next, time.Now().Add(showRefresh)), quit) {
+			log.Println("Stopping show player thread")
+			return
+		}
+	}
+}
+
+// sleepUntil waits for the time and returns false when the quit signal came first
+func sleepUntil(t time.Time, quit <-chan struct{}) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-quit:
+		return false
+	}
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,113 @@
+package dmx
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShowRecord(t *testing.T) {
+	s := NewState()
+	var buf bytes.Buffer
+	r, err := RecordShow(s, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	looks := []DMXData{{DisplayMode: 1, WhiteDots: true}, {DisplayMode: 2, ColorPalette: 3}}
+	for _, look := range looks {
+		time.Sleep(20 * time.Millisecond)
+		s.Received(look, time.Now())
+		// Only the DMX input is recorded, the changes of the other inputs are left out
+		s.Update(func(data *DMXData) { data.DisplayMode = 5 })
+	}
+	// A lost signal alone isn't a change of the look and neither is the look of the failsafe
+	s.lost(time.Now())
+	s.setAbsent(DMXData{DisplayMode: 6})
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	show, err := ReadShow(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(show.Steps) != 3 {
+		t.Fatalf("steps mismatch. Want: %v, Have: %v\n", 3, len(show.Steps))
+	}
+	for i, look := range looks {
+		if have := show.Steps[i+1].Data; have != look {
+			t.Errorf("look %d mismatch. Want: %v, Have: %v\n", i, look, have)
+		}
+		if i > 0 && show.Steps[i+1].At <= show.Steps[i].At {
+			t.Errorf("look %d isn't after the previous one\n", i)
+		}
+	}
+	if show.Length < show.Steps[2].At {
+		t.Errorf("length %v is before the last look at %v\n", show.Length, show.Steps[2].At)
+	}
+	if have := show.At(show.Steps[1].At + time.Millisecond); have != looks[0] {
+		t.Errorf("look at mismatch. Want: %v, Have: %v\n", looks[0], have)
+	}
+}
+
+func TestReadShow(t *testing.T) {
+	show, err := ReadShow(strings.NewReader(`{"format":"fftwave-dmx","version":1}
+{"t":1.5,"data":{"DisplayMode":2}}
+{"t":0,"data":{"DisplayMode":1}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The looks are sorted and the show without the end marker ends with its last look
+	if show.Steps[0].Data.DisplayMode != 1 || show.Length != 1500*time.Millisecond {
+		t.Errorf("show mismatch. Want: %v %v, Have: %v %v\n", 1, 1500*time.Millisecond, show.Steps[0].Data.DisplayMode, show.Length)
+	}
+
+	for name, file := range map[string]string{
+		"empty":   "",
+		"format":  `{"format":"other","version":1}`,
+		"version": `{"format":"fftwave-dmx","version":2}`,
+		"looks":   "{\"format\":\"fftwave-dmx\",\"version\":1}\n{\"t\":1,\"end\":true}",
+	} {
+		if _, err := ReadShow(strings.NewReader(file)); err == nil {
+			t.Errorf("%s show was accepted\n", name)
+		}
+	}
+}
+
+func TestShowPlayer(t *testing.T) {
+	show := &Show{
+		Steps: []ShowStep{
+			{At: 0, Data: DMXData{DisplayMode: 1}},
+			{At: 30 * time.Millisecond, Data: DMXData{DisplayMode: 2}},
+		},
+		Length: 60 * time.Millisecond,
+	}
+	s := NewState()
+	changes, unsubscribe := s.Subscribe(FieldDisplayMode)
+	defer unsubscribe()
+
+	started := make(chan struct{}, 1)
+	p := NewShowPlayer(show, false, time.Time{}, func() { started <- struct{}{} })
+	var wg sync.WaitGroup
+	quit, play := make(chan struct{}), make(chan struct{})
+	wg.Add(1)
+	go p.Run(s, &wg, quit, nil, play)
+	play <- struct{}{}
+
+	<-started
+	for _, want := range []byte{1, 2} {
+		select {
+		case c := <-changes:
+			if c.Data.DisplayMode != want || !c.Present {
+				t.Errorf("look mismatch. Want: %v %v, Have: %v %v\n", want, true, c.Data.DisplayMode, c.Present)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no look played")
+		}
+	}
+	close(quit)
+	wg.Wait()
+}
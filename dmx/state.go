@@ -0,0 +1,272 @@
+package dmx
+
+import (
+	"sync"
+	"time"
+)
+
+// Field is a set of the DMXData fields
+type Field uint
+
+// Fields of the DMXData reported by the change notifications
+const (
+	FieldDisplayMode Field = 1 << iota
+	FieldBackgroundMode
+	FieldWhiteDots
+	FieldColorPalette
+	FieldPaletteAngle
+	FieldPalettePhaseOffset
+	FieldColor
+	FieldLyricsDMXInfo
+	// FieldPresent is reported when the DMX signal is lost or comes back
+	FieldPresent
+
+	FieldAll Field = 1<<iota - 1
+)
+
+// Change is a notification of the changed fields together with the whole data after the change
+type Change struct {
+	Fields  Field
+	Data    DMXData
+	Present bool
+}
+
+// subscriber gets the changes of the fields it is interested in
+// the received ones only get the changes of the data coming from the DMX reader
+type subscriber struct {
+	fields   Field
+	received bool
+	ch       chan Change
+}
+
+// State holds the DMX data shared by the DMX reader, the render loop and the rest of the consumers
+// the readers always see a consistent snapshot of all of the fields
+type State struct {
+	mu   sync.RWMutex
+	data DMXData
+	subs map[*subscriber]struct{}
+	// present is set by the fresh data from the DMX reader and cleared by the failsafe
+	present bool
+	last    time.Time
+	// received is the latest data from the DMX reader, without the changes of the other sources
+	received DMXData
+	// controlled is the time of the latest change made by the control inputs
+	controlled time.Time
+}
+
+// NewState returns a state with the reset values
+func NewState() *State {
+	s := &State{subs: make(map[*subscriber]struct{})}
+	ResetDMX(&s.data)
+	s.received = s.data
+	return s
+}
+
+// Snapshot returns a copy of the current data
+func (s *State) Snapshot() DMXData {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data
+}
+
+// Present reports whether fresh DMX data has been received within the failsafe timeout
+func (s *State) Present() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.present
+}
+
+// Set replaces the whole data and notifies the subscribers of the changed fields
+func (s *State) Set(data DMXData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(data, 0)
+}
+
+// Update changes the fields with the function under the lock so the changes of the other fields made
+// at the same time aren't lost, it's meant for the control inputs like OSC and MIDI which take over from the failsafe
+func (s *State) Update(change func(data *DMXData)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.controlled = time.Now()
+	data := s.data
+	change(&data)
+	s.set(data, 0)
+}
+
+// Received sets the fresh data from the DMX reader and marks the signal as present
+func (s *State) Received(data DMXData, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = now
+	var changed Field
+	if !s.present {
+		s.present = true
+		changed = FieldPresent
+	}
+	s.set(data, changed)
+	s.notify(diff(s.received, data), data, true)
+	s.received = data
+}
+
+// refresh keeps the signal present without new data for the sources which only send the changes,
+// the latest received data takes over again from the failsafe look
+func (s *State) refresh(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last.IsZero() {
+		return
+	}
+	s.last = now
+	if !s.present {
+		s.present = true
+		s.set(s.received, FieldPresent)
+	}
+}
+
+// receivedData returns the latest data from the DMX reader
+func (s *State) receivedData() DMXData {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.received
+}
+
+// lastControlled returns the time of the latest change made by the control inputs
+func (s *State) lastControlled() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.controlled
+}
+
+// lastReceived returns the time of the latest fresh data, zero when nothing has been received yet
+func (s *State) lastReceived() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.last
+}
+
+// lost clears the presence, it returns false when the signal came back in the meantime
+func (s *State) lost(since time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.last.After(since) {
+		return false
+	}
+	if s.present {
+		s.present = false
+		s.set(s.data, FieldPresent)
+	}
+	return true
+}
+
+// setAbsent sets the data only while there's no DMX signal so the failsafe never overrides a returning source
+func (s *State) setAbsent(data DMXData) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.present {
+		return false
+	}
+	s.set(data, 0)
+	return true
+}
+
+// set stores the data and notifies the subscribers, it's called with the lock held
+func (s *State) set(data DMXData, changed Field) {
+	changed |= diff(s.data, data)
+	s.data = data
+	s.notify(changed, data, false)
+}
+
+// notify passes the change on to the subscribers of its kind, it's called with the lock held
+func (s *State) notify(changed Field, data DMXData, received bool) {
+	if changed == 0 {
+		return
+	}
+	for sub := range s.subs {
+		if sub.received == received && sub.fields&changed != 0 {
+			sub.notify(Change{Fields: changed & sub.fields, Data: data, Present: s.present})
+		}
+	}
+}
+
+// Reset sets the default values
+func (s *State) Reset() {
+	var data DMXData
+	ResetDMX(&data)
+	s.Set(data)
+}
+
+// Subscribe returns a channel receiving the changes of the fields, a slow subscriber gets the changes
+// merged into a single one with the latest data so it never blocks the DMX reader
+// the returned function ends the subscription and closes the channel
+func (s *State) Subscribe(fields Field) (<-chan Change, func()) {
+	return s.subscribe(&subscriber{fields: fields, ch: make(chan Change, 1)})
+}
+
+// SubscribeReceived works like Subscribe but only reports the changes of the data coming from the DMX reader
+// leaving out the failsafe and the other inputs, the presence of the signal isn't reported
+func (s *State) SubscribeReceived(fields Field) (<-chan Change, func()) {
+	return s.subscribe(&subscriber{fields: fields &^ FieldPresent, received: true, ch: make(chan Change, 1)})
+}
+
+func (s *State) subscribe(sub *subscriber) (<-chan Change, func()) {
+	s.mu.Lock()
+	s.subs[sub] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subs, sub)
+			s.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+}
+
+// notify is only called with the state lock held so the subscriber channel has a single sender
+func (sub *subscriber) notify(c Change) {
+	select {
+	case sub.ch <- c:
+		return
+	default:
+	}
+	// The pending change hasn't been taken yet, it is replaced with one holding the fields of both
+	select {
+	case old := <-sub.ch:
+		c.Fields |= old.Fields
+	default:
+	}
+	sub.ch <- c
+}
+
+// diff returns the fields which differ between the two data sets
+func diff(a, b DMXData) Field {
+	var f Field
+	if a.DisplayMode != b.DisplayMode {
+		f |= FieldDisplayMode
+	}
+	if a.BackgroundMode != b.BackgroundMode {
+		f |= FieldBackgroundMode
+	}
+	if a.WhiteDots != b.WhiteDots {
+		f |= FieldWhiteDots
+	}
+	if a.ColorPalette != b.ColorPalette {
+		f |= FieldColorPalette
+	}
+	if a.PaletteAngle != b.PaletteAngle {
+		f |= FieldPaletteAngle
+	}
+	if a.PalettePhaseOffset != b.PalettePhaseOffset {
+		f |= FieldPalettePhaseOffset
+	}
+	if a.Color != b.Color {
+		f |= FieldColor
+	}
+	if a.LyricsDMXInfo != b.LyricsDMXInfo {
+		f |= FieldLyricsDMXInfo
+	}
+	return f
+}
@@ -0,0 +1,96 @@
+package dmx
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestStateSubscribe(t *testing.T) {
+	s := NewState()
+	modes, unsubscribe := s.Subscribe(FieldDisplayMode | FieldBackgroundMode)
+
+	// Changes of the other fields are not reported
+	data := s.Snapshot()
+	data.PaletteAngle = 10
+	s.Set(data)
+	select {
+	case c := <-modes:
+		t.Errorf("unexpected change. Have: %v\n", c)
+	default:
+	}
+
+	// The changes which haven't been received are merged
+	data.DisplayMode = 2
+	s.Set(data)
+	data.BackgroundMode = 3
+	data.PaletteAngle = 20
+	s.Set(data)
+	c := <-modes
+	if c.Fields != FieldDisplayMode|FieldBackgroundMode {
+		t.Errorf("fields mismatch. Want: %v, Have: %v\n", FieldDisplayMode|FieldBackgroundMode, c.Fields)
+	}
+	if c.Data != data {
+		t.Errorf("data mismatch. Want: %v, Have: %v\n", data, c.Data)
+	}
+
+	s.Reset()
+	if c := <-modes; c.Data.DisplayMode != 0 || !c.Data.WhiteDots {
+		t.Errorf("reset data mismatch. Want: %v %v, Have: %v %v\n", 0, true, c.Data.DisplayMode, c.Data.WhiteDots)
+	}
+
+	unsubscribe()
+	unsubscribe()
+	s.Set(data)
+	if _, ok := <-modes; ok {
+		t.Errorf("channel still open after unsubscribing\n")
+	}
+}
+
+func TestStateUpdate(t *testing.T) {
+	s := NewState()
+	colors, unsubscribe := s.Subscribe(FieldColor)
+	defer unsubscribe()
+
+	// Only the changed field is reported and the rest of the data is kept
+	s.Update(func(data *DMXData) { data.DisplayMode = 3 })
+	s.Update(func(data *DMXData) { data.Color.R = 200 })
+	c := <-colors
+	if c.Fields != FieldColor {
+		t.Errorf("fields mismatch. Want: %v, Have: %v\n", FieldColor, c.Fields)
+	}
+	if c.Data.DisplayMode != 3 || c.Data.Color.R != 200 {
+		t.Errorf("data mismatch. Want: %v %v, Have: %v %v\n", 3, 200, c.Data.DisplayMode, c.Data.Color.R)
+	}
+}
+
+func TestStateConcurrent(t *testing.T) {
+	s := NewState()
+	changes, unsubscribe := s.Subscribe(FieldAll)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			// Every field of a set holds the same value so a torn read shows up
+			v := byte(i)
+			s.Set(DMXData{DisplayMode: v, BackgroundMode: v, PaletteAngle: v, PalettePhaseOffset: v})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			d := s.Snapshot()
+			if d.DisplayMode != d.BackgroundMode || d.PaletteAngle != d.PalettePhaseOffset || d.DisplayMode != d.PaletteAngle {
+				t.Errorf("inconsistent snapshot. Have: %v\n", d)
+				return
+			}
+		}
+	}()
+	go func() {
+		for range changes {
+		}
+	}()
+	wg.Wait()
+	unsubscribe()
+}
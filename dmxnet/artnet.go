@@ -0,0 +1,77 @@
+// Package dmxnet implements the network DMX protocols, Art-Net and E1.31 (sACN)
+package dmxnet
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Definition of the Art-Net protocol values
+const (
+	ArtNetPort      = 6454
+	artNetID        = "Art-Net\x00"
+	artNetOpDmx     = 0x5000
+	artNetVersion   = 14
+	artNetHeaderLen = 18
+)
+
+// AppendArtDmx appends an ArtDmx packet with the channel data of the universe to buf
+// the universe is the 15 bit port address made of the net, the sub-net and the universe
+func AppendArtDmx(buf []byte, sequence byte, universe int, data []byte) ([]byte, error) {
+	if universe < 0 || universe > 0x7fff {
+		return buf, fmt.Errorf("artnet: universe %d is out of the range 0..32767", universe)
+	}
+	if len(data) > 512 {
+		return buf, fmt.Errorf("artnet: %d channels don't fit in a universe", len(data))
+	}
+
+	// The length has to be even and at least 2
+	length := len(data) + len(data)%2
+	if length < 2 {
+		length = 2
+	}
+
+	buf = append(buf, artNetID...)
+	// The opcode is the only little endian field
+	buf = append(buf, artNetOpDmx&0xff, artNetOpDmx>>8)
+	buf = append(buf, 0, artNetVersion)
+	buf = append(buf, sequence, 0, byte(universe), byte(universe>>8))
+	buf = append(buf, byte(length>>8), byte(length))
+	buf = append(buf, data...)
+	for i := len(data); i < length; i++ {
+		buf = append(buf, 0)
+	}
+	return buf, nil
+}
+
+// ArtDmx is the content of an ArtDmx packet
+type ArtDmx struct {
+	Sequence byte
+	Physical byte
+	Universe int
+	Data     []byte
+}
+
+// ErrNotArtDmx is returned for the Art-Net packets with other opcodes and for the other packets on the port
+var ErrNotArtDmx = errors.New("artnet: not an ArtDmx packet")
+
+// ParseArtDmx decodes an ArtDmx packet, the data refers to the packet buffer
+func ParseArtDmx(packet []byte) (ArtDmx, error) {
+	if len(packet) < artNetHeaderLen || string(packet[:8]) != artNetID ||
+		int(packet[8])|int(packet[9])<<8 != artNetOpDmx {
+		return ArtDmx{}, ErrNotArtDmx
+	}
+	if version := int(packet[10])<<8 | int(packet[11]); version < artNetVersion {
+		return ArtDmx{}, fmt.Errorf("artnet: protocol version %d isn't supported", version)
+	}
+	length := int(packet[16])<<8 | int(packet[17])
+	if length > 512 || artNetHeaderLen+length > len(packet) {
+		return ArtDmx{}, fmt.Errorf("artnet: length %d doesn't match the packet", length)
+	}
+	return ArtDmx{
+		Sequence: packet[12],
+		Physical: packet[13],
+		Universe: int(packet[14]) | int(packet[15]&0x7f)<<8,
+		Data:     packet[artNetHeaderLen : artNetHeaderLen+length],
+	}, nil
+}
@@ -0,0 +1,33 @@
+package dmxnet
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestArtDmx(t *testing.T) {
+	packet, err := AppendArtDmx(nil, 7, 0x1234, []byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParseArtDmx(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The odd length is padded to an even one
+	if p.Sequence != 7 || p.Universe != 0x1234 || !bytes.Equal(p.Data, []byte{1, 2, 3, 0}) {
+		t.Errorf("packet mismatch. Want: %v %v %v, Have: %v %v %v\n", 7, 0x1234, []byte{1, 2, 3, 0}, p.Sequence, p.Universe, p.Data)
+	}
+
+	if _, err := ParseArtDmx(packet[:len(packet)-1]); err == nil {
+		t.Errorf("truncated packet mismatch. Want: error, Have: %v\n", err)
+	}
+	// ArtPoll
+	poll := append([]byte(artNetID), 0x00, 0x20, 0, 14, 0, 0)
+	if _, err := ParseArtDmx(poll); err != ErrNotArtDmx {
+		t.Errorf("ArtPoll mismatch. Want: %v, Have: %v\n", ErrNotArtDmx, err)
+	}
+	if _, err := AppendArtDmx(nil, 0, 0x8000, nil); err == nil {
+		t.Errorf("universe range mismatch. Want: error, Have: %v\n", err)
+	}
+}
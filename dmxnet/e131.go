@@ -0,0 +1,146 @@
+package dmxnet
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Definition of the E1.31 protocol values
+const (
+	E131Port            = 5568
+	E131DefaultPriority = 100
+	e131ID              = "ASC-E1.17\x00\x00\x00"
+	e131VectorRoot      = 0x00000004
+	e131VectorFraming   = 0x00000002
+	e131VectorDMP       = 0x02
+	e131AddressType     = 0xa1
+	e131SourceNameLen   = 64
+	e131HeaderLen       = 126
+)
+
+// CID is the component identifier of an E1.31 source
+type CID [16]byte
+
+// NewCID derives a stable identifier from the source name so that the receivers see the same source after a restart
+func NewCID(name string) CID {
+	var cid CID
+	sum := sha1.Sum([]byte(name))
+	copy(cid[:], sum[:])
+	// Name based UUID version 5 in the RFC 4122 variant
+	cid[6] = cid[6]&0x0f | 0x50
+	cid[8] = cid[8]&0x3f | 0x80
+	return cid
+}
+
+// E131Multicast returns the multicast group of the universe
+func E131Multicast(universe int) net.IP {
+	return net.IPv4(239, 255, byte(universe>>8), byte(universe))
+}
+
+// E131Source holds the fields shared by all of the data packets of a source
+type E131Source struct {
+	CID      CID
+	Name     string
+	Priority int
+}
+
+// AppendData appends an E1.31 data packet with the channel data of the universe to buf
+func (s E131Source) AppendData(buf []byte, sequence byte, universe int, data []byte) ([]byte, error) {
+	if universe < 1 || universe > 63999 {
+		return buf, fmt.Errorf("e131: universe %d is out of the range 1..63999", universe)
+	}
+	if len(data) > 512 {
+		return buf, fmt.Errorf("e131: %d channels don't fit in a universe", len(data))
+	}
+	if s.Priority < 0 || s.Priority > 200 {
+		return buf, fmt.Errorf("e131: priority %d is out of the range 0..200", s.Priority)
+	}
+	length := e131HeaderLen + len(data)
+
+	// Root layer
+	buf = append(buf, 0x00, 0x10, 0x00, 0x00)
+	buf = append(buf, e131ID...)
+	buf = appendFlagsLength(buf, length-16)
+	buf = appendUint32(buf, e131VectorRoot)
+	buf = append(buf, s.CID[:]...)
+
+	// Framing layer
+	buf = appendFlagsLength(buf, length-38)
+	buf = appendUint32(buf, e131VectorFraming)
+	var name [e131SourceNameLen]byte
+	copy(name[:e131SourceNameLen-1], s.Name)
+	buf = append(buf, name[:]...)
+	buf = append(buf, byte(s.Priority), 0, 0, sequence, 0, byte(universe>>8), byte(universe))
+
+	// DMP layer, the property values start with the zero start code
+	buf = appendFlagsLength(buf, length-115)
+	buf = append(buf, e131VectorDMP, e131AddressType, 0, 0, 0, 1)
+	buf = append(buf, byte((len(data)+1)>>8), byte(len(data)+1), 0)
+	return append(buf, data...), nil
+}
+
+func appendFlagsLength(buf []byte, length int) []byte {
+	return append(buf, byte(0x70|length>>8), byte(length))
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// Definition of the E1.31 framing options
+const (
+	E131OptionPreview    = 0x80
+	E131OptionTerminated = 0x40
+)
+
+// E131Data is the content of an E1.31 data packet
+type E131Data struct {
+	CID        CID
+	SourceName string
+	Priority   int
+	Sequence   byte
+	Options    byte
+	Universe   int
+	StartCode  byte
+	Data       []byte
+}
+
+// ErrNotE131Data is returned for the E1.31 synchronization and discovery packets and for the other packets on the port
+var ErrNotE131Data = errors.New("e131: not a data packet")
+
+// ParseE131Data decodes an E1.31 data packet, the data refers to the packet buffer
+func ParseE131Data(packet []byte) (E131Data, error) {
+	if len(packet) < e131HeaderLen || string(packet[4:16]) != e131ID ||
+		uint32At(packet[18:]) != e131VectorRoot || uint32At(packet[40:]) != e131VectorFraming {
+		return E131Data{}, ErrNotE131Data
+	}
+	if packet[117] != e131VectorDMP || packet[118] != e131AddressType {
+		return E131Data{}, errors.New("e131: unknown DMP layer")
+	}
+	count := int(packet[123])<<8 | int(packet[124])
+	if count < 1 || count > 513 || e131HeaderLen-1+count > len(packet) {
+		return E131Data{}, fmt.Errorf("e131: property value count %d doesn't match the packet", count)
+	}
+
+	var d E131Data
+	copy(d.CID[:], packet[22:38])
+	name := packet[44:108]
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+	d.SourceName = string(name)
+	d.Priority = int(packet[108])
+	d.Sequence = packet[111]
+	d.Options = packet[112]
+	d.Universe = int(packet[113])<<8 | int(packet[114])
+	d.StartCode = packet[125]
+	d.Data = packet[e131HeaderLen : e131HeaderLen-1+count]
+	return d, nil
+}
+
+func uint32At(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
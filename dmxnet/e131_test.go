@@ -0,0 +1,35 @@
+package dmxnet
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestE131Data(t *testing.T) {
+	src := E131Source{CID: NewCID("console"), Name: "console", Priority: 150}
+	packet, err := src.AppendData(nil, 9, 300, []byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ParseE131Data(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.CID != src.CID || d.SourceName != "console" || d.Priority != 150 || d.Sequence != 9 || d.Universe != 300 {
+		t.Errorf("header mismatch. Want: %v %v %v %v, Have: %v %v %v %v\n", "console", 150, 9, 300, d.SourceName, d.Priority, d.Sequence, d.Universe)
+	}
+	if d.StartCode != 0 || !bytes.Equal(d.Data, []byte{1, 2, 3}) {
+		t.Errorf("data mismatch. Want: %v, Have: %v\n", []byte{1, 2, 3}, d.Data)
+	}
+
+	if _, err := ParseE131Data(packet[:len(packet)-1]); err == nil {
+		t.Errorf("truncated packet mismatch. Want: error, Have: %v\n", err)
+	}
+	artnet, _ := AppendArtDmx(nil, 0, 0, make([]byte, 200))
+	if _, err := ParseE131Data(artnet); err != ErrNotE131Data {
+		t.Errorf("other packet mismatch. Want: %v, Have: %v\n", ErrNotE131Data, err)
+	}
+	if E131Multicast(300).String() != "239.255.1.44" {
+		t.Errorf("multicast group mismatch. Want: %v, Have: %v\n", "239.255.1.44", E131Multicast(300))
+	}
+}
@@ -0,0 +1,22 @@
+package drawloops
+
+import (
+	"time"
+)
+
+// frameTime is the time of the frame being drawn, the animated waves use it instead of the wall clock
+// so that the frames can be rendered offscreen at any pace
+var frameTime time.Time
+
+// SetFrameTime sets the time of the next drawn frame, it has to be called from the drawing goroutine
+func SetFrameTime(t time.Time) {
+	frameTime = t
+}
+
+// currentTime returns the time of the frame being drawn, or the wall clock if it was never set
+func currentTime() time.Time {
+	if frameTime.IsZero() {
+		return time.Now()
+	}
+	return frameTime
+}
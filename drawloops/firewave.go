@@ -0,0 +1,152 @@
+package drawloops
+
+import (
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"time"
+
+	"github.com/TFK1410/go-rpi-fftwave/dmx"
+	"github.com/TFK1410/go-rpi-fftwave/modes"
+	"github.com/TFK1410/go-rpi-fftwave/palette"
+)
+
+// FireWave defines the values used for the display of the wave that are specific to this pattern type
+// it's based on the fire propagation algorithm from the PSX Doom port
+type FireWave struct {
+	params  *modes.Params
+	version uint64
+	step    time.Duration
+
+	dataWidth      int
+	dataHeight     int
+	minVal, maxVal float64
+	paletteIndexes []byte
+
+	heat     []uint8
+	maxDecay int
+	rng      *rand.Rand
+	lastDraw time.Time
+	pending  time.Duration
+}
+
+// InitWave does the initial calculation of the reused variables in the draw loop
+func (fw *FireWave) InitWave(screenWidth, screenHeight int, minVal, maxVal float64) {
+	fw.dataWidth = screenWidth
+	fw.dataHeight = screenHeight
+	fw.minVal, fw.maxVal = minVal, maxVal
+	fw.heat = make([]uint8, screenWidth*screenHeight)
+	fw.rng = rand.New(rand.NewSource(1))
+	fw.setup()
+}
+
+// setup calculates the values derived from the current parameters
+func (fw *FireWave) setup() {
+	fw.version = fw.params.Version()
+	fw.step = time.Second / time.Duration(fw.params.Int("updateRate"))
+	// On average the full heat should die out just as it reaches the top of the screen
+	fw.maxDecay = int(fw.params.Float("cooling")*510/float64(fw.dataHeight)) + 1
+}
+
+// Draw creates a new canvas to be later rendered on the matrix
+func (fw *FireWave) Draw(c draw.Image, dmxData dmx.DMXData, data, dots []float64) {
+	if fw.params.Version() != fw.version {
+		fw.setup()
+	}
+
+	// The fire is propagated with its own fixed rate so that the flames look the same regardless of the refresh rate
+	step := fw.step
+	now := currentTime()
+	elapsed := now.Sub(fw.lastDraw)
+	fw.lastDraw = now
+	if elapsed < 0 || elapsed > time.Second {
+		elapsed = step
+	}
+
+	fw.pending += elapsed
+	for ; fw.pending >= step; fw.pending -= step {
+		fw.feed(data)
+		fw.spread()
+	}
+
+	p := palette.Palettes[fw.params.Int("palette")]
+	if dmxData.ColorPalette > 0 {
+		p = palette.Palettes[dmxData.ColorPalette]
+	}
+
+	for y := 0; y < fw.dataHeight; y++ {
+		for x := 0; x < fw.dataWidth; x++ {
+			h := fw.heat[y*fw.dataWidth+x]
+			if h == 0 {
+				// leave the pixel empty for the background
+				c.Set(x, y, color.RGBA{0, 0, 0, 0})
+				continue
+			}
+
+			var clr color.RGBA
+			if dmxData.Color.A > 0 {
+				clr = dmxData.Color
+			} else {
+				clr = p[h]
+			}
+			fw.DrawPixels(c, x, y, dim(clr, h))
+		}
+	}
+}
+
+// feed sets the heat sources in the bottom row from the FFT values of every column
+func (fw *FireWave) feed(data []float64) {
+	bottom := fw.heat[(fw.dataHeight-1)*fw.dataWidth:]
+	for x := range bottom {
+		val := data[x*len(data)/fw.dataWidth]
+		heat := (val - fw.minVal) / (fw.maxVal - fw.minVal)
+		if heat < 0 {
+			heat = 0
+		} else if heat > 1 {
+			heat = 1
+		}
+		bottom[x] = uint8(heat * 255)
+	}
+}
+
+// spread moves every heat value one row up with a random decay and a random sideways drift
+func (fw *FireWave) spread() {
+	for y := 0; y < fw.dataHeight-1; y++ {
+		for x := 0; x < fw.dataWidth; x++ {
+			r := fw.rng.Intn(3)
+			dstX := x + r - 1
+			if dstX < 0 || dstX >= fw.dataWidth {
+				dstX = x
+			}
+
+			heat := int(fw.heat[(y+1)*fw.dataWidth+x]) - fw.rng.Intn(fw.maxDecay+1)
+			if heat < 0 {
+				heat = 0
+			}
+			fw.heat[y*fw.dataWidth+dstX] = uint8(heat)
+		}
+	}
+}
+
+// dim scales the color down with the heat so that the cooling embers fade out to black
+func dim(clr color.RGBA, heat uint8) color.RGBA {
+	f := uint16(heat) + 1
+	return color.RGBA{uint8(uint16(clr.R) * f >> 8), uint8(uint16(clr.G) * f >> 8), uint8(uint16(clr.B) * f >> 8), 255}
+}
+
+// This function draws a single pixel, the fire buffer is already in screen space
+func (fw *FireWave) DrawPixels(c draw.Image, x, y int, clr color.RGBA) {
+	c.Set(x, y, clr)
+}
+
+func (fw *FireWave) GetDataSize() (int, int) {
+	return fw.dataWidth, fw.dataHeight
+}
+
+func (fw *FireWave) GetValueRange() (float64, float64) {
+	return fw.minVal, fw.maxVal
+}
+
+func (fw *FireWave) GetPaletteIndexes() []byte {
+	return fw.paletteIndexes
+}
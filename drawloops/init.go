@@ -1,38 +1,183 @@
 package drawloops
 
 import (
+	"fmt"
 	"image/color"
+	"image/draw"
+	"sync"
 
 	"github.com/TFK1410/go-rpi-fftwave/dmx"
-	rgbmatrix "github.com/tfk1410/go-rpi-rgb-led-matrix"
+	"github.com/TFK1410/go-rpi-fftwave/modes"
 )
 
 // Wave is used for the implementation of any possible display patterns
 type Wave interface {
 	InitWave(int, int, float64, float64)
-	Draw(*rgbmatrix.Canvas, dmx.DMXData, []float64, []float64)
-	DrawPixels(c *rgbmatrix.Canvas, x, y int, clr color.RGBA)
+	Draw(draw.Image, dmx.DMXData, []float64, []float64)
+	DrawPixels(c draw.Image, x, y int, clr color.RGBA)
 	GetDataSize() (int, int)
 	GetValueRange() (float64, float64)
 	GetPaletteIndexes() []byte
 }
 
+// iterator holds the index of the currently selected wave and is shared by every way of switching the waves
 var iterator int
+var iteratorMu sync.Mutex
 var waves []Wave
 
-// InitWaves creates the wave types array and initializes every one of them
-func InitWaves(screenWidth, screenHeight int, minVal, maxVal float64) {
-	waves = append(waves, &SingleWave{})
-	waves = append(waves, &SingleWaveMirrored{})
-	waves = append(waves, &DualWave{})
-	waves = append(waves, &MirrorWave{})
-	waves = append(waves, &QuadWave{})
-	waves = append(waves, &QuadWaveSideways{})
-	waves = append(waves, &NoWave{})
-
-	for i := range waves {
+// infos and params hold the descriptions and the parameter sets of the wave instances, in the same order as the waves
+var infos []modes.Info
+var params []*modes.Params
+
+// CheckPresets checks that every preset has a unique name which isn't taken by a wave type and a known type
+func CheckPresets(presets []modes.Preset) error {
+	for i, preset := range presets {
+		if preset.Name == "" {
+			return fmt.Errorf("wave preset of %q has no name", preset.Type)
+		}
+		if _, ok := lookup(preset.Name); ok {
+			return fmt.Errorf("wave preset %q uses the name of a wave type", preset.Name)
+		}
+		for _, other := range presets[:i] {
+			if other.Name == preset.Name {
+				return fmt.Errorf("wave preset %q is defined twice", preset.Name)
+			}
+		}
+		if _, ok := lookup(preset.Type); !ok {
+			return fmt.Errorf("wave preset %q: unknown wave %q", preset.Name, preset.Type)
+		}
+	}
+	return nil
+}
+
+// ListWaves returns the descriptions of all the selectable waves in their index order
+// these are all of the registered wave types followed by the presets and the layouts
+func ListWaves(presets []modes.Preset, layouts []Layout) ([]modes.Info, error) {
+	out := Available()
+	if err := CheckPresets(presets); err != nil {
+		return nil, err
+	}
+	for _, preset := range presets {
+		r, _ := lookup(preset.Type)
+		info := registry[r].info
+		info.Name = preset.Name
+		info.Description = preset.Description
+		if info.Description == "" {
+			info.Description = "Preset of " + preset.Type
+		}
+		out = append(out, info)
+	}
+	for _, layout := range layouts {
+		if layout.Name == "" {
+			return nil, fmt.Errorf("layout has no name")
+		}
+		for _, info := range out {
+			if info.Name == layout.Name {
+				return nil, fmt.Errorf("layout %q uses an already taken name", layout.Name)
+			}
+		}
+		out = append(out, layoutInfo(layout))
+	}
+	return out, nil
+}
+
+// InitWaves creates an instance of every registered wave type, every preset and every layout and initializes every one of them
+// config holds the parameter values of the waves keyed by the wave name
+func InitWaves(screenWidth, screenHeight int, minVal, maxVal float64, config modes.Config, presets []modes.Preset, layouts []Layout) error {
+	for name := range config {
+		if _, ok := lookup(name); !ok {
+			return fmt.Errorf("unknown wave %q in the config", name)
+		}
+	}
+
+	var err error
+	infos, err = ListWaves(presets, layouts)
+	if err != nil {
+		return err
+	}
+
+	waves = make([]Wave, len(infos))
+	params = make([]*modes.Params, len(infos))
+	for i, info := range infos {
+		params[i] = modes.NewParams(info.Params)
+		if err := loadParams(params[i], info, config, presets); err != nil {
+			return err
+		}
+
+		if info.Type == layoutType {
+			// Layouts are listed last so every wave used by the zones already exists
+			lw, err := newLayoutWave(layouts[i-len(infos)+len(layouts)], screenWidth, screenHeight)
+			if err != nil {
+				return err
+			}
+			waves[i] = lw
+		} else {
+			r, _ := lookup(info.Type)
+			waves[i] = registry[r].new(params[i])
+		}
 		waves[i].InitWave(screenWidth, screenHeight, minVal, maxVal)
 	}
+	return nil
+}
+
+// loadParams applies the values from the config section of the wave type followed by the values of the preset
+func loadParams(p *modes.Params, info modes.Info, config modes.Config, presets []modes.Preset) error {
+	merged := make(map[string]interface{})
+	for name, value := range config[info.Type] {
+		merged[name] = value
+	}
+	for _, preset := range presets {
+		if preset.Name == info.Name {
+			for name, value := range preset.Params {
+				merged[name] = value
+			}
+		}
+	}
+	if err := p.Load(merged); err != nil {
+		return fmt.Errorf("wave %s: %v", info.Name, err)
+	}
+	return nil
+}
+
+// find returns the index of the wave instance with the given name
+func find(name string) (int, bool) {
+	for i := range infos {
+		if infos[i].Name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// SetParam changes a single parameter of the wave with the given name at runtime
+func SetParam(wave, name, value string) error {
+	i, ok := find(wave)
+	if !ok {
+		return fmt.Errorf("unknown wave %q", wave)
+	}
+	return params[i].Set(name, value)
+}
+
+// LoadParams changes the parameters of the waves and their presets at runtime from the values decoded from the config
+// presets can't be added or removed this way as that would change the wave indexes
+func LoadParams(config modes.Config, presets []modes.Preset) error {
+	for name := range config {
+		if _, ok := lookup(name); !ok {
+			return fmt.Errorf("unknown wave %q", name)
+		}
+	}
+	for _, preset := range presets {
+		if i, ok := find(preset.Name); !ok || infos[i].Type != preset.Type {
+			return fmt.Errorf("wave preset %q is new or changed its type, restart the application to apply it", preset.Name)
+		}
+	}
+
+	for i, info := range infos {
+		if err := loadParams(params[i], info, config, presets); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // GetFirstWave returns the first wave type from the array
@@ -40,9 +185,12 @@ func GetFirstWave() Wave {
 	return waves[0]
 }
 
+// GetWaveNum returns the wave with the given index, out of range indexes fall back to the first wave
 func GetWaveNum(i int) Wave {
+	iteratorMu.Lock()
+	defer iteratorMu.Unlock()
 	iterator = i
-	if iterator >= len(waves) {
+	if iterator >= len(waves) || iterator < 0 {
 		iterator = 0
 	}
 	return waves[iterator]
@@ -50,9 +198,27 @@ func GetWaveNum(i int) Wave {
 
 // GetNextWave returns the next wave type from the slice
 func GetNextWave() Wave {
+	iteratorMu.Lock()
+	defer iteratorMu.Unlock()
 	iterator++
 	if iterator >= len(waves) {
 		iterator = 0
 	}
 	return waves[iterator]
 }
+
+// GetWaveByName returns the wave type or preset with the given name
+func GetWaveByName(name string) (Wave, error) {
+	i, ok := find(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown wave %q", name)
+	}
+	return GetWaveNum(i), nil
+}
+
+// GetCurrentWaveName returns the name of the last selected wave
+func GetCurrentWaveName() string {
+	iteratorMu.Lock()
+	defer iteratorMu.Unlock()
+	return infos[iterator].Name
+}
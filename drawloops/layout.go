@@ -0,0 +1,281 @@
+package drawloops
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/TFK1410/go-rpi-fftwave/dmx"
+	"github.com/TFK1410/go-rpi-fftwave/modes"
+)
+
+// Zone describes a rectangle on the canvas with the wave drawn inside of it
+// the content of the zone is mirrored first, then flipped and rotated clockwise by the given angle
+type Zone struct {
+	X        int       `yaml:"x"`
+	Y        int       `yaml:"y"`
+	Width    int       `yaml:"width"`
+	Height   int       `yaml:"height"`
+	Wave     string    `yaml:"wave,omitempty"`
+	Bands    []float64 `yaml:"bands,omitempty"`
+	Rotation int       `yaml:"rotation,omitempty"`
+	FlipX    bool      `yaml:"flipX,omitempty"`
+	FlipY    bool      `yaml:"flipY,omitempty"`
+	MirrorX  bool      `yaml:"mirrorX,omitempty"`
+	MirrorY  bool      `yaml:"mirrorY,omitempty"`
+}
+
+// Layout is a named set of zones which is selectable just like any other wave
+type Layout struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	Zones       []Zone `yaml:"zones"`
+}
+
+// layoutType is the type name of every layout in the wave descriptions
+const layoutType = "layout"
+
+// validate checks the zone against the canvas size and fills in the defaults
+func (z *Zone) validate(screenWidth, screenHeight int) error {
+	if z.Width <= 0 || z.Height <= 0 || z.X < 0 || z.Y < 0 || z.X+z.Width > screenWidth || z.Y+z.Height > screenHeight {
+		return fmt.Errorf("zone %dx%d at %d,%d doesn't fit on the %dx%d canvas", z.Width, z.Height, z.X, z.Y, screenWidth, screenHeight)
+	}
+	if z.Rotation != 0 && z.Rotation != 90 && z.Rotation != 180 && z.Rotation != 270 {
+		return fmt.Errorf("zone rotation has to be one of 0, 90, 180 or 270, got %d", z.Rotation)
+	}
+	if z.Wave == "" {
+		z.Wave = "single"
+	}
+	if len(z.Bands) == 0 {
+		z.Bands = []float64{0, 1}
+	}
+	if len(z.Bands) != 2 || z.Bands[0] < 0 || z.Bands[1] > 1 || z.Bands[0] >= z.Bands[1] {
+		return fmt.Errorf("zone bands have to be a [from, to] range within 0..1, got %v", z.Bands)
+	}
+	return nil
+}
+
+// zoneImage maps the pixels of a zone onto the parent image
+// it lets any wave draw into a zone as if it was a whole canvas of its own
+type zoneImage struct {
+	parent        draw.Image
+	width, height int
+	copies        int
+	mapping       []image.Point // copies positions on the parent image for every zone pixel
+}
+
+// newZoneImage calculates the position of every zone pixel on the parent image
+func newZoneImage(parent draw.Image, z Zone) *zoneImage {
+	// content size before the rotation
+	cw, ch := z.Width, z.Height
+	if z.Rotation == 90 || z.Rotation == 270 {
+		cw, ch = ch, cw
+	}
+
+	zm := &zoneImage{
+		parent: parent,
+		width:  cw,
+		height: ch,
+		copies: 1,
+	}
+	// The odd sized mirrored zones share their center line between both of the copies
+	if z.MirrorX {
+		zm.width = (cw + 1) / 2
+		zm.copies *= 2
+	}
+	if z.MirrorY {
+		zm.height = (ch + 1) / 2
+		zm.copies *= 2
+	}
+	zm.mapping = make([]image.Point, 0, zm.width*zm.height*zm.copies)
+
+	for ly := 0; ly < zm.height; ly++ {
+		for lx := 0; lx < zm.width; lx++ {
+			// Mirrored zones grow outwards from their center, the left and the bottom of the wave are placed in the middle
+			xs, ys := []int{lx}, []int{ly}
+			if z.MirrorX {
+				xs = []int{cw/2 + lx, (cw-1)/2 - lx}
+			}
+			if z.MirrorY {
+				ys = []int{ly, ch - 1 - ly}
+			}
+
+			for _, x := range xs {
+				if z.FlipX {
+					x = cw - 1 - x
+				}
+				for _, y := range ys {
+					if z.FlipY {
+						y = ch - 1 - y
+					}
+
+					var px, py int
+					switch z.Rotation {
+					case 0:
+						px, py = x, y
+					case 90:
+						px, py = ch-1-y, x
+					case 180:
+						px, py = cw-1-x, ch-1-y
+					case 270:
+						px, py = y, cw-1-x
+					}
+					zm.mapping = append(zm.mapping, image.Point{z.X + px, z.Y + py})
+				}
+			}
+		}
+	}
+	return zm
+}
+
+func (zm *zoneImage) ColorModel() color.Model {
+	return zm.parent.ColorModel()
+}
+
+func (zm *zoneImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, zm.width, zm.height)
+}
+
+func (zm *zoneImage) At(x, y int) color.Color {
+	p := zm.mapping[(y*zm.width+x)*zm.copies]
+	return zm.parent.At(p.X, p.Y)
+}
+
+func (zm *zoneImage) Set(x, y int, c color.Color) {
+	i := (y*zm.width + x) * zm.copies
+	for _, p := range zm.mapping[i : i+zm.copies] {
+		zm.parent.Set(p.X, p.Y, c)
+	}
+}
+
+// zoneWave holds the wave instance drawn in a single zone together with the zone image
+type zoneWave struct {
+	zone  Zone
+	wave  Wave
+	image *zoneImage
+}
+
+// LayoutWave draws a separate wave in every zone of the layout
+type LayoutWave struct {
+	layout         Layout
+	dataWidth      int
+	dataHeight     int
+	minVal, maxVal float64
+	paletteIndexes []byte
+
+	zones     []zoneWave
+	uncovered []image.Point
+	parent    draw.Image
+}
+
+// newLayoutWave checks the zones of the layout against the canvas and the already created waves
+// the zones can use any of the waves or presets but not other layouts
+func newLayoutWave(l Layout, screenWidth, screenHeight int) (*LayoutWave, error) {
+	if len(l.Zones) == 0 {
+		return nil, fmt.Errorf("layout %s has no zones", l.Name)
+	}
+	for i := range l.Zones {
+		z := &l.Zones[i]
+		if err := z.validate(screenWidth, screenHeight); err != nil {
+			return nil, fmt.Errorf("layout %s: %v", l.Name, err)
+		}
+		wi, ok := find(z.Wave)
+		if !ok {
+			return nil, fmt.Errorf("layout %s: unknown wave %q", l.Name, z.Wave)
+		}
+		if infos[wi].Type == layoutType {
+			return nil, fmt.Errorf("layout %s: zones can't use other layouts", l.Name)
+		}
+	}
+	return &LayoutWave{layout: l}, nil
+}
+
+// InitWave does the initial calculation of the reused variables in the draw loop
+// every zone gets its own instance of the wave which shares the parameters with the selectable one
+func (lw *LayoutWave) InitWave(screenWidth, screenHeight int, minVal, maxVal float64) {
+	lw.dataWidth = screenWidth
+	lw.dataHeight = screenHeight
+	lw.minVal, lw.maxVal = minVal, maxVal
+
+	covered := make([]bool, screenWidth*screenHeight)
+	for _, z := range lw.layout.Zones {
+		for y := z.Y; y < z.Y+z.Height; y++ {
+			for x := z.X; x < z.X+z.Width; x++ {
+				covered[y*screenWidth+x] = true
+			}
+		}
+	}
+	lw.uncovered = lw.uncovered[:0]
+	for i := range covered {
+		if !covered[i] {
+			lw.uncovered = append(lw.uncovered, image.Point{i % screenWidth, i / screenWidth})
+		}
+	}
+}
+
+// initZones creates the zone images on the first draw since the target image isn't known before that
+func (lw *LayoutWave) initZones(c draw.Image) {
+	lw.parent = c
+	lw.zones = make([]zoneWave, len(lw.layout.Zones))
+	for i, z := range lw.layout.Zones {
+		wi, _ := find(z.Wave)
+		r, _ := lookup(infos[wi].Type)
+
+		zm := newZoneImage(c, z)
+		zw := zoneWave{
+			zone:  z,
+			wave:  registry[r].new(params[wi]),
+			image: zm,
+		}
+		zw.wave.InitWave(zm.width, zm.height, lw.minVal, lw.maxVal)
+		lw.zones[i] = zw
+	}
+}
+
+// Draw creates a new canvas to be later rendered on the matrix
+func (lw *LayoutWave) Draw(c draw.Image, dmxData dmx.DMXData, data, dots []float64) {
+	if lw.parent != c {
+		lw.initZones(c)
+	}
+
+	for _, p := range lw.uncovered {
+		c.Set(p.X, p.Y, color.RGBA{0, 0, 0, 0})
+	}
+
+	for _, zw := range lw.zones {
+		// Every zone only gets its part of the spectrum
+		from := int(zw.zone.Bands[0] * float64(len(data)))
+		to := int(zw.zone.Bands[1] * float64(len(data)))
+		if to <= from {
+			to = from + 1
+		}
+		zw.wave.Draw(zw.image, dmxData, data[from:to], dots[from:to])
+	}
+}
+
+// This function draws a single pixel, the zones take care of their own mapping
+func (lw *LayoutWave) DrawPixels(c draw.Image, x, y int, clr color.RGBA) {
+	c.Set(x, y, clr)
+}
+
+func (lw *LayoutWave) GetDataSize() (int, int) {
+	return lw.dataWidth, lw.dataHeight
+}
+
+func (lw *LayoutWave) GetValueRange() (float64, float64) {
+	return lw.minVal, lw.maxVal
+}
+
+func (lw *LayoutWave) GetPaletteIndexes() []byte {
+	return lw.paletteIndexes
+}
+
+// layoutInfo returns the description of the layout as a selectable wave
+func layoutInfo(l Layout) modes.Info {
+	desc := l.Description
+	if desc == "" {
+		desc = fmt.Sprintf("Layout of %d zones", len(l.Zones))
+	}
+	return modes.Info{Name: l.Name, Type: layoutType, Description: desc}
+}
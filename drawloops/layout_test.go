@@ -0,0 +1,114 @@
+package drawloops
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestZoneCoverage(t *testing.T) {
+	frame := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	for _, size := range []image.Point{{8, 6}, {7, 5}} {
+		for _, rotation := range []int{0, 90, 180, 270} {
+			for flags := 0; flags < 16; flags++ {
+				z := Zone{X: 2, Y: 1, Width: size.X, Height: size.Y, Rotation: rotation,
+					FlipX: flags&1 > 0, FlipY: flags&2 > 0, MirrorX: flags&4 > 0, MirrorY: flags&8 > 0}
+				zi := newZoneImage(frame, z)
+
+				// Every pixel of the zone rectangle has to be covered, the even sized zones exactly once
+				seen := make(map[image.Point]bool)
+				for _, p := range zi.mapping {
+					if !p.In(image.Rect(z.X, z.Y, z.X+z.Width, z.Y+z.Height)) || seen[p] && size.X%2 == 0 {
+						t.Fatalf("%+v: pixel %v outside of the zone or mapped twice\n", z, p)
+					}
+					seen[p] = true
+				}
+				if len(seen) != z.Width*z.Height {
+					t.Errorf("%+v: coverage mismatch. Want: %v, Have: %v\n", z, z.Width*z.Height, len(seen))
+				}
+			}
+		}
+	}
+}
+
+func TestZoneMapping(t *testing.T) {
+	frame := image.NewRGBA(image.Rect(0, 0, 5, 5))
+	for _, tc := range []struct {
+		zone Zone
+		want []image.Point
+	}{
+		// All of the copies of the top left pixel of the zone content
+		{Zone{Width: 4, Height: 4, FlipX: true, MirrorY: true}, []image.Point{{3, 0}, {3, 3}}},
+		{Zone{Width: 4, Height: 4, FlipY: true, MirrorY: true}, []image.Point{{0, 3}, {0, 0}}},
+		{Zone{Width: 4, Height: 4, FlipX: true, MirrorX: true}, []image.Point{{1, 0}, {2, 0}}},
+		{Zone{Width: 4, Height: 4, FlipY: true, MirrorX: true}, []image.Point{{2, 3}, {1, 3}}},
+		{Zone{Width: 4, Height: 4, FlipX: true, FlipY: true, MirrorX: true, MirrorY: true},
+			[]image.Point{{1, 3}, {1, 0}, {2, 3}, {2, 0}}},
+		// The odd sizes start on the center line which is shared by both of the copies
+		{Zone{Width: 5, Height: 5, MirrorX: true}, []image.Point{{2, 0}, {2, 0}}},
+		{Zone{Width: 5, Height: 5, MirrorX: true, MirrorY: true}, []image.Point{{2, 0}, {2, 4}, {2, 0}, {2, 4}}},
+	} {
+		zi := newZoneImage(frame, tc.zone)
+		have := zi.mapping[:zi.copies]
+		if len(have) != len(tc.want) {
+			t.Errorf("%+v: mapping mismatch. Want: %v, Have: %v\n", tc.zone, tc.want, have)
+			continue
+		}
+		for i := range tc.want {
+			if have[i] != tc.want[i] {
+				t.Errorf("%+v: mapping mismatch. Want: %v, Have: %v\n", tc.zone, tc.want, have)
+				break
+			}
+		}
+	}
+
+	// The last column and row of the odd sized mirrored zones are drawn as well
+	zi := newZoneImage(frame, Zone{Width: 5, Height: 5, MirrorX: true})
+	if want, have := (image.Point{3, 5}), zi.Bounds().Size(); have != want {
+		t.Errorf("odd zone size mismatch. Want: %v, Have: %v\n", want, have)
+	}
+	if want, have := (image.Point{4, 0}), zi.mapping[2*zi.copies]; have != want {
+		t.Errorf("odd zone edge mismatch. Want: %v, Have: %v\n", want, have)
+	}
+}
+
+func TestZoneTransform(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	for _, tc := range []struct {
+		zone Zone
+		want []image.Point
+	}{
+		// The bottom left pixel of the zone content
+		{Zone{Width: 8, Height: 6}, []image.Point{{0, 5}}},
+		{Zone{Width: 8, Height: 6, Rotation: 90}, []image.Point{{0, 0}}},
+		{Zone{Width: 8, Height: 6, Rotation: 180}, []image.Point{{7, 0}}},
+		{Zone{Width: 8, Height: 6, Rotation: 270}, []image.Point{{7, 5}}},
+		{Zone{Width: 8, Height: 6, FlipX: true}, []image.Point{{7, 5}}},
+		{Zone{Width: 8, Height: 6, FlipY: true}, []image.Point{{0, 0}}},
+		{Zone{Width: 8, Height: 6, MirrorX: true}, []image.Point{{4, 5}, {3, 5}}},
+		{Zone{Width: 8, Height: 6, MirrorY: true}, []image.Point{{0, 2}, {0, 3}}},
+	} {
+		frame := image.NewRGBA(image.Rect(0, 0, 8, 6))
+		zi := newZoneImage(frame, tc.zone)
+		zi.Set(0, zi.Bounds().Dy()-1, red)
+
+		var have []image.Point
+		for y := 0; y < 6; y++ {
+			for x := 0; x < 8; x++ {
+				if frame.RGBAAt(x, y) == red {
+					have = append(have, image.Point{x, y})
+				}
+			}
+		}
+		if len(have) != len(tc.want) {
+			t.Errorf("%+v: pixel mismatch. Want: %v, Have: %v\n", tc.zone, tc.want, have)
+			continue
+		}
+		for _, p := range tc.want {
+			if frame.RGBAAt(p.X, p.Y) != red {
+				t.Errorf("%+v: pixel mismatch. Want: %v, Have: %v\n", tc.zone, tc.want, have)
+				break
+			}
+		}
+	}
+}
@@ -2,9 +2,9 @@ package drawloops
 
 import (
 	"image/color"
+	"image/draw"
 
 	"github.com/TFK1410/go-rpi-fftwave/dmx"
-	rgbmatrix "github.com/tfk1410/go-rpi-rgb-led-matrix"
 )
 
 // NoWave defines the values used for the display of the wave that are specific to this pattern type
@@ -22,7 +22,7 @@ func (nb *NoWave) InitWave(screenWidth, screenHeight int, minVal, maxVal float64
 }
 
 // Draw creates a new canvas to be later rendered on the matrix
-func (nb *NoWave) Draw(c *rgbmatrix.Canvas, dmxData dmx.DMXData, data, dots []float64) {
+func (nb *NoWave) Draw(c draw.Image, dmxData dmx.DMXData, data, dots []float64) {
 	// Set all matrix pixels to all black
 	for x := 0; x < nb.dataWidth; x++ {
 		for y := 0; y < nb.dataHeight; y++ {
@@ -32,7 +32,7 @@ func (nb *NoWave) Draw(c *rgbmatrix.Canvas, dmxData dmx.DMXData, data, dots []fl
 }
 
 // This function will mirror out a single pixel draw to multiple fields as required
-func (nb *NoWave) DrawPixels(c *rgbmatrix.Canvas, x, y int, clr color.RGBA) {
+func (nb *NoWave) DrawPixels(c draw.Image, x, y int, clr color.RGBA) {
 	c.Set(2*x, nb.dataHeight-1-y, clr)
 	c.Set(2*x+1, nb.dataHeight-1-y, clr)
 }
@@ -0,0 +1,343 @@
+package drawloops
+
+import (
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/TFK1410/go-rpi-fftwave/dmx"
+	"github.com/TFK1410/go-rpi-fftwave/modes"
+	"github.com/TFK1410/go-rpi-fftwave/palette"
+)
+
+// Behaviors available for the particle wave
+const (
+	ParticleFountain  = "fountain"
+	ParticleFireworks = "fireworks"
+	ParticleExplosion = "explosion"
+)
+
+// beatMaxAge is how long a triggered beat waits for the particle waves, the same as the longest frame step
+// so the beats triggered while no particle wave is shown don't fire late
+const beatMaxAge = 50 * time.Millisecond
+
+// beatGeneration and beatTime are the number and the wall clock time of the latest beat triggered from outside
+// of the sound, like with the MIDI pads, every particle wave compares the number with the last one it has seen
+var beatMu sync.Mutex
+var beatGeneration uint64
+var beatTime time.Time
+
+// TriggerBeat makes the particle waves fire on the next frame as if a kick was detected
+func TriggerBeat() {
+	beatMu.Lock()
+	defer beatMu.Unlock()
+	beatGeneration++
+	beatTime = time.Now()
+}
+
+// takeBeat reports whether a fresh beat was triggered since the last call of this wave
+func (pw *ParticleWave) takeBeat() bool {
+	beatMu.Lock()
+	defer beatMu.Unlock()
+	if beatGeneration == pw.beatSeen {
+		return false
+	}
+	pw.beatSeen = beatGeneration
+	return time.Since(beatTime) <= beatMaxAge
+}
+
+type particle struct {
+	x, y, vx, vy float64
+	life, ttl    float64
+	clr          color.RGBA
+}
+
+// emitter spawns particles from a single point in a cone of directions
+type emitter struct {
+	x, y         float64
+	angle        float64 // direction of the cone center in radians, 0 points right, -Pi/2 points up
+	spread       float64 // full cone width in radians
+	minSpeed     float64
+	maxSpeed     float64
+	minTTL       float64
+	maxTTL       float64
+	accumulation float64 // fractional particles carried over to the next frame
+}
+
+// ParticleWave defines the values used for the display of the wave that are specific to this pattern type
+type ParticleWave struct {
+	params   *modes.Params
+	version  uint64
+	behavior string
+	gravity  float64
+
+	dataWidth      int
+	dataHeight     int
+	minVal, maxVal float64
+	paletteIndexes []byte
+
+	particles []particle
+	live      int
+	emitters  []emitter
+	accum     []uint32
+	rng       *rand.Rand
+	lastDraw  time.Time
+
+	bassAvg  float64
+	cooldown float64
+	beatSeen uint64
+}
+
+// InitWave does the initial calculation of the reused variables in the draw loop
+func (pw *ParticleWave) InitWave(screenWidth, screenHeight int, minVal, maxVal float64) {
+	pw.dataWidth = screenWidth
+	pw.dataHeight = screenHeight
+	pw.minVal, pw.maxVal = minVal, maxVal
+	pw.accum = make([]uint32, screenWidth*screenHeight*3)
+	pw.rng = rand.New(rand.NewSource(1))
+	pw.setup()
+}
+
+// setup creates the particle pool and the emitters from the current parameters
+// the live particles stay on the screen, only the ones which don't fit in a smaller pool are dropped
+func (pw *ParticleWave) setup() {
+	pw.version = pw.params.Version()
+	pw.behavior = pw.params.String("behavior")
+	pw.gravity = pw.params.Float("gravity")
+
+	if n := pw.params.Int("maxParticles"); n != len(pw.particles) {
+		particles := make([]particle, n)
+		pw.live = copy(particles, pw.particles[:pw.live])
+		pw.particles = particles
+	}
+
+	w, h := float64(pw.dataWidth), float64(pw.dataHeight)
+	switch pw.behavior {
+	case ParticleFountain:
+		// Three nozzles at the bottom, one for each of the sound bands
+		pw.emitters = []emitter{
+			{x: w / 6, y: h - 1, angle: -math.Pi / 2, spread: 0.5, minSpeed: h * 0.6, maxSpeed: h * 1.3, minTTL: 0.8, maxTTL: 1.6},
+			{x: w / 2, y: h - 1, angle: -math.Pi / 2, spread: 0.5, minSpeed: h * 0.6, maxSpeed: h * 1.3, minTTL: 0.8, maxTTL: 1.6},
+			{x: 5 * w / 6, y: h - 1, angle: -math.Pi / 2, spread: 0.5, minSpeed: h * 0.6, maxSpeed: h * 1.3, minTTL: 0.8, maxTTL: 1.6},
+		}
+	default:
+		// Fireworks and explosions use a single omnidirectional emitter which is moved around on every beat
+		pw.emitters = []emitter{
+			{x: w / 2, y: h / 2, spread: 2 * math.Pi, minSpeed: h * 0.2, maxSpeed: h * 0.8, minTTL: 0.6, maxTTL: 1.4},
+		}
+	}
+}
+
+// Draw creates a new canvas to be later rendered on the matrix
+func (pw *ParticleWave) Draw(c draw.Image, dmxData dmx.DMXData, data, dots []float64) {
+	if pw.params.Version() != pw.version {
+		pw.setup()
+	}
+
+	now := currentTime()
+	dt := now.Sub(pw.lastDraw).Seconds()
+	pw.lastDraw = now
+	// Cap the time step so that a pause in drawing doesn't teleport the particles
+	if dt > 0.05 || dt < 0 {
+		dt = 0.05
+	}
+
+	bass, mid, treble := pw.bandEnergies(data)
+	triggered := pw.takeBeat()
+	beat := pw.detectBeat(bass, dt) || triggered
+
+	pw.emit(dmxData, bass, mid, treble, beat, dt)
+	pw.step(dt)
+	pw.render(c)
+}
+
+// bandEnergies returns the max value of each of the three sound bands normalized to 0..1
+func (pw *ParticleWave) bandEnergies(data []float64) (float64, float64, float64) {
+	var bands [3]float64
+	for i := range data {
+		b := 3 * i / len(data)
+		if bands[b] < data[i] {
+			bands[b] = data[i]
+		}
+	}
+	for i := range bands {
+		bands[i] = (bands[i] - pw.minVal) / (pw.maxVal - pw.minVal)
+		if bands[i] < 0 {
+			bands[i] = 0
+		} else if bands[i] > 1 {
+			bands[i] = 1
+		}
+	}
+	return bands[0], bands[1], bands[2]
+}
+
+// detectBeat compares the bass energy to its running average to find sudden kicks
+func (pw *ParticleWave) detectBeat(bass, dt float64) bool {
+	pw.cooldown -= dt
+	beat := pw.cooldown <= 0 && bass > 0.3 && bass > pw.bassAvg*1.25
+	if beat {
+		pw.cooldown = 0.15
+	}
+	pw.bassAvg = 0.9*pw.bassAvg + 0.1*bass
+	return beat
+}
+
+// emit spawns the new particles for this frame depending on the selected behavior
+func (pw *ParticleWave) emit(dmxData dmx.DMXData, bass, mid, treble float64, beat bool, dt float64) {
+	switch pw.behavior {
+	case ParticleFountain:
+		energies := [3]float64{bass, mid, treble}
+		for i := range pw.emitters {
+			// The spawn rate follows the band energy, up to 400 particles per second per nozzle
+			pw.emitters[i].accumulation += energies[i] * energies[i] * 400 * dt
+			n := int(pw.emitters[i].accumulation)
+			pw.emitters[i].accumulation -= float64(n)
+			pw.spawn(&pw.emitters[i], n, pw.particleColor(dmxData, energies[i], bass, mid, treble))
+		}
+	case ParticleFireworks:
+		if !beat {
+			return
+		}
+		// Rockets burst somewhere in the upper half of the screen
+		e := &pw.emitters[0]
+		e.x = float64(pw.dataWidth) * (0.15 + 0.7*pw.rng.Float64())
+		e.y = float64(pw.dataHeight) * (0.15 + 0.35*pw.rng.Float64())
+		pw.spawn(e, 40+int(bass*160), pw.particleColor(dmxData, pw.rng.Float64(), bass, mid, treble))
+	case ParticleExplosion:
+		if !beat {
+			return
+		}
+		e := &pw.emitters[0]
+		e.x = float64(pw.dataWidth)/2 - 0.5
+		e.y = float64(pw.dataHeight)/2 - 0.5
+		e.maxSpeed = float64(pw.dataWidth) * (0.3 + bass)
+		pw.spawn(e, 80+int(bass*320), pw.particleColor(dmxData, bass, bass, mid, treble))
+	}
+}
+
+// particleColor picks the color of the spawned particles
+// DMX color and palette take priority, otherwise the color is a mix of the band energies
+func (pw *ParticleWave) particleColor(dmxData dmx.DMXData, pos, bass, mid, treble float64) color.RGBA {
+	if dmxData.Color.A > 0 {
+		return dmxData.Color
+	}
+	if dmxData.ColorPalette > 0 {
+		return palette.Palettes[dmxData.ColorPalette][byte(pos*255)]
+	}
+
+	// Scale the band mix so that the strongest band is at full intensity
+	mx := math.Max(bass, math.Max(mid, treble))
+	if mx == 0 {
+		return color.RGBA{255, 255, 255, 255}
+	}
+	return color.RGBA{uint8(255 * bass / mx), uint8(255 * mid / mx), uint8(255 * treble / mx), 255}
+}
+
+// spawn creates up to n new particles from the emitter, limited by the free space in the pool
+func (pw *ParticleWave) spawn(e *emitter, n int, clr color.RGBA) {
+	for i := 0; i < n && pw.live < len(pw.particles); i++ {
+		angle := e.angle + (pw.rng.Float64()-0.5)*e.spread
+		speed := e.minSpeed + pw.rng.Float64()*(e.maxSpeed-e.minSpeed)
+		ttl := e.minTTL + pw.rng.Float64()*(e.maxTTL-e.minTTL)
+		pw.particles[pw.live] = particle{
+			x:   e.x,
+			y:   e.y,
+			vx:  math.Cos(angle) * speed,
+			vy:  math.Sin(angle) * speed,
+			ttl: ttl,
+			clr: clr,
+		}
+		pw.live++
+	}
+}
+
+// step moves every live particle and removes the expired ones by swapping them with the last live one
+func (pw *ParticleWave) step(dt float64) {
+	gravity := pw.gravity
+	drag := 1.0
+	if pw.behavior == ParticleExplosion {
+		// Explosions float outwards slowing down instead of falling
+		gravity = 0
+		drag = math.Pow(0.2, dt)
+	}
+
+	w, h := float64(pw.dataWidth), float64(pw.dataHeight)
+	for i := 0; i < pw.live; {
+		p := &pw.particles[i]
+		p.life += dt
+		p.vx *= drag
+		p.vy = p.vy*drag + gravity*dt
+		p.x += p.vx * dt
+		p.y += p.vy * dt
+
+		if p.life >= p.ttl || p.x < 0 || p.x >= w || p.y >= h || p.y < -h {
+			pw.live--
+			pw.particles[i] = pw.particles[pw.live]
+			continue
+		}
+		i++
+	}
+}
+
+// render blends all of the particles additively and draws the whole area to the canvas
+// the sums are 32 bit so the hundreds of particles of a burst on the same pixel don't overflow
+func (pw *ParticleWave) render(c draw.Image) {
+	for i := range pw.accum {
+		pw.accum[i] = 0
+	}
+
+	for i := 0; i < pw.live; i++ {
+		p := &pw.particles[i]
+		x, y := int(p.x), int(p.y)
+		if y < 0 {
+			continue
+		}
+		// Particles fade out towards the end of their life
+		fade := 1 - p.life/p.ttl
+		offset := (y*pw.dataWidth + x) * 3
+		pw.accum[offset] += uint32(float64(p.clr.R) * fade)
+		pw.accum[offset+1] += uint32(float64(p.clr.G) * fade)
+		pw.accum[offset+2] += uint32(float64(p.clr.B) * fade)
+	}
+
+	for y := 0; y < pw.dataHeight; y++ {
+		for x := 0; x < pw.dataWidth; x++ {
+			offset := (y*pw.dataWidth + x) * 3
+			r, g, b := pw.accum[offset], pw.accum[offset+1], pw.accum[offset+2]
+			if r == 0 && g == 0 && b == 0 {
+				// leave the pixel empty for the background
+				c.Set(x, y, color.RGBA{0, 0, 0, 0})
+				continue
+			}
+			pw.DrawPixels(c, x, y, color.RGBA{saturate(r), saturate(g), saturate(b), 255})
+		}
+	}
+}
+
+// saturate clips the additive blending result to a single byte
+func saturate(v uint32) uint8 {
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+// This function draws a single pixel, the particles are already in screen space
+func (pw *ParticleWave) DrawPixels(c draw.Image, x, y int, clr color.RGBA) {
+	c.Set(x, y, clr)
+}
+
+func (pw *ParticleWave) GetDataSize() (int, int) {
+	return pw.dataWidth, pw.dataHeight
+}
+
+func (pw *ParticleWave) GetValueRange() (float64, float64) {
+	return pw.minVal, pw.maxVal
+}
+
+func (pw *ParticleWave) GetPaletteIndexes() []byte {
+	return pw.paletteIndexes
+}
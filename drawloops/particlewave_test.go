@@ -0,0 +1,84 @@
+package drawloops
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/TFK1410/go-rpi-fftwave/modes"
+)
+
+func newTestParticleWave(t *testing.T) (*ParticleWave, *modes.Params) {
+	r, ok := lookup("particles")
+	if !ok {
+		t.Fatal("particles wave isn't registered")
+	}
+	p := modes.NewParams(registry[r].info.Params)
+	pw := registry[r].new(p).(*ParticleWave)
+	pw.InitWave(8, 8, 0, 1)
+	return pw, p
+}
+
+func TestParticleBlend(t *testing.T) {
+	pw, _ := newTestParticleWave(t)
+
+	// A burst spawns hundreds of particles on the center pixel, their sum of 258 * 255 would wrap around 16 bits to 254
+	for i := 0; i < 258; i++ {
+		pw.particles[i] = particle{x: 4, y: 4, ttl: 1, clr: color.RGBA{255, 0, 0, 255}}
+	}
+	pw.live = 258
+	frame := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	pw.render(frame)
+	if have := frame.RGBAAt(4, 4); have != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("blended pixel mismatch. Want: %v, Have: %v\n", color.RGBA{255, 0, 0, 255}, have)
+	}
+}
+
+func TestParticleBeat(t *testing.T) {
+	a, _ := newTestParticleWave(t)
+	b, _ := newTestParticleWave(t)
+
+	// Every particle wave fires on the triggered beat once
+	TriggerBeat()
+	if !a.takeBeat() || !b.takeBeat() {
+		t.Errorf("beat not seen by every wave\n")
+	}
+	if a.takeBeat() {
+		t.Errorf("beat seen twice\n")
+	}
+
+	// A beat from before the last frame is dropped
+	TriggerBeat()
+	beatMu.Lock()
+	beatTime = beatTime.Add(-2 * beatMaxAge)
+	beatMu.Unlock()
+	if a.takeBeat() {
+		t.Errorf("old beat fired\n")
+	}
+}
+
+func TestParticleReload(t *testing.T) {
+	pw, p := newTestParticleWave(t)
+	for i := 0; i < 10; i++ {
+		pw.particles[i] = particle{x: 4, y: 4, ttl: 1}
+	}
+	pw.live = 10
+
+	// A changed parameter keeps the particles on the screen
+	if err := p.Set("gravity", "10"); err != nil {
+		t.Fatal(err)
+	}
+	pw.setup()
+	if pw.live != 10 || pw.gravity != 10 {
+		t.Errorf("reloaded particles mismatch. Want: %v %v, Have: %v %v\n", 10, 10, pw.live, pw.gravity)
+	}
+
+	// A smaller pool keeps the particles which fit in it
+	if err := p.Set("maxParticles", "4"); err != nil {
+		t.Fatal(err)
+	}
+	pw.setup()
+	if pw.live != 4 || len(pw.particles) != 4 {
+		t.Errorf("resized pool mismatch. Want: %v %v, Have: %v %v\n", 4, 4, pw.live, len(pw.particles))
+	}
+}
@@ -2,9 +2,9 @@ package drawloops
 
 import (
 	"image/color"
+	"image/draw"
 
 	"github.com/TFK1410/go-rpi-fftwave/dmx"
-	rgbmatrix "github.com/tfk1410/go-rpi-rgb-led-matrix"
 )
 
 // QuadWaveSideways defines the values used for the display of the wave that are specific to this pattern type
@@ -24,12 +24,12 @@ func (m *QuadWaveSideways) InitWave(screenWidth, screenHeight int, minVal, maxVa
 }
 
 // Draw creates a new canvas to be later rendered on the matrix
-func (m *QuadWaveSideways) Draw(c *rgbmatrix.Canvas, dmxData dmx.DMXData, data, dots []float64) {
+func (m *QuadWaveSideways) Draw(c draw.Image, dmxData dmx.DMXData, data, dots []float64) {
 	commonDraw(m, c, dmxData, data, dots)
 }
 
 // This function will mirror out a single pixel draw to multiple fields as required
-func (m *QuadWaveSideways) DrawPixels(c *rgbmatrix.Canvas, x, y int, clr color.RGBA) {
+func (m *QuadWaveSideways) DrawPixels(c draw.Image, x, y int, clr color.RGBA) {
 	c.Set(y, m.dataWidth-1-x, clr)
 	c.Set(m.dataHeight*2-1-y, m.dataWidth-1-x, clr)
 	c.Set(y, m.dataWidth+x, clr)
@@ -0,0 +1,84 @@
+package drawloops
+
+import (
+	"fmt"
+
+	"github.com/TFK1410/go-rpi-fftwave/modes"
+	"github.com/TFK1410/go-rpi-fftwave/palette"
+)
+
+type registration struct {
+	info modes.Info
+	new  func(*modes.Params) Wave
+}
+
+// registry keeps the wave types in the order of registration
+// this order defines the index used by the DMX display mode so new waves should only be added at the end
+var registry []registration
+
+// Register adds a new wave type to the registry under a unique name
+func Register(info modes.Info, new func(*modes.Params) Wave) {
+	for _, r := range registry {
+		if r.info.Name == info.Name {
+			panic(fmt.Sprintf("drawloops: wave %q registered twice", info.Name))
+		}
+	}
+	info.Type = info.Name
+	registry = append(registry, registration{info: info, new: new})
+}
+
+// Available returns the descriptions of all the registered wave types in their index order
+func Available() []modes.Info {
+	out := make([]modes.Info, len(registry))
+	for i, r := range registry {
+		out[i] = r.info
+	}
+	return out
+}
+
+// lookup finds the index of the wave type registered under the given name
+func lookup(name string) (int, bool) {
+	for i := range registry {
+		if registry[i].info.Name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func init() {
+	Register(modes.Info{Name: "single", Description: "Single spectrum over the whole screen"},
+		func(*modes.Params) Wave { return &SingleWave{} })
+	Register(modes.Info{Name: "single-mirrored", Description: "Spectrum growing from the bottom and its mirror from the top"},
+		func(*modes.Params) Wave { return &SingleWaveMirrored{} })
+	Register(modes.Info{Name: "dual", Description: "Single spectrum with double width bars"},
+		func(*modes.Params) Wave { return &DualWave{} })
+	Register(modes.Info{Name: "mirror", Description: "Spectrum mirrored from the center to the sides"},
+		func(*modes.Params) Wave { return &MirrorWave{} })
+	Register(modes.Info{Name: "quad", Description: "Spectrum mirrored from the center to all four corners"},
+		func(*modes.Params) Wave { return &QuadWave{} })
+	Register(modes.Info{Name: "quad-sideways", Description: "Spectrum mirrored from the center to all four corners with the bars laid horizontally"},
+		func(*modes.Params) Wave { return &QuadWaveSideways{} })
+	Register(modes.Info{Name: "none", Description: "Blank screen leaving the whole area to the background"},
+		func(*modes.Params) Wave { return &NoWave{} })
+	Register(modes.Info{
+		Name:        "particles",
+		Description: "Particle system driven by the band energies and bass beats",
+		Params: []modes.Param{
+			{Name: "behavior", Description: "Way the particles are spawned", Kind: modes.Choice, Default: ParticleFountain,
+				Choices: []string{ParticleFountain, ParticleFireworks, ParticleExplosion}},
+			{Name: "maxParticles", Description: "Max number of particles alive at the same time", Kind: modes.Int, Default: "1024", Min: 1, Max: 65536},
+			{Name: "gravity", Description: "Downward acceleration in pixels per second squared", Kind: modes.Float, Default: "40", Min: -1000, Max: 1000},
+		},
+	}, func(p *modes.Params) Wave { return &ParticleWave{params: p} })
+	Register(modes.Info{
+		Name:        "fire",
+		Description: "Doom style fire fed from the bottom by the spectrum",
+		Params: []modes.Param{
+			{Name: "palette", Description: "Index of the flame palette, a palette selected over DMX takes priority", Kind: modes.Int, Default: "0",
+				Min: 0, Max: float64(len(palette.Palettes) - 1)},
+			{Name: "cooling", Description: "Multiplier of the heat loss per row", Kind: modes.Float, Default: "1", Min: 0.1, Max: 10},
+			{Name: "updateRate", Description: "Number of times per second the flames move up", Kind: modes.Int, Default: "60", Min: 1, Max: 1000},
+		},
+	}, func(p *modes.Params) Wave { return &FireWave{params: p} })
+}
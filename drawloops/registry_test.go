@@ -0,0 +1,40 @@
+package drawloops
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TFK1410/go-rpi-fftwave/modes"
+)
+
+func TestRegistryOrder(t *testing.T) {
+	// The DMX display mode selects the waves by their index so the waves that were already there keep their place
+	// and the new ones only follow them
+	want := []string{"single", "single-mirrored", "dual", "mirror", "quad", "quad-sideways", "none", "particles", "fire"}
+	have := Available()
+	if len(have) < len(want) {
+		t.Fatalf("wave count mismatch. Want: %v, Have: %v\n", len(want), len(have))
+	}
+	for i, name := range want {
+		if have[i].Name != name {
+			t.Errorf("wave %d mismatch. Want: %v, Have: %v\n", i, name, have[i].Name)
+		}
+	}
+}
+
+func TestCheckPresets(t *testing.T) {
+	for _, tc := range []struct {
+		presets []modes.Preset
+		want    string
+	}{
+		{[]modes.Preset{{Name: "big", Type: "single"}}, ""},
+		{[]modes.Preset{{Name: "single", Type: "single"}}, "uses the name of a wave type"},
+		{[]modes.Preset{{Name: "big", Type: "single"}, {Name: "big", Type: "dual"}}, "is defined twice"},
+		{[]modes.Preset{{Name: "big", Type: "spiral"}}, "unknown wave"},
+	} {
+		err := CheckPresets(tc.presets)
+		if (err == nil) != (tc.want == "") || err != nil && !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: error mismatch. Want: %v, Have: %v\n", tc.presets, tc.want, err)
+		}
+	}
+}
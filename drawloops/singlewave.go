@@ -2,9 +2,9 @@ package drawloops
 
 import (
 	"image/color"
+	"image/draw"
 
 	"github.com/TFK1410/go-rpi-fftwave/dmx"
-	rgbmatrix "github.com/tfk1410/go-rpi-rgb-led-matrix"
 )
 
 // SingleWave defines the values used for the display of the wave that are specific to this pattern type
@@ -24,12 +24,12 @@ func (m *SingleWave) InitWave(screenWidth, screenHeight int, minVal, maxVal floa
 }
 
 // Draw creates a new canvas to be later rendered on the matrix
-func (m *SingleWave) Draw(c *rgbmatrix.Canvas, dmxData dmx.DMXData, data, dots []float64) {
+func (m *SingleWave) Draw(c draw.Image, dmxData dmx.DMXData, data, dots []float64) {
 	commonDraw(m, c, dmxData, data, dots)
 }
 
 // This function will mirror out a single pixel draw to multiple fields as required
-func (m *SingleWave) DrawPixels(c *rgbmatrix.Canvas, x, y int, clr color.RGBA) {
+func (m *SingleWave) DrawPixels(c draw.Image, x, y int, clr color.RGBA) {
 	c.Set(x, m.dataHeight-1-y, clr)
 }
 
@@ -2,11 +2,11 @@ package drawloops
 
 import (
 	"image/color"
+	"image/draw"
 	"math"
 
 	"github.com/TFK1410/go-rpi-fftwave/dmx"
 	"github.com/TFK1410/go-rpi-fftwave/palette"
-	rgbmatrix "github.com/tfk1410/go-rpi-rgb-led-matrix"
 )
 
 // Linspace function returns a slice of n values which are linearly spread out between a and b.
@@ -123,7 +123,7 @@ func getPaletteOffsetWrap(offset int) byte {
 	}
 }
 
-func commonDraw(m Wave, c *rgbmatrix.Canvas, dmxData dmx.DMXData, data, dots []float64) {
+func commonDraw(m Wave, c draw.Image, dmxData dmx.DMXData, data, dots []float64) {
 	var maxvalue, maxdot float64
 	dataWidth, dataHeight := m.GetDataSize()
 	minVal, maxVal := m.GetValueRange()
@@ -12,25 +12,65 @@ import (
 
 const SoundEmulatorENV = "SOUND_EMULATOR"
 
+// fftAnalyzer turns a chunk of samples into the FFT values in logarithmic bins, one per display column
+type fftAnalyzer struct {
+	compData       []complex128
+	realData       []float64
+	plan           *fftw.Plan1d
+	fftBins        []int
+	fftBinFloating []float64
+	out            []float64
+}
+
+// newFFTAnalyzer generates a plan for FFTW for chunks of bfz samples
+// the analyzer has to be freed after use
+func newFFTAnalyzer(bfz, binCount int) *fftAnalyzer {
+	a := &fftAnalyzer{
+		compData: make([]complex128, bfz),
+		realData: make([]float64, bfz),
+		out:      make([]float64, binCount),
+	}
+	a.plan = fftw.NewPlan1d(a.compData, false, true)
+
+	// Calculate the logarithmic bins
+	a.fftBins, a.fftBinFloating = calculateBins(cfg.Display.MinHz, cfg.Display.MaxHz, binCount, cfg.SampleRate, bfz)
+	return a
+}
+
+// analyze calculates the FFT of the samples, the returned slice is reused by the next call
+func (a *fftAnalyzer) analyze(data []int16) []float64 {
+	// Convert int16 data into complex128
+	for i := range data {
+		a.compData[i] = complex(float64(data[i]), 0)
+	}
+
+	// Execute the plan
+	a.plan.Execute()
+
+	// Convert the data to real values
+	for i := range a.compData {
+		a.realData[i] = cmplx.Abs(a.compData[i])
+	}
+
+	// Convert the linear data to logarithmic space
+	fftToBins(a.fftBins, a.fftBinFloating, a.realData, a.out)
+	return a.out
+}
+
+func (a *fftAnalyzer) free() {
+	a.plan.Free()
+}
+
 // initFFT function is a start for the goroutine handling the FFT part of the application.
 // bfz is the number of elements in a single FFT call.
 // Should be the same as the ring buffer size.
 func initFFT(bfz, binCount int, fftOutChan chan<- []float64, ss SoundSync) error {
 	defer ss.wg.Done()
-	// start := time.Now()
 
-	// Generate a plan for FFTW
 	var r *soundbuffer.SoundBuffer
 	var data []int16
-	compData := make([]complex128, bfz)
-	realData := make([]float64, bfz)
-	plan := fftw.NewPlan1d(compData, false, true)
-	defer plan.Free()
-
-	// Calculate the logarithmic bins
-	fftBins, fftBinFloating := calculateBins(cfg.Display.MinHz, cfg.Display.MaxHz, binCount, cfg.SampleRate, 1<<cfg.FFT.ChunkPower)
-
-	outFFT := make([]float64, binCount)
+	analyzer := newFFTAnalyzer(bfz, binCount)
+	defer analyzer.free()
 
 	freq := 10
 
@@ -42,11 +82,6 @@ func initFFT(bfz, binCount int, fftOutChan chan<- []float64, ss SoundSync) error
 		case r = <-ss.sb:
 		}
 
-		// elapsed := time.Since(start)
-		// log.Printf("Sleep time: %v\n", elapsed)
-		// start = time.Now()
-
-		// Convert int16 data into complex128
 		data = r.Sound()
 
 		if os.Getenv(SoundEmulatorENV) == "1" {
@@ -59,29 +94,13 @@ func initFFT(bfz, binCount int, fftOutChan chan<- []float64, ss SoundSync) error
 			}
 		}
 
-		for i := range data {
-			compData[i] = complex(float64(data[i]), 0)
-		}
-
-		// Execute the plan
-		plan.Execute()
-
-		// Convert the data to real values
-		for i := range compData {
-			realData[i] = cmplx.Abs(compData[i])
-		}
-
-		// Convert the linear data to logarithmic space
-		fftToBins(fftBins, fftBinFloating, realData, outFFT)
+		outFFT := analyzer.analyze(data)
 
 		// Send the new data to the smoothing goroutine without blocking
 		select {
 		case fftOutChan <- outFFT:
 		default:
 		}
-
-		// elapsed = time.Since(start)
-		// log.Printf("Execution time: %v\n", elapsed)
 	}
 }
 
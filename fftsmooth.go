@@ -10,10 +10,22 @@ import (
 	"github.com/TFK1410/go-rpi-fftwave/dmx"
 	"github.com/TFK1410/go-rpi-fftwave/drawloops"
 	"github.com/TFK1410/go-rpi-fftwave/lyricsoverlay"
-	rgbmatrix "github.com/tfk1410/go-rpi-rgb-led-matrix"
+	"github.com/TFK1410/go-rpi-fftwave/output"
+	"github.com/TFK1410/go-rpi-fftwave/render"
 )
 
-func initFFTSmooth(c *rgbmatrix.Canvas, wavechan <-chan drawloops.Wave, backgroundchan <-chan backgroundloops.BackgroundLoop, fftOutChan <-chan []float64, dmxData *dmx.DMXData, ldc *lyricsoverlay.LyricDrawContext, wg *sync.WaitGroup, quit <-chan struct{}) {
+// renderConfig converts the display settings from the config to the renderer settings
+func renderConfig(cfg *Configuration) render.Config {
+	return render.Config{
+		SmoothCurve:  cfg.Display.FFTSmoothCurve,
+		DotHangTime:  time.Duration(cfg.WhiteDot.HangTime * float64(time.Second)),
+		DotDropSpeed: cfg.WhiteDot.DropSpeed,
+		HistoryCount: cfg.SoundEnergy.HistoryCount,
+	}
+}
+
+// initFFTSmooth renders a frame from the latest FFT data with every tick and passes the finished frame on to the sink
+func initFFTSmooth(r *render.Renderer, sink output.Sink, wavechan <-chan drawloops.Wave, backgroundchan <-chan backgroundloops.BackgroundLoop, fftOutChan <-chan []float64, dmxState *dmx.State, ldc *lyricsoverlay.LyricDrawContext, wg *sync.WaitGroup, quit <-chan struct{}) {
 	defer wg.Done()
 
 	// Wait for the first batch of FFT data
@@ -27,19 +39,6 @@ func initFFTSmooth(c *rgbmatrix.Canvas, wavechan <-chan drawloops.Wave, backgrou
 	// Create a loop ticker that will try to keep the display in the specified refresh rate
 	ticker := time.Tick(time.Second / time.Duration(cfg.Display.RefreshRate))
 
-	// Create the buffer for the smoothed out FFT data to be displayed
-	smoothFFT := make([]float64, c.Bounds().Dx())
-
-	// Setup the white dot buffers and timers
-	dotsValue := make([]float64, c.Bounds().Dx())
-	dotsTimeLeft := make([]time.Duration, c.Bounds().Dx())
-	dotsHangTime := time.Duration(cfg.WhiteDot.HangTime * float64(time.Second))
-	var start time.Time
-	var elapsed time.Duration
-
-	var soundTriBandMax backgroundloops.SoundEnergyTriBand
-	soundTriBandMaxHistory := make([]backgroundloops.SoundEnergyTriBand, cfg.SoundEnergy.HistoryCount)
-
 	// Wait for the first wave display type to be selected
 	var wave drawloops.Wave
 	background := backgroundloops.GetFirstBackgroundLoop()
@@ -51,7 +50,6 @@ func initFFTSmooth(c *rgbmatrix.Canvas, wavechan <-chan drawloops.Wave, backgrou
 	}
 
 	var dispMode, backMode int
-	// looptimes := make([]time.Duration, 1000)
 
 	for {
 		select {
@@ -67,111 +65,26 @@ func initFFTSmooth(c *rgbmatrix.Canvas, wavechan <-chan drawloops.Wave, backgrou
 		case <-ticker:
 		}
 
+		// A single snapshot per frame keeps the wave, the background and the colors in agreement
+		dmxData := dmxState.Snapshot()
 		if dispMode != int(dmxData.DisplayMode) {
 			dispMode = int(dmxData.DisplayMode)
 			wave = drawloops.GetWaveNum(dispMode)
 		}
-		if backMode != int(dmxData.DisplayMode) {
+		if backMode != int(dmxData.BackgroundMode) {
 			backMode = int(dmxData.BackgroundMode)
 			background = backgroundloops.GetBackgroundLoopNum(backMode)
 		}
-		// looptime := time.Now()
-
-		// Calculate the smoothed FFT values and the sound energy
-		soundTriBandMax.Bass, soundTriBandMax.Mid, soundTriBandMax.Treble = 0, 0, 0
-		// soundEnergy = 0
-		for i := range smoothFFT {
-			smoothFFT[i] = cfg.Display.FFTSmoothCurve*smoothFFT[i] + (1-cfg.Display.FFTSmoothCurve)*curFFT[i]
-			bandIndex := 3 * i / len(smoothFFT)
-			switch bandIndex {
-			case 0:
-				if soundTriBandMax.Bass < smoothFFT[i] {
-					soundTriBandMax.Bass = smoothFFT[i]
-				}
-			case 1:
-				if soundTriBandMax.Mid < smoothFFT[i] {
-					soundTriBandMax.Mid = smoothFFT[i]
-				}
-			case 2:
-				if soundTriBandMax.Treble < smoothFFT[i] {
-					soundTriBandMax.Treble = smoothFFT[i]
-				}
-			}
-		}
-		// fmt.Println(soundTriBandMax.Bass, soundTriBandMax.Mid, soundTriBandMax.Treble)
-
-		elapsed = time.Since(start)
-		start = time.Now()
-		soundTriBandMax.Tm = start
-
-		// Add the current sound energy to the history buffer
-		copy(soundTriBandMaxHistory[1:], soundTriBandMaxHistory[0:len(soundTriBandMaxHistory)-2])
-		soundTriBandMaxHistory[0] = soundTriBandMax
-
-		// Calculate the current state of the white dots
-		whiteDotCalc(dotsValue, dotsHangTime, dotsTimeLeft, smoothFFT, elapsed)
-
-		// Generate the current canvas to be displayed
-		wave.Draw(c, *dmxData, smoothFFT, dotsValue)
-
-		// Generate the current background canvas to be displayed
-		background.Draw(c, *dmxData, soundTriBandMaxHistory)
 
+		var overlay *image.RGBA
 		if dmxData.LyricsDMXInfo > 0 {
-			overlay := ldc.GetImage()
-			// overlay := image.NewRGBA(image.Rect(0, 0, c.Bounds().Dx(), c.Bounds().Dy()))
-			// draw.Draw(overlay, overlay.Bounds(), &image.Uniform{color.White}, image.Point{0, 0}, draw.Src)
-
-			// starttest := time.Now()
-			overlayImage(c, overlay)
-			// draw.Draw(c, overlay.Bounds(), overlay, image.Point{0, 0}, draw.Over)
-			// log.Println("Elapsed: ", time.Since(starttest))
+			overlay = ldc.GetImage()
 		}
-		// Call the main render of the canvas
-		c.Render()
-
-		// fmt.Printf("Elapsed time: %v\tSound Energy: %.2f\n", elapsed, soundEnergy)
-		// copy(looptimes[1:],looptimes[:len(looptimes)-1])
-		// looptimes[0] = time.Since(looptime)
-		// var average, avgcount time.Duration
-
-		// for i := 0; i < len(looptimes); i++ {
-		//	if looptimes[i] == 0 {
-		//		break
-		//	}
-		//	average += looptimes[i]
-		//	avgcount++
-		// }
-		// fmt.Printf("Elapsed time average: %v\n", average / time.Duration(avgcount))
-	}
-}
-
-func overlayImage(c *rgbmatrix.Canvas, overlay *image.RGBA) {
-	bounds := overlay.Bounds()
-	sizeX, sizeY := bounds.Dx(), bounds.Dy()
-
-	for x := 0; x < sizeX; x++ {
-		for y := 0; y < sizeY; y++ {
-			if overlay.Pix[overlay.PixOffset(x, y)+3] > 0 {
-				c.Set(x, y, overlay.At(x, y))
-			}
-		}
-	}
-}
 
-// Calculate the elapsed time for the white dots hang and lower the values if necessary
-func whiteDotCalc(dotsValue []float64, hangTime time.Duration, dotsTimeLeft []time.Duration, fft []float64, elapsed time.Duration) {
-	for i := range dotsValue {
-		if dotsValue[i] < fft[i] {
-			dotsValue[i] = fft[i]
-			dotsTimeLeft[i] = hangTime
-		} else {
-			if dotsTimeLeft[i] > 0 {
-				dotsTimeLeft[i] -= elapsed
-			}
-			if dotsTimeLeft[i] <= 0 {
-				dotsValue[i] -= elapsed.Seconds() * cfg.WhiteDot.DropSpeed
-			}
+		// Generate the current frame and pass it on to the outputs
+		frame := r.Render(time.Now(), curFFT, wave, background, dmxData, overlay)
+		if err := sink.Send(frame); err != nil {
+			log.Println("Sending the frame failed:", err)
 		}
 	}
 }
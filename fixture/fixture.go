@@ -0,0 +1,174 @@
+// Package fixture writes the fixture definitions of the visualizer for the lighting consoles
+// so it can be patched like any other fixture with the modes made of the DMX personalities
+package fixture
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TFK1410/go-rpi-fftwave/dmx"
+)
+
+// Definition describes the fixture, the modes are the DMX personalities
+type Definition struct {
+	Manufacturer string
+	Model        string
+	Modes        []dmx.Personality
+	// Waves, Backgrounds and Palettes are the names at the DMX indexes
+	Waves       []string
+	Backgrounds []string
+	Palettes    []string
+}
+
+// paramInfo holds the names of a parameter in the fixture formats
+type paramInfo struct {
+	name string
+	// continuous parameters get a single capability over the whole channel
+	continuous bool
+	// preset is the QLC+ channel preset of the coarse channel, the fine channel gets the Fine suffix
+	preset string
+	// attribute is the GDTF attribute
+	attribute string
+}
+
+var params = map[string]paramInfo{
+	dmx.ParamDisplayMode:        {name: "Wave", attribute: "Effects1"},
+	dmx.ParamBackgroundMode:     {name: "Background", attribute: "Effects2"},
+	dmx.ParamWhiteDots:          {name: "White dots", attribute: "Effects3"},
+	dmx.ParamColorPalette:       {name: "Palette", attribute: "ColorMacro1"},
+	dmx.ParamPaletteAngle:       {name: "Palette angle", continuous: true, attribute: "Effects1Adjust1"},
+	dmx.ParamPalettePhaseOffset: {name: "Palette offset", continuous: true, attribute: "Effects1Adjust2"},
+	dmx.ParamRed:                {name: "Red", continuous: true, preset: "IntensityRed", attribute: "ColorAdd_R"},
+	dmx.ParamGreen:              {name: "Green", continuous: true, preset: "IntensityGreen", attribute: "ColorAdd_G"},
+	dmx.ParamBlue:               {name: "Blue", continuous: true, preset: "IntensityBlue", attribute: "ColorAdd_B"},
+	dmx.ParamDimmer:             {name: "Dimmer", continuous: true, preset: "IntensityMasterDimmer", attribute: "Dimmer"},
+	dmx.ParamLyricID:            {name: "Lyric ID", continuous: true, attribute: "Effects4"},
+	dmx.ParamLyricProgress:      {name: "Lyric progress", continuous: true, attribute: "Effects4Adjust1"},
+}
+
+// capability is a range of the channel values with the same meaning
+type capability struct {
+	min, max int
+	label    string
+}
+
+// channel describes a single DMX channel of a mode
+type channel struct {
+	// offset counts from 1 at the start address
+	offset int
+	name   string
+	// mapping is the first of the parameters set by the channel, nil for an unused channel
+	mapping *dmx.ChannelMapping
+	// byte is the position of the channel in the value of its mapping, 0 for the most significant one
+	byte int
+	caps []capability
+}
+
+// channels describes every channel of the footprint of the personality
+func (d *Definition) channels(p *dmx.Personality) []channel {
+	footprint := p.Footprint()
+	out := make([]channel, 0, footprint)
+	for offset := 1; offset <= footprint; offset++ {
+		c := channel{offset: offset}
+		var mappings []*dmx.ChannelMapping
+		var names []string
+		for i := range p.Channels {
+			m := &p.Channels[i]
+			for b, ch := range m.Channels {
+				if ch == offset {
+					if c.mapping == nil {
+						c.mapping, c.byte = m, b
+					}
+					mappings = append(mappings, m)
+					names = append(names, byteName(m, b))
+				}
+			}
+		}
+
+		switch {
+		case len(mappings) == 0:
+			c.name = "Not used"
+			c.caps = []capability{{0, 255, "Not used"}}
+		case len(mappings) == 1 && constant(mappings[0]):
+			c.name = names[0]
+			c.caps = []capability{{0, 255, names[0]}}
+		default:
+			// The meaning of every value is worked out the same way the decoder does it and the runs of
+			// the same meaning make the capabilities
+			c.name = strings.Join(names, " / ")
+			values := make([]byte, footprint)
+			for v := 0; v < 256; v++ {
+				values[offset-1] = byte(v)
+				labels := make([]string, len(mappings))
+				for i, m := range mappings {
+					if constant(m) {
+						labels[i] = names[i]
+					} else {
+						value, _ := m.Value(values)
+						labels[i] = d.label(m.Parameter, value)
+					}
+				}
+				label := strings.Join(labels, " / ")
+				if n := len(c.caps); n > 0 && c.caps[n-1].label == label {
+					c.caps[n-1].max = v
+				} else {
+					c.caps = append(c.caps, capability{v, v, label})
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// constant reports whether the mapping only has a single meaning over the values of its channels
+func constant(m *dmx.ChannelMapping) bool {
+	return len(m.Channels) > 1 || (params[m.Parameter].continuous && len(m.Ranges) == 0)
+}
+
+// byteName names the channel of the mapping at the position in its value
+func byteName(m *dmx.ChannelMapping, b int) string {
+	name := params[m.Parameter].name
+	switch {
+	case len(m.Channels) == 1:
+		return name
+	case len(m.Channels) == 2 && b == 0:
+		return name + " coarse"
+	case len(m.Channels) == 2:
+		return name + " fine"
+	}
+	return fmt.Sprintf("%s byte %d", name, b+1)
+}
+
+// label names the value of the parameter the way the visualizer uses it
+func (d *Definition) label(param string, v int) string {
+	switch param {
+	case dmx.ParamDisplayMode:
+		return indexName(d.Waves, v)
+	case dmx.ParamBackgroundMode:
+		return indexName(d.Backgrounds, v)
+	case dmx.ParamWhiteDots:
+		if v != 0 {
+			return "White dots on"
+		}
+		return "White dots off"
+	case dmx.ParamColorPalette:
+		// The first palette and the ones past the end of the list leave the colors of the wave
+		if v <= 0 || v >= len(d.Palettes) {
+			return "Wave colors"
+		}
+		return "Palette " + d.Palettes[v]
+	}
+	return fmt.Sprintf("%s %d", params[param].name, v)
+}
+
+// indexName returns the name at the index, the indexes out of the range select the first mode
+func indexName(names []string, v int) string {
+	if len(names) == 0 {
+		return fmt.Sprint(v)
+	}
+	if v < 0 || v >= len(names) {
+		v = 0
+	}
+	return names[v]
+}
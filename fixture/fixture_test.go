@@ -0,0 +1,146 @@
+package fixture
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/TFK1410/go-rpi-fftwave/dmx"
+)
+
+var testDefinition = Definition{
+	Manufacturer: "Test",
+	Model:        "Wave",
+	Modes:        []dmx.Personality{dmx.DefaultPersonality, dmx.ExtendedPersonality},
+	Waves:        []string{"bars", "line"},
+	Backgrounds:  []string{"none", "dots"},
+	Palettes:     []string{"none", "rainbow", "fire"},
+}
+
+func TestChannels(t *testing.T) {
+	channels := testDefinition.channels(&dmx.DefaultPersonality)
+	if len(channels) != 12 {
+		t.Fatalf("Channel count mismatch. Want: %v, Have: %v\n", 12, len(channels))
+	}
+
+	// The packed first channel holds the wave in the low 4 bits, the background in the next 3 bits
+	// and the white dots at 128, the out of range waves select the first one
+	first := channels[0]
+	if first.caps[0].label != "bars / none / White dots on" {
+		t.Errorf("First capability mismatch. Want: %v, Have: %v\n", "bars / none / White dots on", first.caps[0].label)
+	}
+	if want := (capability{2, 15, "bars / none / White dots on"}); first.caps[2] != want {
+		t.Errorf("Out of range wave capability mismatch. Want: %v, Have: %v\n", want, first.caps[2])
+	}
+
+	// The palette takes the upper 6 bits so every palette spans 4 values
+	palettes := channels[1].caps
+	want := []capability{{0, 3, "Wave colors"}, {4, 7, "Palette rainbow"}, {8, 11, "Palette fire"}, {12, 255, "Wave colors"}}
+	if len(palettes) != len(want) {
+		t.Fatalf("Palette capability count mismatch. Want: %v, Have: %v\n", len(want), len(palettes))
+	}
+	for i := range want {
+		if palettes[i] != want[i] {
+			t.Errorf("Palette capability %d mismatch. Want: %v, Have: %v\n", i, want[i], palettes[i])
+		}
+	}
+
+	if len(channels[4].caps) != 1 || channels[4].name != "Red" {
+		t.Errorf("Red channel mismatch. Want: %v, Have: %v %v\n", "Red with one capability", channels[4].name, channels[4].caps)
+	}
+	if channels[9].name != "Lyric ID byte 2" {
+		t.Errorf("Lyric channel name mismatch. Want: %v, Have: %v\n", "Lyric ID byte 2", channels[9].name)
+	}
+}
+
+func TestWriteQLC(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteQLC(&buf, testDefinition); err != nil {
+		t.Fatal(err)
+	}
+	var f qlcFixture
+	if err := xml.Unmarshal(buf.Bytes(), &f); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.Modes) != 2 {
+		t.Fatalf("Mode count mismatch. Want: %v, Have: %v\n", 2, len(f.Modes))
+	}
+	if len(f.Modes[0].Channels) != 12 || len(f.Modes[1].Channels) != 15 {
+		t.Errorf("Mode channel count mismatch. Want: %v, Have: %v %v\n", "12 15", len(f.Modes[0].Channels), len(f.Modes[1].Channels))
+	}
+
+	// Every channel referenced by the modes has to be defined once
+	defined := make(map[string]qlcChannel)
+	for _, c := range f.Channels {
+		if _, ok := defined[c.Name]; ok {
+			t.Errorf("Channel %q is defined twice\n", c.Name)
+		}
+		defined[c.Name] = c
+	}
+	for _, m := range f.Modes {
+		for _, c := range m.Channels {
+			if _, ok := defined[c.Name]; !ok {
+				t.Errorf("Channel %q of mode %s isn't defined\n", c.Name, m.Name)
+			}
+		}
+	}
+
+	if c := defined["Dimmer fine"]; c.Preset != "IntensityMasterDimmerFine" {
+		t.Errorf("Dimmer fine preset mismatch. Want: %v, Have: %v\n", "IntensityMasterDimmerFine", c.Preset)
+	}
+	// The palette channel of the extended mode isn't shifted like the default one
+	if _, ok := defined["Palette (extended)"]; !ok {
+		t.Errorf("Palette channel of the extended mode missing\n")
+	}
+}
+
+func TestWriteGDTF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteGDTF(&buf, testDefinition); err != nil {
+		t.Fatal(err)
+	}
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(z.File) != 1 || z.File[0].Name != "description.xml" {
+		t.Fatalf("GDTF archive mismatch. Want: %v, Have: %v\n", "description.xml", z.File)
+	}
+	r, err := z.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var g gdtf
+	if err := xml.NewDecoder(r).Decode(&g); err != nil {
+		t.Fatal(err)
+	}
+
+	modes := g.FixtureType.DMXModes.DMXMode
+	if len(modes) != 2 {
+		t.Fatalf("Mode count mismatch. Want: %v, Have: %v\n", 2, len(modes))
+	}
+	// The lyric ID takes three channels in a single DMX channel of GDTF
+	channels := modes[0].DMXChannels.DMXChannel
+	if len(channels) != 10 {
+		t.Fatalf("Default mode channel count mismatch. Want: %v, Have: %v\n", 10, len(channels))
+	}
+	if channels[8].Offset != "9,10,11" {
+		t.Errorf("Lyric ID offset mismatch. Want: %v, Have: %v\n", "9,10,11", channels[8].Offset)
+	}
+
+	// Every attribute used by the channels has to be defined
+	defined := make(map[string]bool)
+	for _, a := range g.FixtureType.AttributeDefinitions.Attributes.Attribute {
+		defined[a.Name] = true
+	}
+	for _, m := range modes {
+		for _, c := range m.DMXChannels.DMXChannel {
+			if !defined[c.LogicalChannel.Attribute] {
+				t.Errorf("Attribute %s of mode %s isn't defined\n", c.LogicalChannel.Attribute, m.Name)
+			}
+		}
+	}
+}
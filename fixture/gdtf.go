@@ -0,0 +1,229 @@
+package fixture
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/TFK1410/go-rpi-fftwave/dmxnet"
+)
+
+// The GDTF description of the fixture type, the file is a zip archive with the description.xml inside
+type gdtf struct {
+	XMLName     xml.Name        `xml:"GDTF"`
+	DataVersion string          `xml:"DataVersion,attr"`
+	FixtureType gdtfFixtureType `xml:"FixtureType"`
+}
+
+type gdtfFixtureType struct {
+	Name                 string         `xml:"Name,attr"`
+	ShortName            string         `xml:"ShortName,attr"`
+	LongName             string         `xml:"LongName,attr"`
+	Manufacturer         string         `xml:"Manufacturer,attr"`
+	Description          string         `xml:"Description,attr"`
+	FixtureTypeID        string         `xml:"FixtureTypeID,attr"`
+	Thumbnail            string         `xml:"Thumbnail,attr"`
+	RefFT                string         `xml:"RefFT,attr"`
+	AttributeDefinitions gdtfAttributes `xml:"AttributeDefinitions"`
+	Wheels               struct{}       `xml:"Wheels"`
+	PhysicalDescriptions struct{}       `xml:"PhysicalDescriptions"`
+	Models               struct{}       `xml:"Models"`
+	Geometries           struct {
+		Geometry []gdtfGeometry `xml:"Geometry"`
+	} `xml:"Geometries"`
+	DMXModes struct {
+		DMXMode []gdtfDMXMode `xml:"DMXMode"`
+	} `xml:"DMXModes"`
+	Revisions struct{} `xml:"Revisions"`
+}
+
+type gdtfAttributes struct {
+	ActivationGroups struct{} `xml:"ActivationGroups"`
+	FeatureGroups    struct {
+		FeatureGroup []gdtfFeatureGroup `xml:"FeatureGroup"`
+	} `xml:"FeatureGroups"`
+	Attributes struct {
+		Attribute []gdtfAttribute `xml:"Attribute"`
+	} `xml:"Attributes"`
+}
+
+type gdtfFeatureGroup struct {
+	Name    string `xml:"Name,attr"`
+	Pretty  string `xml:"Pretty,attr"`
+	Feature []struct {
+		Name string `xml:"Name,attr"`
+	} `xml:"Feature"`
+}
+
+type gdtfAttribute struct {
+	Name         string `xml:"Name,attr"`
+	Pretty       string `xml:"Pretty,attr"`
+	Feature      string `xml:"Feature,attr"`
+	PhysicalUnit string `xml:"PhysicalUnit,attr"`
+}
+
+type gdtfGeometry struct {
+	Name     string `xml:"Name,attr"`
+	Model    string `xml:"Model,attr"`
+	Position string `xml:"Position,attr"`
+}
+
+type gdtfDMXMode struct {
+	Name        string `xml:"Name,attr"`
+	Geometry    string `xml:"Geometry,attr"`
+	DMXChannels struct {
+		DMXChannel []gdtfDMXChannel `xml:"DMXChannel"`
+	} `xml:"DMXChannels"`
+}
+
+type gdtfDMXChannel struct {
+	DMXBreak       int                `xml:"DMXBreak,attr"`
+	Offset         string             `xml:"Offset,attr"`
+	Highlight      string             `xml:"Highlight,attr"`
+	Geometry       string             `xml:"Geometry,attr"`
+	LogicalChannel gdtfLogicalChannel `xml:"LogicalChannel"`
+}
+
+type gdtfLogicalChannel struct {
+	Attribute          string              `xml:"Attribute,attr"`
+	Snap               string              `xml:"Snap,attr"`
+	Master             string              `xml:"Master,attr"`
+	MibFade            int                 `xml:"MibFade,attr"`
+	DMXChangeTimeLimit int                 `xml:"DMXChangeTimeLimit,attr"`
+	ChannelFunction    gdtfChannelFunction `xml:"ChannelFunction"`
+}
+
+type gdtfChannelFunction struct {
+	Name              string           `xml:"Name,attr"`
+	Attribute         string           `xml:"Attribute,attr"`
+	OriginalAttribute string           `xml:"OriginalAttribute,attr"`
+	DMXFrom           string           `xml:"DMXFrom,attr"`
+	Default           string           `xml:"Default,attr"`
+	PhysicalFrom      float64          `xml:"PhysicalFrom,attr"`
+	PhysicalTo        float64          `xml:"PhysicalTo,attr"`
+	RealFade          float64          `xml:"RealFade,attr"`
+	RealAcceleration  float64          `xml:"RealAcceleration,attr"`
+	ChannelSets       []gdtfChannelSet `xml:"ChannelSet"`
+}
+
+type gdtfChannelSet struct {
+	Name           string  `xml:"Name,attr"`
+	DMXFrom        string  `xml:"DMXFrom,attr"`
+	PhysicalFrom   float64 `xml:"PhysicalFrom,attr"`
+	PhysicalTo     float64 `xml:"PhysicalTo,attr"`
+	WheelSlotIndex int     `xml:"WheelSlotIndex,attr"`
+}
+
+// gdtfFeatures are the feature groups and their features used by the attributes of the parameters
+var gdtfFeatures = []struct{ group, feature string }{
+	{"Dimmer", "Dimmer"},
+	{"Color", "RGB"},
+	{"Color", "Color"},
+	{"Beam", "Effects"},
+}
+
+// gdtfFeature returns the feature of the attribute
+func gdtfFeature(attribute string) string {
+	switch {
+	case attribute == "Dimmer":
+		return "Dimmer.Dimmer"
+	case strings.HasPrefix(attribute, "ColorAdd_"):
+		return "Color.RGB"
+	case strings.HasPrefix(attribute, "Color"):
+		return "Color.Color"
+	}
+	return "Beam.Effects"
+}
+
+// WriteGDTF writes the GDTF fixture type (.gdtf)
+func WriteGDTF(w io.Writer, d Definition) error {
+	id := dmxnet.NewCID(d.Manufacturer + "/" + d.Model)
+	ft := gdtfFixtureType{
+		Name:          d.Model,
+		ShortName:     d.Model,
+		LongName:      d.Model,
+		Manufacturer:  d.Manufacturer,
+		Description:   "Audio spectrum visualizer on LED matrix panels",
+		FixtureTypeID: strings.ToUpper(fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])),
+	}
+	ft.Geometries.Geometry = []gdtfGeometry{{Name: "Body", Position: "{1,0,0,0}{0,1,0,0}{0,0,1,0}{0,0,0,1}"}}
+
+	for _, f := range gdtfFeatures {
+		groups := ft.AttributeDefinitions.FeatureGroups.FeatureGroup
+		if n := len(groups); n == 0 || groups[n-1].Name != f.group {
+			groups = append(groups, gdtfFeatureGroup{Name: f.group, Pretty: f.group})
+		}
+		g := &groups[len(groups)-1]
+		g.Feature = append(g.Feature, struct {
+			Name string `xml:"Name,attr"`
+		}{f.feature})
+		ft.AttributeDefinitions.FeatureGroups.FeatureGroup = groups
+	}
+
+	attributes := make(map[string]bool)
+	for i := range d.Modes {
+		p := &d.Modes[i]
+		mode := gdtfDMXMode{Name: p.Name, Geometry: "Body"}
+		for _, c := range d.channels(p) {
+			// The fine channels are a part of the coarse one and the unused channels are left out
+			if c.mapping == nil || c.byte > 0 {
+				continue
+			}
+			info := params[c.mapping.Parameter]
+			n := 1
+			offset := fmt.Sprint(c.offset)
+			if len(c.mapping.Channels) > 1 {
+				n = len(c.mapping.Channels)
+				offset = strings.Trim(strings.Join(strings.Fields(fmt.Sprint(c.mapping.Channels)), ","), "[]")
+			}
+			if !attributes[info.attribute] {
+				attributes[info.attribute] = true
+				ft.AttributeDefinitions.Attributes.Attribute = append(ft.AttributeDefinitions.Attributes.Attribute,
+					gdtfAttribute{Name: info.attribute, Pretty: info.name, Feature: gdtfFeature(info.attribute), PhysicalUnit: "None"})
+			}
+
+			fn := gdtfChannelFunction{
+				Name:       c.name,
+				Attribute:  info.attribute,
+				DMXFrom:    fmt.Sprintf("0/%d", n),
+				Default:    fmt.Sprintf("0/%d", n),
+				PhysicalTo: 1,
+			}
+			if len(c.caps) > 1 {
+				for _, cap := range c.caps {
+					fn.ChannelSets = append(fn.ChannelSets, gdtfChannelSet{Name: cap.label, DMXFrom: fmt.Sprintf("%d/1", cap.min)})
+				}
+			}
+			mode.DMXChannels.DMXChannel = append(mode.DMXChannels.DMXChannel, gdtfDMXChannel{
+				DMXBreak:  1,
+				Offset:    offset,
+				Highlight: "None",
+				Geometry:  "Body",
+				LogicalChannel: gdtfLogicalChannel{
+					Attribute:       info.attribute,
+					Snap:            "No",
+					Master:          "None",
+					ChannelFunction: fn,
+				},
+			})
+		}
+		ft.DMXModes.DMXMode = append(ft.DMXModes.DMXMode, mode)
+	}
+
+	z := zip.NewWriter(w)
+	f, err := z.Create("description.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(f)
+	enc.Indent("", "  ")
+	if err := enc.Encode(gdtf{DataVersion: "1.1", FixtureType: ft}); err != nil {
+		return err
+	}
+	return z.Close()
+}
@@ -0,0 +1,118 @@
+package fixture
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// The QLC+ fixture definition, the channels are shared by the modes and referenced by their names
+type qlcFixture struct {
+	XMLName      xml.Name     `xml:"FixtureDefinition"`
+	Xmlns        string       `xml:"xmlns,attr"`
+	Creator      qlcCreator   `xml:"Creator"`
+	Manufacturer string       `xml:"Manufacturer"`
+	Model        string       `xml:"Model"`
+	Type         string       `xml:"Type"`
+	Channels     []qlcChannel `xml:"Channel"`
+	Modes        []qlcMode    `xml:"Mode"`
+}
+
+type qlcCreator struct {
+	Name    string `xml:"Name"`
+	Version string `xml:"Version"`
+	Author  string `xml:"Author"`
+}
+
+type qlcChannel struct {
+	Name         string          `xml:"Name,attr"`
+	Preset       string          `xml:"Preset,attr,omitempty"`
+	Group        *qlcGroup       `xml:"Group,omitempty"`
+	Capabilities []qlcCapability `xml:"Capability"`
+}
+
+type qlcGroup struct {
+	Byte  int    `xml:"Byte,attr"`
+	Value string `xml:",chardata"`
+}
+
+type qlcCapability struct {
+	Min   int    `xml:"Min,attr"`
+	Max   int    `xml:"Max,attr"`
+	Label string `xml:",chardata"`
+}
+
+type qlcMode struct {
+	Name     string           `xml:"Name,attr"`
+	Channels []qlcModeChannel `xml:"Channel"`
+}
+
+type qlcModeChannel struct {
+	Number int    `xml:"Number,attr"`
+	Name   string `xml:",chardata"`
+}
+
+// WriteQLC writes the QLC+ fixture definition (.qxf)
+func WriteQLC(w io.Writer, d Definition) error {
+	f := qlcFixture{
+		Xmlns:        "http://www.qlcplus.org/FixtureDefinition",
+		Creator:      qlcCreator{Name: d.Model, Version: "1", Author: d.Manufacturer},
+		Manufacturer: d.Manufacturer,
+		Model:        d.Model,
+		Type:         "LED Bar (Pixels)",
+	}
+
+	// The channels with the same name and a different meaning in another mode get the name of their mode
+	byName := make(map[string]qlcChannel)
+	for i := range d.Modes {
+		p := &d.Modes[i]
+		mode := qlcMode{Name: p.Name}
+		for _, c := range d.channels(p) {
+			qc := qlcChannelOf(c)
+			if prev, ok := byName[qc.Name]; ok && !reflect.DeepEqual(prev, qc) {
+				qc.Name = fmt.Sprintf("%s (%s)", qc.Name, p.Name)
+			}
+			if _, ok := byName[qc.Name]; !ok {
+				byName[qc.Name] = qc
+				f.Channels = append(f.Channels, qc)
+			}
+			mode.Channels = append(mode.Channels, qlcModeChannel{Number: c.offset - 1, Name: qc.Name})
+		}
+		f.Modes = append(f.Modes, mode)
+	}
+
+	if _, err := io.WriteString(w, xml.Header+"<!DOCTYPE FixtureDefinition>\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func qlcChannelOf(c channel) qlcChannel {
+	qc := qlcChannel{Name: c.name}
+	if c.mapping == nil {
+		qc.Group = &qlcGroup{Value: "Nothing"}
+	} else if preset := params[c.mapping.Parameter].preset; preset != "" && len(c.mapping.Channels) <= 2 && len(c.caps) == 1 {
+		// The intensity channels are described by the presets of QLC+
+		if c.byte > 0 {
+			preset += "Fine"
+		}
+		qc.Preset = preset
+		return qc
+	} else {
+		qc.Group = &qlcGroup{Value: "Effect"}
+		if c.byte > 0 {
+			qc.Group.Byte = 1
+		}
+	}
+	for _, cap := range c.caps {
+		qc.Capabilities = append(qc.Capabilities, qlcCapability{Min: cap.min, Max: cap.max, Label: cap.label})
+	}
+	return qc
+}
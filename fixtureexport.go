@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TFK1410/go-rpi-fftwave/backgroundloops"
+	"github.com/TFK1410/go-rpi-fftwave/dmx"
+	"github.com/TFK1410/go-rpi-fftwave/drawloops"
+	"github.com/TFK1410/go-rpi-fftwave/fixture"
+	"github.com/TFK1410/go-rpi-fftwave/modes"
+	"github.com/TFK1410/go-rpi-fftwave/palette"
+)
+
+var exportFixture = flag.String("export-fixture", "", "Write the fixture definition for the lighting consoles to a .qxf (QLC+) or .gdtf file and exit")
+
+// writeFixture writes the fixture definition of the configured waves, backgrounds and personalities
+func writeFixture(path string) error {
+	write := fixture.WriteQLC
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".qxf":
+	case ".gdtf":
+		write = fixture.WriteGDTF
+	default:
+		return fmt.Errorf("unknown fixture format of %s, use .qxf or .gdtf", path)
+	}
+
+	waves, err := drawloops.ListWaves(cfg.WavePresets, cfg.Layouts)
+	if err != nil {
+		return err
+	}
+	backgrounds, err := backgroundloops.ListBackgroundLoops(cfg.BgPresets)
+	if err != nil {
+		return err
+	}
+	personalities, err := dmx.Personalities(cfg.DMXPersonalities)
+	if err != nil {
+		return err
+	}
+	d := fixture.Definition{
+		Manufacturer: "go-rpi-fftwave",
+		Model:        "FFT Wave Display",
+		Modes:        personalities,
+		Waves:        modeNames(waves),
+		Backgrounds:  modeNames(backgrounds),
+		Palettes:     palette.Names,
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f, d); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func modeNames(list []modes.Info) []string {
+	names := make([]string, len(list))
+	for i, info := range list {
+		names[i] = info.Name
+	}
+	return names
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/TFK1410/go-rpi-fftwave/backgroundloops"
+	"github.com/TFK1410/go-rpi-fftwave/dmx"
+	"github.com/TFK1410/go-rpi-fftwave/drawloops"
+	"github.com/TFK1410/go-rpi-fftwave/output"
+	"github.com/TFK1410/go-rpi-fftwave/render"
+	"github.com/TFK1410/go-rpi-fftwave/soundbuffer"
+)
+
+var headlessInput = flag.String("headless", "", "Render offscreen from a WAV file or a synthetic signal (sweep, beat) without opening the matrix, GPIO, I2C and PortAudio and exit, "+
+	"the native libraries still have to be installed")
+var headlessDuration = flag.Duration("headless-duration", 10*time.Second, "Length of the synthetic signal in the headless mode")
+
+// runHeadless drives the analysis and the rendering from the input at the configured rates and passes every frame to the sink
+// the frames are rendered as fast as possible with their timestamps following the position in the input
+func runHeadless(input string, sink output.Sink) error {
+	samples, rate, err := loadSamples(input, cfg.SampleRate, *headlessDuration)
+	if err != nil {
+		return err
+	}
+	if rate != cfg.SampleRate {
+		log.Println("Using the sample rate of the input:", rate)
+		cfg.SampleRate = rate
+	}
+
+	width, height := canvasSize(cfg.IntMatrix)
+	log.Println("Canvas size:", width, "x", height)
+
+	wave, background, err := initModes(width, height)
+	if err != nil {
+		return err
+	}
+
+	bfz := 1 << cfg.FFT.ChunkPower
+	analyzer := newFFTAnalyzer(bfz, width)
+	defer analyzer.free()
+	buf, _ := soundbuffer.NewBuffer(int64(bfz))
+	hop := rate / cfg.FFT.FFTUpdateRate
+
+	var dmxData dmx.DMXData
+	dmx.ResetDMX(&dmxData)
+
+	// A recorded show follows the position in the input so the looks line up with the audio file
+	var show *dmx.Show
+	if *headlessShow != "" {
+		if show, err = loadShow(*headlessShow); err != nil {
+			return err
+		}
+	}
+	var dispMode, backMode int
+
+	r := render.New(width, height, renderConfig(&cfg))
+	fft := make([]float64, width)
+	frameStep := time.Second / time.Duration(cfg.Display.RefreshRate)
+	start := time.Now()
+
+	var frames, pos int
+	for {
+		t := time.Duration(frames) * frameStep
+		target := int(t.Seconds() * float64(rate))
+		if target > len(samples) {
+			break
+		}
+
+		// Feed the samples up to the time of the frame the same way the recording does
+		for pos+hop <= target {
+			buf.Write(samples[pos : pos+hop])
+			pos += hop
+			fft = analyzer.analyze(buf.Sound())
+		}
+
+		if show != nil {
+			// The modes switch the same way the render loop does it with the live DMX data
+			dmxData = show.At(t)
+			if dispMode != int(dmxData.DisplayMode) {
+				dispMode = int(dmxData.DisplayMode)
+				wave = drawloops.GetWaveNum(dispMode)
+			}
+			if backMode != int(dmxData.BackgroundMode) {
+				backMode = int(dmxData.BackgroundMode)
+				background = backgroundloops.GetBackgroundLoopNum(backMode)
+			}
+		}
+
+		frame := r.Render(start.Add(t), fft, wave, background, dmxData, nil)
+		if err := sink.Send(frame); err != nil {
+			return err
+		}
+		frames++
+	}
+
+	log.Printf("Rendered %d frames of %v in %v\n", frames, time.Duration(frames)*frameStep, time.Since(start))
+	return nil
+}
+
+// canvasSize calculates the size of the canvas from the matrix config without the matrix library
+// out of the pixel mappers only the U-mapper and the rotation change the size
+func canvasSize(mc matrixConfig) (int, int) {
+	width, height := mc.Cols*mc.Chain, mc.Rows*mc.Parallel
+	for _, mapper := range strings.Split(mc.PixelMapperConfig, ";") {
+		mapper = strings.TrimSpace(mapper)
+		switch {
+		case mapper == "U-mapper":
+			width, height = width/2, height*2
+		case mapper == "Rotate:90" || mapper == "Rotate:270":
+			width, height = height, width
+		}
+	}
+	return width, height
+}
+
+// loadSamples returns the mono samples of the headless input together with their sample rate
+// the synthetic signals are generated with the given sample rate and duration
+func loadSamples(input string, sampleRate int, duration time.Duration) ([]int16, int, error) {
+	n := int(duration.Seconds() * float64(sampleRate))
+	switch input {
+	case "sweep":
+		return sweepSignal(n, sampleRate), sampleRate, nil
+	case "beat":
+		return beatSignal(n, sampleRate), sampleRate, nil
+	}
+	return readWAV(input)
+}
+
+// sweepSignal generates a logarithmic sine sweep from 20Hz up to the Nyquist frequency
+func sweepSignal(n, sampleRate int) []int16 {
+	out := make([]int16, n)
+	f0, f1 := 20.0, float64(sampleRate)/2
+	length := float64(n) / float64(sampleRate)
+	k := math.Log(f1 / f0)
+	for i := range out {
+		t := float64(i) / float64(sampleRate)
+		phase := 2 * math.Pi * f0 * length / k * (math.Exp(t/length*k) - 1)
+		out[i] = int16(0.5 * math.MaxInt16 * math.Sin(phase))
+	}
+	return out
+}
+
+// beatSignal generates a 120 BPM pattern with a kick on every beat, a hi-hat between them and a quiet chord under both
+func beatSignal(n, sampleRate int) []int16 {
+	out := make([]int16, n)
+	rng := rand.New(rand.NewSource(1))
+	beat := sampleRate / 2
+	for i := range out {
+		t := float64(i) / float64(sampleRate)
+		kt := float64(i%beat) / float64(sampleRate)
+		ht := float64((i+beat/2)%beat) / float64(sampleRate)
+
+		// The kick drops in pitch from 120Hz to 50Hz
+		v := 0.6 * math.Exp(-kt*12) * math.Sin(2*math.Pi*(50*kt+70*(1-math.Exp(-kt*30))/30))
+		v += 0.2 * math.Exp(-ht*60) * (rng.Float64()*2 - 1)
+		v += 0.05 * (math.Sin(2*math.Pi*440*t) + math.Sin(2*math.Pi*660*t))
+		out[i] = int16(v * math.MaxInt16)
+	}
+	return out
+}
+
+// readWAV reads a 16 bit PCM WAV file, multiple channels are mixed down to mono
+func readWAV(path string) ([]int16, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error opening the input: %v", err)
+	}
+	defer f.Close()
+
+	var riff [12]byte
+	if _, err := io.ReadFull(f, riff[:]); err != nil || string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, 0, fmt.Errorf("%s is not a WAV file", path)
+	}
+
+	var channels, bits, format uint16
+	var rate uint32
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(f, header[:]); err != nil {
+			return nil, 0, fmt.Errorf("%s has no audio data", path)
+		}
+		size := binary.LittleEndian.Uint32(header[4:])
+
+		switch string(header[0:4]) {
+		case "fmt ":
+			chunk := make([]byte, size)
+			if _, err := io.ReadFull(f, chunk); err != nil || size < 16 {
+				return nil, 0, fmt.Errorf("%s has an invalid format chunk", path)
+			}
+			format = binary.LittleEndian.Uint16(chunk[0:])
+			channels = binary.LittleEndian.Uint16(chunk[2:])
+			rate = binary.LittleEndian.Uint32(chunk[4:])
+			bits = binary.LittleEndian.Uint16(chunk[14:])
+		case "data":
+			if format != 1 || bits != 16 || channels == 0 {
+				return nil, 0, fmt.Errorf("%s has to be a 16 bit PCM WAV file", path)
+			}
+			// Files cut short are read up to their end
+			raw := make([]byte, size)
+			read, err := io.ReadFull(f, raw)
+			if err != nil && err != io.ErrUnexpectedEOF {
+				return nil, 0, fmt.Errorf("error reading the audio data: %v", err)
+			}
+			frameSize := 2 * int(channels)
+			out := make([]int16, read/frameSize)
+			for i := range out {
+				var sum int
+				for c := 0; c < int(channels); c++ {
+					sum += int(int16(binary.LittleEndian.Uint16(raw[i*frameSize+2*c:])))
+				}
+				out[i] = int16(sum / int(channels))
+			}
+			return out, int(rate), nil
+		default:
+			// Chunks are padded to an even size
+			if _, err := f.Seek(int64(size+size%2), io.SeekCurrent); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"math/rand"
 	"sync"
 	"time"
+
+	"github.com/TFK1410/go-rpi-fftwave/dmx"
 )
 
 // LyricDrawContext ...
@@ -213,7 +215,7 @@ func (ldc *LyricDrawContext) GetImage() *image.RGBA {
 	return ldc.img
 }
 
-func (ldc *LyricDrawContext) InitLyricsThread(lyricsDMXInfo <-chan uint, wg *sync.WaitGroup, quit <-chan struct{}) {
+func (ldc *LyricDrawContext) InitLyricsThread(changes <-chan dmx.Change, wg *sync.WaitGroup, quit <-chan struct{}) {
 	defer wg.Done()
 	ldc.img = image.NewRGBA(image.Rect(0, 0, ldc.SizeX, ldc.SizeY))
 
@@ -237,7 +239,12 @@ func (ldc *LyricDrawContext) InitLyricsThread(lyricsDMXInfo <-chan uint, wg *syn
 
 	for {
 		select {
-		case curData = <-lyricsDMXInfo:
+		case c, ok := <-changes:
+			if !ok {
+				changes = nil
+				continue
+			}
+			curData = c.Data.LyricsDMXInfo
 			// A new song ID is received
 			// If it is equal to 0 that means that no song is selected right now
 			// The goroutine will be paused until a good ID with an existing .lrc file is found
@@ -2,16 +2,26 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"image/color"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/TFK1410/go-rpi-fftwave/backgroundloops"
 	"github.com/TFK1410/go-rpi-fftwave/dmx"
 	"github.com/TFK1410/go-rpi-fftwave/drawloops"
 	"github.com/TFK1410/go-rpi-fftwave/lyricsoverlay"
+	"github.com/TFK1410/go-rpi-fftwave/midi"
+	"github.com/TFK1410/go-rpi-fftwave/modes"
+	"github.com/TFK1410/go-rpi-fftwave/osc"
+	"github.com/TFK1410/go-rpi-fftwave/output"
+	"github.com/TFK1410/go-rpi-fftwave/render"
 	"github.com/TFK1410/go-rpi-fftwave/soundbuffer"
 	rgbmatrix "github.com/tfk1410/go-rpi-rgb-led-matrix"
 	"periph.io/x/host/v3"
@@ -25,6 +35,7 @@ type SoundSync struct {
 }
 
 var configPath = flag.String("config", "config.yml", "Path to the script configuration file")
+var listModes = flag.Bool("list-modes", false, "List the available waves and backgrounds and exit")
 
 func main() {
 	// Parsing the single configuration flag
@@ -37,16 +48,140 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if *listModes {
+		if err := printModes(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *exportFixture != "" {
+		if err := writeFixture(*exportFixture); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *headlessInput != "" {
+		// The capture waits for its writer since there's no need to keep up with the wall clock
+		capture, err := newCapture(cfg.Capture, true)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if cfg.Capture.OnStart {
+			capture.Start()
+		}
+		err = runHeadless(*headlessInput, output.Multi{capture})
+		if cerr := capture.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Take over kill signals
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
+	// SIGHUP reloads the wave and background parameters and the color calibration from the config file
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
+	// SIGUSR2 starts a capture of the displayed frames or stops the running one
+	captureSignal := make(chan os.Signal, 1)
+	signal.Notify(captureSignal, syscall.SIGUSR2)
+
 	// Load GPIO and I2C drivers
 	_, err = host.Init()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// The DMX personality is checked before anything is started
+	personality, err := dmx.FindPersonality(cfg.DMX.Personality, cfg.DMXPersonalities)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.DMX.Input == "i2c" {
+		if err := dmx.CheckI2CPersonality(personality); err != nil {
+			log.Fatal(err)
+		}
+	}
+	log.Println("Using the DMX personality", personality.Name, "with", personality.Footprint(), "channels")
+
+	// Initialize the LED matrix which is the main output of the frames
+	// set export MATRIX_TERMINAL_EMULATOR=1 to use the terminal emulator version for testing
+	// set export SOUND_EMULATOR=1 to add dummy sound data for testing
+	// without the matrix the canvas keeps the size the matrix config describes
+	var m rgbmatrix.Matrix
+	var power *output.PowerLimiter
+	// brightness is kept for the power limiter which runs in the rendering thread
+	var brightness int32
+	width, height := canvasSize(cfg.IntMatrix)
+	if cfg.Local.Matrix {
+		m, err = rgbmatrix.NewRGBLedMatrix(cfg.Matrix)
+		if err != nil {
+			log.Fatal(err)
+		}
+		width, height = m.Geometry()
+		brightness = int32(m.GetBrightness())
+		power = newPowerLimiter(newMatrixSink(m), cfg.Power, &brightness)
+	}
+
+	// Every frame is drawn into the renderer buffer before being passed on to the outputs
+	capture, err := newCapture(cfg.Capture, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	outputs, err := newOutputs(width, height)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The color calibration is only applied to the displays, the captures and the preview get the rendered colors
+	displays := outputs
+	if power != nil {
+		displays = append(output.Multi{power}, displays...)
+	}
+	colorCalibration, err := calibration(cfg.Calibration, cfg.IntMatrix)
+	if err != nil {
+		log.Fatal(err)
+	}
+	corrected, err := output.NewCorrected(displays, colorCalibration)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *testPattern != "" {
+		err = runTestPattern(*testPattern, corrected, width, height, quit, reload)
+		if cerr := corrected.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	// The DMX state shared by the DMX reader, the lyrics, the preview and the render loop
+	dmxState := dmx.NewState()
+
+	sinks := output.Multi{corrected, capture}
+	if cfg.Preview.Address != "" {
+		preview, srv, err := startPreview(cfg.Preview, dmxStatus(dmxState, cfg.DMX))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer srv.Close()
+		sinks = append(sinks, preview)
+	}
+	defer sinks.Close()
+	if cfg.Capture.OnStart {
+		capture.Start()
+	}
+
+	renderer := render.New(width, height, renderConfig(&cfg))
+	log.Println("Canvas size:", width, "x", height)
+
 	// Setup a waitGroup and quit channels for the goroutines
 	var quits []chan struct{}
 	var wg sync.WaitGroup
@@ -62,49 +197,37 @@ func main() {
 	ss.quit = quits[len(quits)-1]
 	go initRecord(r, cfg.SampleRate/cfg.FFT.FFTUpdateRate, ss)
 
-	// Initialize the LED matrix and the canvas that goes along with it
-	// set export MATRIX_TERMINAL_EMULATOR=1 to use the terminal emulator version for testing
-	// set export SOUND_EMULATOR=1 to add dummy sound data for testing
-	m, err := rgbmatrix.NewRGBLedMatrix(cfg.Matrix)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	c := rgbmatrix.NewCanvas(m)
-	defer c.Close()
-
-	log.Println("Canvas size:", c.Bounds().Dx(), "x", c.Bounds().Dy())
-
 	// Setup FFT thread
 	quits = addThread(&wg, quits)
 	ss.quit = quits[len(quits)-1]
 	fftOutChan := make(chan []float64)
-	go initFFT(1<<cfg.FFT.ChunkPower, c.Bounds().Dx(), fftOutChan, ss)
+	go initFFT(1<<cfg.FFT.ChunkPower, width, fftOutChan, ss)
 
-	// Initialize all the possible wave types
-	drawloops.InitWaves(c.Bounds().Dx(), c.Bounds().Dy(), cfg.Display.MinVal, cfg.Display.MaxVal)
-	backgroundloops.InitBackgroundLoops(c.Bounds().Dx(), c.Bounds().Dy(), cfg.SoundEnergy.MinBand, cfg.SoundEnergy.MaxBand)
+	// Initialize all the possible wave and background types
+	firstWave, firstBackground, err := initModes(width, height)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Setup lyrics thread
-	lyricsDMXInfo := make(chan uint)
+	lyricsChanges, unsubscribeLyrics := dmxState.Subscribe(dmx.FieldLyricsDMXInfo)
 	quits = addThread(&wg, quits)
 	ldc := lyricsoverlay.LyricDrawContext{
-		SizeX:       c.Bounds().Dx(),
-		SizeY:       c.Bounds().Dy(),
+		SizeX:       width,
+		SizeY:       height,
 		RefreshRate: cfg.Lyrics.RefreshRate,
 		SqlitePath:  cfg.Lyrics.SqlitePath,
 	}
 
-	go ldc.InitLyricsThread(lyricsDMXInfo, &wg, quits[len(quits)-1])
+	go ldc.InitLyricsThread(lyricsChanges, &wg, quits[len(quits)-1])
 
 	// Setup FFT smoothing thread
-	var dmxData dmx.DMXData
 	waveChan := make(chan drawloops.Wave)
 	backgroundChan := make(chan backgroundloops.BackgroundLoop)
 	quits = addThread(&wg, quits)
-	go initFFTSmooth(c, waveChan, backgroundChan, fftOutChan, &dmxData, &ldc, &wg, quits[len(quits)-1])
-	waveChan <- drawloops.GetFirstWave()
-	backgroundChan <- backgroundloops.GetFirstBackgroundLoop()
+	go initFFTSmooth(renderer, sinks, waveChan, backgroundChan, fftOutChan, dmxState, &ldc, &wg, quits[len(quits)-1])
+	waveChan <- firstWave
+	backgroundChan <- firstBackground
 
 	// Start encoder thread
 	encMessage := make(chan EncoderMessage)
@@ -115,10 +238,113 @@ func main() {
 	quits = addThread(&wg, quits)
 	pause := make(chan struct{})
 	play := make(chan struct{})
-	go dmx.InitDMX(cfg.DMX.SlaveAddress, &dmxData, lyricsDMXInfo, &wg, quits[len(quits)-1], pause, play)
-	dmx.ResetDMX(&dmxData)
+	switch cfg.DMX.Input {
+	case "i2c":
+		go dmx.InitDMX(cfg.DMX.SlaveAddress, personality, dmxState, &wg, quits[len(quits)-1], pause, play)
+	case "artnet":
+		receiver, err := dmx.NewArtNetReceiver(cfg.DMX.Address, cfg.DMX.Universe, cfg.DMX.StartAddress, personality)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Listening for Art-Net universe", cfg.DMX.Universe, "on", receiver.Addr())
+		go receiver.Run(dmxState, &wg, quits[len(quits)-1], pause, play)
+	case "e131":
+		receiver, err := dmx.NewE131Receiver(cfg.DMX.Address, cfg.DMX.Universe, cfg.DMX.StartAddress, cfg.DMX.Merge, personality)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Listening for E1.31 universe", cfg.DMX.Universe, "on", receiver.Addr())
+		go receiver.Run(dmxState, &wg, quits[len(quits)-1], pause, play)
+	case "show":
+		player, audio, err := newShowPlayer(cfg.DMX.Show)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if audio != nil {
+			defer audio.stop()
+		}
+		log.Println("Playing the DMX show", cfg.DMX.Show.File)
+		go player.Run(dmxState, &wg, quits[len(quits)-1], pause, play)
+	default:
+		log.Fatalf("Unknown DMX input %q\n", cfg.DMX.Input)
+	}
 	play <- struct{}{}
 
+	// The recording covers the whole run
+	if *dmxRecord != "" {
+		f, err := os.Create(*dmxRecord)
+		if err != nil {
+			log.Fatal(err)
+		}
+		recorder, err := dmx.RecordShow(dmxState, f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Recording the DMX show to", *dmxRecord)
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				log.Println("Recording the DMX show failed:", err)
+			}
+			f.Close()
+		}()
+	}
+
+	// Start the DMX failsafe thread watching for the signal loss
+	failsafeCfg, err := failsafeSettings(cfg.DMX.Failsafe)
+	if err != nil {
+		log.Fatal(err)
+	}
+	failsafe, err := dmx.NewFailsafe(dmxState, failsafeCfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	quits = addThread(&wg, quits)
+	go failsafe.Run(&wg, quits[len(quits)-1])
+
+	// The OSC and MIDI inputs drive the same state as the DMX input
+	inputBrightness := make(chan int, 1)
+	var ctl *controls
+	if cfg.OSC.Enabled || cfg.MIDI.Input != "" {
+		ctl, err = newControls(dmxState, inputBrightness)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if cfg.OSC.Enabled {
+		server, err := osc.Listen(cfg.OSC.Address)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("Listening for OSC on", server.Addr())
+		quits = addThread(&wg, quits)
+		go server.Run((&oscInput{ctl}).handle, &wg, quits[len(quits)-1])
+	}
+	if cfg.MIDI.Input != "" {
+		input, err := newMIDIInput(ctl, cfg.MIDI, *midiLearn, *configPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		src, err := openMIDI(cfg.MIDI)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if input.learn != nil {
+			log.Println("MIDI learn: move the control to bind to", input.learn.Target)
+		}
+		quits = addThread(&wg, quits)
+		go midi.Run(src, input.handle, &wg, quits[len(quits)-1])
+	} else if *midiLearn != "" {
+		log.Fatal("MIDI learn needs the MIDI input in the midiConfig section")
+	}
+
+	// The power report ticker is left stopped when the reports are off
+	powerReport := time.NewTicker(time.Hour)
+	powerReport.Stop()
+	if power != nil && cfg.Power.ReportInterval > 0 {
+		powerReport.Reset(time.Duration(cfg.Power.ReportInterval * float64(time.Second)))
+	}
+	defer powerReport.Stop()
+
 	log.Println("All initialized")
 
 	for {
@@ -128,13 +354,21 @@ func main() {
 			switch msg {
 			case BrightnessUp:
 				// Brightness increase
-				if bright := m.GetBrightness(); bright < 70 {
+				if m == nil {
+					break
+				}
+				if bright := m.GetBrightness(); bright < maxBrightness {
 					m.SetBrightness(bright + 1)
+					atomic.StoreInt32(&brightness, int32(bright+1))
 				}
 			case BrightnessDown:
 				// Brightness decrease
+				if m == nil {
+					break
+				}
 				if bright := m.GetBrightness(); bright > 0 {
 					m.SetBrightness(bright - 1)
+					atomic.StoreInt32(&brightness, int32(bright-1))
 				}
 			case ButtonPress:
 				// This will select the next display wave pattern
@@ -149,13 +383,42 @@ func main() {
 				// 	play <- struct{}{}
 				// }
 				// This will reset the current DMX data
-				dmx.ResetDMX(&dmxData)
+				dmxState.Reset()
 			case UpPress:
 				waveChan <- drawloops.GetNextWave()
 			case DownPress:
 				backgroundChan <- backgroundloops.GetNextBackgroundLoop()
 
 			}
+		case bright := <-inputBrightness:
+			if m != nil {
+				m.SetBrightness(bright)
+				atomic.StoreInt32(&brightness, int32(bright))
+			}
+		// Reload the mode parameters, on errors the previous values are kept
+		case <-reload:
+			if err := loadModeParams(*configPath); err != nil {
+				log.Println("Reloading the mode parameters failed:", err)
+			} else {
+				log.Println("Mode parameters reloaded")
+			}
+			c, err := loadCalibration(&cfg, *configPath)
+			if err == nil {
+				err = corrected.SetCalibration(c)
+			}
+			if err != nil {
+				log.Println("Reloading the color calibration failed:", err)
+			}
+		case <-powerReport.C:
+			r := power.Report()
+			log.Printf("Matrix load: average %.2f A, peak %.2f A, peak before the limiter %.2f A, lowest scale %.2f\n",
+				r.Average/1000, r.Peak/1000, r.PeakUnlimited/1000, r.MinScale)
+		case <-captureSignal:
+			if capture.Recording() {
+				capture.Stop()
+			} else if err := capture.Start(); err != nil {
+				log.Println(err)
+			}
 		// Handle the quit message by forwarding the terminate signal to all goroutines
 		case <-quit:
 			log.Println("Terminating goroutines")
@@ -168,13 +431,96 @@ func main() {
 			ss.wg.Wait()
 			close(encMessage)
 			close(waveChan)
-			close(lyricsDMXInfo)
+			unsubscribeLyrics()
 			log.Println("DONE")
 			return
 		}
 	}
 }
 
+// initModes creates all of the waves and backgrounds for a canvas of the given size
+// and returns the ones selected in the config
+func initModes(width, height int) (drawloops.Wave, backgroundloops.BackgroundLoop, error) {
+	err := drawloops.InitWaves(width, height, cfg.Display.MinVal, cfg.Display.MaxVal, cfg.Waves, cfg.WavePresets, cfg.Layouts)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = backgroundloops.InitBackgroundLoops(width, height, cfg.SoundEnergy.MinBand, cfg.SoundEnergy.MaxBand,
+		backgroundSharedParams(cfg.SoundEnergy), cfg.Backgrounds, cfg.BgPresets)
+	if err != nil {
+		return nil, nil, err
+	}
+	wave, err := drawloops.GetWaveByName(cfg.Display.Wave)
+	if err != nil {
+		return nil, nil, err
+	}
+	background, err := backgroundloops.GetBackgroundLoopByName(cfg.Display.Background)
+	if err != nil {
+		return nil, nil, err
+	}
+	return wave, background, nil
+}
+
+// printModes lists every selectable wave and background including the presets with its DMX index
+func printModes() error {
+	waves, err := drawloops.ListWaves(cfg.WavePresets, cfg.Layouts)
+	if err != nil {
+		return err
+	}
+	backgrounds, err := backgroundloops.ListBackgroundLoops(cfg.BgPresets)
+	if err != nil {
+		return err
+	}
+	printModeList("Waves", waves)
+	printModeList("Backgrounds", backgrounds)
+	return nil
+}
+
+func printModeList(title string, list []modes.Info) {
+	fmt.Println(title + ":")
+	for i, info := range list {
+		fmt.Printf("  %2d %-16s %s\n", i, info.Name, info.Description)
+		for _, p := range info.Params {
+			desc := p.Description
+			if p.Kind == modes.Choice {
+				desc += " [" + strings.Join(p.Choices, "|") + "]"
+			}
+			fmt.Printf("       %-18s %-8s default %-9s %s\n", p.Name, p.Kind, p.Default, desc)
+		}
+	}
+}
+
+// failsafeSettings converts the failsafe config to the one of the dmx package and loads its show
+func failsafeSettings(fc failsafeConfig) (dmx.FailsafeConfig, error) {
+	show := dmx.DMXData{
+		DisplayMode:        fc.Show.DisplayMode,
+		BackgroundMode:     fc.Show.BackgroundMode,
+		WhiteDots:          fc.Show.WhiteDots,
+		ColorPalette:       fc.Show.ColorPalette,
+		PaletteAngle:       fc.Show.PaletteAngle,
+		PalettePhaseOffset: fc.Show.PalettePhaseOffset,
+		Color:              color.RGBA{fc.Show.Color[0], fc.Show.Color[1], fc.Show.Color[2], 0},
+	}
+	if show.Color.R > 0 || show.Color.G > 0 || show.Color.B > 0 {
+		show.Color.A = 255
+	}
+	var recorded *dmx.Show
+	if fc.Mode == dmx.FailsafeShow && fc.Show.File != "" {
+		var err error
+		recorded, err = loadShow(fc.Show.File)
+		if err != nil {
+			return dmx.FailsafeConfig{}, fmt.Errorf("failsafe: %v", err)
+		}
+	}
+	return dmx.FailsafeConfig{
+		Timeout:  time.Duration(fc.Timeout * float64(time.Second)),
+		Mode:     fc.Mode,
+		FadeTime: time.Duration(fc.FadeTime * float64(time.Second)),
+		Show:     show,
+		Recorded: recorded,
+	}, nil
+}
+
 func addThread(wg *sync.WaitGroup, quits []chan struct{}) []chan struct{} {
 	wg.Add(1)
 	return append(quits, make(chan struct{}))
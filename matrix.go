@@ -0,0 +1,36 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	rgbmatrix "github.com/tfk1410/go-rpi-rgb-led-matrix"
+)
+
+// matrixSink shows the finished frames on the LED matrix
+type matrixSink struct {
+	m rgbmatrix.Matrix
+	c *rgbmatrix.Canvas
+}
+
+func newMatrixSink(m rgbmatrix.Matrix) *matrixSink {
+	return &matrixSink{m: m, c: rgbmatrix.NewCanvas(m)}
+}
+
+// Send copies the frame into the LED buffer and renders it on the matrix
+// the alpha channel is dropped since the matrix can't show transparency
+func (ms *matrixSink) Send(frame *image.RGBA) error {
+	w, h := ms.m.Geometry()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := frame.PixOffset(x, y)
+			ms.m.Set(y*w+x, color.RGBA{frame.Pix[i], frame.Pix[i+1], frame.Pix[i+2], 255})
+		}
+	}
+	return ms.m.Render()
+}
+
+// Close clears the matrix and closes it
+func (ms *matrixSink) Close() error {
+	return ms.c.Close()
+}
@@ -0,0 +1,206 @@
+// Package midi reads the note and control change messages from the MIDI controllers and maps them with bindings
+package midi
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// EventType is the kind of a MIDI event
+type EventType int
+
+// Types of the events passed on from the MIDI messages, the rest of the messages are skipped
+const (
+	NoteOn EventType = iota
+	NoteOff
+	ControlChange
+)
+
+func (t EventType) String() string {
+	switch t {
+	case NoteOn:
+		return "note on"
+	case NoteOff:
+		return "note off"
+	case ControlChange:
+		return "cc"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event is a note or a control change, the channel counts from 0 and the value is the velocity of the notes
+type Event struct {
+	Type    EventType
+	Channel int
+	Number  int
+	Value   int
+}
+
+// Parser decodes the events from a raw MIDI byte stream with the running status
+type Parser struct {
+	status byte
+	data   [2]byte
+	n      int
+	// sysex is set while inside of a system exclusive message
+	sysex bool
+}
+
+// Feed adds the next byte of the stream and returns the event it completes
+func (p *Parser) Feed(b byte) (Event, bool) {
+	switch {
+	case b >= 0xf8:
+		// The real time messages can show up anywhere and don't change the running status
+		return Event{}, false
+	case b == 0xf7:
+		p.sysex = false
+		return Event{}, false
+	case b >= 0xf0:
+		// The system common messages cancel the running status, their data bytes are skipped
+		p.status, p.n = 0, 0
+		p.sysex = b == 0xf0
+		return Event{}, false
+	case b >= 0x80:
+		p.status, p.n = b, 0
+		p.sysex = false
+		return Event{}, false
+	}
+
+	if p.sysex || p.status == 0 {
+		return Event{}, false
+	}
+	p.data[p.n] = b
+	p.n++
+	if p.n < p.dataLength() {
+		return Event{}, false
+	}
+	p.n = 0
+
+	e := Event{Channel: int(p.status & 0x0f), Number: int(p.data[0]), Value: int(p.data[1])}
+	switch p.status & 0xf0 {
+	case 0x80:
+		e.Type = NoteOff
+	case 0x90:
+		// A note on with zero velocity is the usual way of sending a note off with the running status
+		e.Type = NoteOn
+		if e.Value == 0 {
+			e.Type = NoteOff
+		}
+	case 0xb0:
+		e.Type = ControlChange
+	default:
+		return Event{}, false
+	}
+	return e, true
+}
+
+// dataLength returns the number of the data bytes of the running status
+func (p *Parser) dataLength() int {
+	switch p.status & 0xf0 {
+	case 0xc0, 0xd0:
+		return 1
+	}
+	return 2
+}
+
+// Source is a MIDI input, Close makes a blocked Read return
+type Source interface {
+	Read() (Event, error)
+	Close() error
+}
+
+// Binding ties a note or a control change to a target, the meaning of the target and the value is up to the user
+type Binding struct {
+	// Control is either note or cc
+	Control string `yaml:"control"`
+	// Channel counts from 1, 0 matches every channel
+	Channel int    `yaml:"channel,omitempty"`
+	Number  int    `yaml:"number"`
+	Target  string `yaml:"target"`
+	Value   int    `yaml:"value,omitempty"`
+}
+
+// Controls of the bindings
+const (
+	ControlNote = "note"
+	ControlCC   = "cc"
+)
+
+// Learn returns the binding of the control sending the event, the note offs aren't learned
+func Learn(e Event, target string, value int) (Binding, bool) {
+	b := Binding{Channel: e.Channel + 1, Number: e.Number, Target: target, Value: value}
+	switch e.Type {
+	case NoteOn:
+		b.Control = ControlNote
+	case ControlChange:
+		b.Control = ControlCC
+	default:
+		return b, false
+	}
+	return b, true
+}
+
+// Matches reports whether the event comes from the control of the binding, the note offs match the note bindings
+func (b *Binding) Matches(e Event) bool {
+	if b.Channel != 0 && b.Channel != e.Channel+1 {
+		return false
+	}
+	if b.Number != e.Number {
+		return false
+	}
+	if e.Type == ControlChange {
+		return b.Control == ControlCC
+	}
+	return b.Control == ControlNote
+}
+
+// SameControl reports whether both of the bindings are set by the same control
+func (b *Binding) SameControl(o Binding) bool {
+	return b.Control == o.Control && b.Channel == o.Channel && b.Number == o.Number
+}
+
+// Validate checks the control, channel and number of the binding
+func (b *Binding) Validate() error {
+	if b.Control != ControlNote && b.Control != ControlCC {
+		return fmt.Errorf("midi: binding of %s has the control %q, use note or cc", b.Target, b.Control)
+	}
+	if b.Channel < 0 || b.Channel > 16 {
+		return fmt.Errorf("midi: binding of %s has the channel %d out of the range 0..16", b.Target, b.Channel)
+	}
+	if b.Number < 0 || b.Number > 127 {
+		return fmt.Errorf("midi: binding of %s has the number %d out of the range 0..127", b.Target, b.Number)
+	}
+	return nil
+}
+
+// Run passes the events of the source to the handler until the quit signal, the source is closed on return
+// when the source ends or fails the thread only waits for the quit signal
+func Run(src Source, handle func(Event), wg *sync.WaitGroup, quit <-chan struct{}) {
+	defer wg.Done()
+
+	// Closing the source is the only way to interrupt a blocked read
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-quit:
+		case <-done:
+		}
+		src.Close()
+	}()
+
+	for {
+		e, err := src.Read()
+		if err != nil {
+			select {
+			case <-quit:
+			default:
+				log.Println("MIDI input stopped:", err)
+				<-quit
+			}
+			log.Println("Stopping MIDI thread")
+			return
+		}
+		handle(e)
+	}
+}
@@ -0,0 +1,111 @@
+package midi
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testStream has the running status, a real time clock in the middle of a message, a system exclusive
+// message and a program change which isn't passed on
+var testStream = []byte{
+	0x90, 60, 100, 62, 0xf8, 90, 60, 0,
+	0xf0, 0x7e, 0x10, 0xf7,
+	0xb1, 7, 127, 0xc0, 5, 0x81, 64, 10,
+}
+
+var testEvents = []Event{
+	{NoteOn, 0, 60, 100},
+	{NoteOn, 0, 62, 90},
+	{NoteOff, 0, 60, 0},
+	{ControlChange, 1, 7, 127},
+	{NoteOff, 1, 64, 10},
+}
+
+func TestParser(t *testing.T) {
+	var p Parser
+	var events []Event
+	for _, b := range testStream {
+		if e, ok := p.Feed(b); ok {
+			events = append(events, e)
+		}
+	}
+	if len(events) != len(testEvents) {
+		t.Fatalf("event count mismatch. Want: %v, Have: %v\n", testEvents, events)
+	}
+	for i := range testEvents {
+		if events[i] != testEvents[i] {
+			t.Errorf("event %d mismatch. Want: %v, Have: %v\n", i, testEvents[i], events[i])
+		}
+	}
+}
+
+func TestBinding(t *testing.T) {
+	b, ok := Learn(Event{ControlChange, 1, 7, 127}, "brightness", 0)
+	if !ok {
+		t.Fatal("control change not learned")
+	}
+	if want := (Binding{Control: ControlCC, Channel: 2, Number: 7, Target: "brightness"}); b != want {
+		t.Errorf("learned binding mismatch. Want: %v, Have: %v\n", want, b)
+	}
+	if _, ok := Learn(Event{NoteOff, 0, 60, 0}, "beat", 0); ok {
+		t.Errorf("note off learned\n")
+	}
+
+	matches := []struct {
+		e    Event
+		want bool
+	}{
+		{Event{ControlChange, 1, 7, 10}, true},
+		{Event{ControlChange, 0, 7, 10}, false},
+		{Event{ControlChange, 1, 8, 10}, false},
+		{Event{NoteOn, 1, 7, 10}, false},
+	}
+	for _, m := range matches {
+		if have := b.Matches(m.e); have != m.want {
+			t.Errorf("match of %v mismatch. Want: %v, Have: %v\n", m.e, m.want, have)
+		}
+	}
+
+	// The bindings without a channel match every channel and the note offs of their notes
+	note := Binding{Control: ControlNote, Number: 36, Target: "beat"}
+	if !note.Matches(Event{NoteOff, 9, 36, 0}) {
+		t.Errorf("note off of any channel not matched\n")
+	}
+	if err := (&Binding{Control: "pitch", Target: "wave"}).Validate(); err == nil {
+		t.Errorf("unknown control not rejected\n")
+	}
+}
+
+func TestRunRaw(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "controller.mid")
+	if err := os.WriteFile(path, testStream, 0644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := OpenRaw(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan Event, len(testEvents))
+	var wg sync.WaitGroup
+	quit := make(chan struct{})
+	wg.Add(1)
+	go Run(src, func(e Event) { received <- e }, &wg, quit)
+
+	for i := range testEvents {
+		select {
+		case e := <-received:
+			if e != testEvents[i] {
+				t.Errorf("event %d mismatch. Want: %v, Have: %v\n", i, testEvents[i], e)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no event received")
+		}
+	}
+	// The thread keeps waiting for the quit signal at the end of the file
+	close(quit)
+	wg.Wait()
+}
@@ -0,0 +1,41 @@
+package midi
+
+import (
+	"bufio"
+	"os"
+)
+
+// RawSource reads the raw MIDI bytes from a device like /dev/snd/midiC1D0, a FIFO or a file
+// recorded with amidi -r, a file ends with io.EOF
+type RawSource struct {
+	f      *os.File
+	r      *bufio.Reader
+	parser Parser
+}
+
+// OpenRaw opens the raw MIDI device or file
+func OpenRaw(path string) (*RawSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &RawSource{f: f, r: bufio.NewReader(f)}, nil
+}
+
+// Read returns the next note or control change
+func (s *RawSource) Read() (Event, error) {
+	for {
+		b, err := s.r.ReadByte()
+		if err != nil {
+			return Event{}, err
+		}
+		if e, ok := s.parser.Feed(b); ok {
+			return e, nil
+		}
+	}
+}
+
+// Close closes the file
+func (s *RawSource) Close() error {
+	return s.f.Close()
+}
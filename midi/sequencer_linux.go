@@ -0,0 +1,275 @@
+package midi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// The ALSA sequencer interface of the kernel from sound/asequencer.h
+const (
+	seqDevice = "/dev/snd/seq"
+
+	iocWrite = 1
+	iocRead  = 2
+
+	seqPortCapWrite     = 1 << 1
+	seqPortCapSubsWrite = 1 << 6
+
+	seqPortTypeMIDIGeneric = 1 << 1
+	seqPortTypeApplication = 1 << 20
+
+	seqEventSize           = 28
+	seqEventNoteOn         = 6
+	seqEventNoteOff        = 7
+	seqEventController     = 10
+	seqEventLengthMask     = 3 << 2
+	seqEventLengthVariable = 1 << 2
+	seqExtMask             = 0xc0000000
+)
+
+type seqClientInfo struct {
+	client          int32
+	typ             int32
+	name            [64]byte
+	filter          uint32
+	multicastFilter [8]byte
+	eventFilter     [32]byte
+	numPorts        int32
+	eventLost       int32
+	card            int32
+	pid             int32
+	reserved        [56]byte
+}
+
+type seqPortInfo struct {
+	addr         [2]byte
+	name         [64]byte
+	capability   uint32
+	typ          uint32
+	midiChannels int32
+	midiVoices   int32
+	synthVoices  int32
+	readUse      int32
+	writeUse     int32
+	kernel       uintptr
+	flags        uint32
+	timeQueue    byte
+	reserved     [59]byte
+}
+
+type seqPortSubscribe struct {
+	sender   [2]byte
+	dest     [2]byte
+	voices   uint32
+	flags    uint32
+	queue    byte
+	pad      [3]byte
+	reserved [64]byte
+}
+
+func seqIoctl(dir, nr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | 'S'<<8 | nr
+}
+
+var (
+	seqIoctlClientID        = seqIoctl(iocRead, 0x01, 4)
+	seqIoctlGetClientInfo   = seqIoctl(iocRead|iocWrite, 0x10, unsafe.Sizeof(seqClientInfo{}))
+	seqIoctlSetClientInfo   = seqIoctl(iocWrite, 0x11, unsafe.Sizeof(seqClientInfo{}))
+	seqIoctlCreatePort      = seqIoctl(iocRead|iocWrite, 0x20, unsafe.Sizeof(seqPortInfo{}))
+	seqIoctlSubscribePort   = seqIoctl(iocWrite, 0x30, unsafe.Sizeof(seqPortSubscribe{}))
+	seqIoctlQueryNextClient = seqIoctl(iocRead|iocWrite, 0x51, unsafe.Sizeof(seqClientInfo{}))
+)
+
+// Sequencer is an ALSA sequencer client with a single input port the controllers can be connected to
+// with aconnect or by the connect address given to OpenSequencer
+type Sequencer struct {
+	f       *os.File
+	rc      syscall.RawConn
+	client  int
+	port    int
+	buf     []byte
+	pending []byte
+}
+
+// OpenSequencer creates the sequencer client with the input port of the name, a non empty connect address
+// subscribes the port to a source given as client:port, the client can be its number or its name
+func OpenSequencer(name, connect string) (*Sequencer, error) {
+	f, err := os.OpenFile(seqDevice, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sequencer{f: f, buf: make([]byte, 4096)}
+	if err := s.setup(name, connect); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Sequencer) setup(name, connect string) error {
+	// The raw connection keeps the file in the non-blocking mode so Close interrupts a read
+	rc, err := s.f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	s.rc = rc
+
+	var client int32
+	if err := s.ioctl(seqIoctlClientID, unsafe.Pointer(&client)); err != nil {
+		return fmt.Errorf("midi: sequencer client ID: %v", err)
+	}
+	s.client = int(client)
+
+	info := seqClientInfo{client: client}
+	if err := s.ioctl(seqIoctlGetClientInfo, unsafe.Pointer(&info)); err != nil {
+		return fmt.Errorf("midi: sequencer client info: %v", err)
+	}
+	info.name = [64]byte{}
+	copy(info.name[:63], name)
+	if err := s.ioctl(seqIoctlSetClientInfo, unsafe.Pointer(&info)); err != nil {
+		return fmt.Errorf("midi: sequencer client name: %v", err)
+	}
+
+	port := seqPortInfo{
+		addr:       [2]byte{byte(client), 0},
+		capability: seqPortCapWrite | seqPortCapSubsWrite,
+		typ:        seqPortTypeMIDIGeneric | seqPortTypeApplication,
+	}
+	copy(port.name[:63], name)
+	if err := s.ioctl(seqIoctlCreatePort, unsafe.Pointer(&port)); err != nil {
+		return fmt.Errorf("midi: sequencer port: %v", err)
+	}
+	s.port = int(port.addr[1])
+
+	if connect == "" {
+		return nil
+	}
+	sender, err := s.resolve(connect)
+	if err != nil {
+		return err
+	}
+	sub := seqPortSubscribe{sender: sender, dest: [2]byte{byte(s.client), byte(s.port)}}
+	if err := s.ioctl(seqIoctlSubscribePort, unsafe.Pointer(&sub)); err != nil {
+		return fmt.Errorf("midi: connecting %s: %v", connect, err)
+	}
+	return nil
+}
+
+// resolve returns the sequencer address of client:port, the port defaults to 0
+func (s *Sequencer) resolve(address string) ([2]byte, error) {
+	clientName, portName := address, "0"
+	if i := strings.LastIndex(address, ":"); i >= 0 {
+		clientName, portName = address[:i], address[i+1:]
+	}
+	port, err := strconv.Atoi(portName)
+	if err != nil || port < 0 || port > 255 {
+		return [2]byte{}, fmt.Errorf("midi: port of %s isn't a number", address)
+	}
+	if client, err := strconv.Atoi(clientName); err == nil {
+		if client < 0 || client > 255 {
+			return [2]byte{}, fmt.Errorf("midi: client of %s is out of the range 0..255", address)
+		}
+		return [2]byte{byte(client), byte(port)}, nil
+	}
+
+	// The name matches a client exactly or by its start like with aconnect
+	match := -1
+	info := seqClientInfo{client: -1}
+	for s.ioctl(seqIoctlQueryNextClient, unsafe.Pointer(&info)) == nil {
+		n := info.name[:]
+		if i := strings.IndexByte(string(n), 0); i >= 0 {
+			n = n[:i]
+		}
+		if string(n) == clientName {
+			match = int(info.client)
+			break
+		}
+		if match < 0 && strings.HasPrefix(string(n), clientName) {
+			match = int(info.client)
+		}
+	}
+	if match < 0 {
+		return [2]byte{}, fmt.Errorf("midi: no sequencer client %q", clientName)
+	}
+	return [2]byte{byte(match), byte(port)}, nil
+}
+
+func (s *Sequencer) ioctl(req uintptr, arg unsafe.Pointer) error {
+	var errno syscall.Errno
+	err := s.rc.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Addr returns the client:port address of the input port
+func (s *Sequencer) Addr() string {
+	return fmt.Sprintf("%d:%d", s.client, s.port)
+}
+
+// Read returns the next note or control change sent to the port
+func (s *Sequencer) Read() (Event, error) {
+	for {
+		for len(s.pending) >= seqEventSize {
+			e, ok := s.next()
+			if ok {
+				return e, nil
+			}
+		}
+		n, err := s.f.Read(s.buf)
+		if err != nil {
+			return Event{}, err
+		}
+		s.pending = s.buf[:n]
+	}
+}
+
+// next decodes the event at the start of the pending data, the events are in the byte order of the machine
+// which is little endian on the Pi
+func (s *Sequencer) next() (Event, bool) {
+	ev := s.pending[:seqEventSize]
+	s.pending = s.pending[seqEventSize:]
+	data := ev[16:]
+	if ev[1]&seqEventLengthMask == seqEventLengthVariable {
+		// The data of the variable length events like the system exclusive ones follows the event
+		n := int(binary.LittleEndian.Uint32(data) &^ seqExtMask)
+		if n > len(s.pending) {
+			n = len(s.pending)
+		}
+		s.pending = s.pending[n:]
+		return Event{}, false
+	}
+
+	switch ev[0] {
+	case seqEventNoteOn, seqEventNoteOff:
+		e := Event{Type: NoteOn, Channel: int(data[0] & 0x0f), Number: int(data[1] & 0x7f), Value: int(data[2] & 0x7f)}
+		if ev[0] == seqEventNoteOff || e.Value == 0 {
+			e.Type = NoteOff
+		}
+		return e, true
+	case seqEventController:
+		return Event{
+			Type:    ControlChange,
+			Channel: int(data[0] & 0x0f),
+			Number:  int(binary.LittleEndian.Uint32(data[4:]) & 0x7f),
+			Value:   int(int32(binary.LittleEndian.Uint32(data[8:])) & 0x7f),
+		}, true
+	}
+	return Event{}, false
+}
+
+// Close closes the sequencer client together with its port
+func (s *Sequencer) Close() error {
+	return s.f.Close()
+}
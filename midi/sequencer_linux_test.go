@@ -0,0 +1,56 @@
+package midi
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestSequencerStructs(t *testing.T) {
+	// The sizes are a part of the ioctl numbers so they have to match the kernel structs
+	ptr := int(unsafe.Sizeof(uintptr(0)))
+	sizes := []struct {
+		name       string
+		want, have int
+	}{
+		{"snd_seq_client_info", 188, int(unsafe.Sizeof(seqClientInfo{}))},
+		{"snd_seq_port_info", 164 + ptr - 4, int(unsafe.Sizeof(seqPortInfo{}))},
+		{"snd_seq_port_subscribe", 80, int(unsafe.Sizeof(seqPortSubscribe{}))},
+	}
+	for _, s := range sizes {
+		if s.want != s.have {
+			t.Errorf("%s size mismatch. Want: %v, Have: %v\n", s.name, s.want, s.have)
+		}
+	}
+}
+
+func TestSequencerEvents(t *testing.T) {
+	event := func(typ, flags byte, data ...byte) []byte {
+		ev := make([]byte, seqEventSize)
+		ev[0], ev[1] = typ, flags
+		copy(ev[16:], data)
+		return ev
+	}
+	var pending []byte
+	pending = append(pending, event(seqEventNoteOn, 0, 2, 60, 100)...)
+	// A system exclusive event with its 3 data bytes is skipped
+	pending = append(pending, event(130, seqEventLengthVariable, 3)...)
+	pending = append(pending, 0xf0, 0x7e, 0xf7)
+	pending = append(pending, event(seqEventController, 0, 15, 0, 0, 0, 7, 0, 0, 0, 127)...)
+
+	s := &Sequencer{pending: pending}
+	var events []Event
+	for len(s.pending) >= seqEventSize {
+		if e, ok := s.next(); ok {
+			events = append(events, e)
+		}
+	}
+	want := []Event{{NoteOn, 2, 60, 100}, {ControlChange, 15, 7, 127}}
+	if len(events) != len(want) {
+		t.Fatalf("event count mismatch. Want: %v, Have: %v\n", want, events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d mismatch. Want: %v, Have: %v\n", i, want[i], events[i])
+		}
+	}
+}
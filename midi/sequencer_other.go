@@ -0,0 +1,29 @@
+//go:build !linux
+// +build !linux
+
+package midi
+
+import "errors"
+
+// Sequencer is only available with the ALSA sequencer of Linux
+type Sequencer struct{}
+
+// OpenSequencer fails outside of Linux
+func OpenSequencer(name, connect string) (*Sequencer, error) {
+	return nil, errors.New("midi: the ALSA sequencer is only available on Linux")
+}
+
+// Addr returns an empty address
+func (s *Sequencer) Addr() string {
+	return ""
+}
+
+// Read never returns an event
+func (s *Sequencer) Read() (Event, error) {
+	return Event{}, errors.New("midi: the ALSA sequencer is only available on Linux")
+}
+
+// Close does nothing
+func (s *Sequencer) Close() error {
+	return nil
+}
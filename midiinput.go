@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/TFK1410/go-rpi-fftwave/dmx"
+	"github.com/TFK1410/go-rpi-fftwave/drawloops"
+	"github.com/TFK1410/go-rpi-fftwave/midi"
+	"github.com/TFK1410/go-rpi-fftwave/palette"
+)
+
+var midiLearn = flag.String("midi-learn", "", "Bind the next MIDI note or control to a target (wave, background, palette, brightness, beat) and save it to the config, "+
+	"the index or brightness set by a note follows an equal sign like wave=3")
+
+// Targets of the MIDI bindings
+const (
+	midiTargetWave       = "wave"
+	midiTargetBackground = "background"
+	midiTargetPalette    = "palette"
+	midiTargetBrightness = "brightness"
+	midiTargetBeat       = "beat"
+)
+
+// midiInput maps the MIDI events onto the controls with the bindings, it's only used by the MIDI thread
+type midiInput struct {
+	*controls
+	bindings []midi.Binding
+	// learn is the binding waiting for its control, nil when not learning
+	learn      *midi.Binding
+	configPath string
+}
+
+func newMIDIInput(c *controls, mc midiConfig, learn, configPath string) (*midiInput, error) {
+	for i := range mc.Bindings {
+		b := &mc.Bindings[i]
+		if err := b.Validate(); err != nil {
+			return nil, err
+		}
+		if err := validateMIDITarget(b.Target, b.Value); err != nil {
+			return nil, err
+		}
+	}
+	in := &midiInput{controls: c, bindings: mc.Bindings, configPath: configPath}
+	if learn != "" {
+		b, err := parseMIDITarget(learn)
+		if err != nil {
+			return nil, err
+		}
+		in.learn = &b
+	}
+	return in, nil
+}
+
+// parseMIDITarget reads the target of the learn flag with its optional value
+func parseMIDITarget(s string) (midi.Binding, error) {
+	b := midi.Binding{Target: s}
+	if i := strings.Index(s, "="); i >= 0 {
+		v, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return b, fmt.Errorf("midi: value of %s isn't a number", s)
+		}
+		b.Target, b.Value = s[:i], v
+	}
+	return b, validateMIDITarget(b.Target, b.Value)
+}
+
+func validateMIDITarget(target string, value int) error {
+	switch target {
+	case midiTargetWave, midiTargetBackground, midiTargetPalette, midiTargetBrightness, midiTargetBeat:
+	default:
+		return fmt.Errorf("midi: unknown target %q, use wave, background, palette, brightness or beat", target)
+	}
+	if value < 0 || value > 255 {
+		return fmt.Errorf("midi: value %d of %s is out of the range 0..255", value, target)
+	}
+	return nil
+}
+
+// handle applies the event with the bindings of its control or learns it
+func (in *midiInput) handle(e midi.Event) {
+	if in.learn != nil {
+		b, ok := midi.Learn(e, in.learn.Target, in.learn.Value)
+		if !ok {
+			return
+		}
+		in.learn = nil
+		in.bind(b)
+		log.Printf("MIDI %s %d on channel %d bound to %s\n", b.Control, b.Number, b.Channel, b.Target)
+		if err := saveConfigValue(in.configPath, in.bindings, "midiConfig", "bindings"); err != nil {
+			log.Println("Saving the MIDI bindings failed:", err)
+		}
+		return
+	}
+
+	for i := range in.bindings {
+		if in.bindings[i].Matches(e) {
+			in.apply(&in.bindings[i], e)
+		}
+	}
+}
+
+// bind adds the binding in place of the one of the same control
+func (in *midiInput) bind(b midi.Binding) {
+	for i := range in.bindings {
+		if in.bindings[i].SameControl(b) {
+			in.bindings[i] = b
+			return
+		}
+	}
+	in.bindings = append(in.bindings, b)
+}
+
+// apply sets the target of the binding, the control changes cover the whole range of the target
+// and the notes set the value of the binding
+func (in *midiInput) apply(b *midi.Binding, e midi.Event) {
+	if e.Type == midi.NoteOff {
+		return
+	}
+	switch b.Target {
+	case midiTargetWave:
+		if i, ok := midiIndex(b, e, len(in.waves)); ok {
+			in.state.Update(func(data *dmx.DMXData) { data.DisplayMode = byte(i) })
+		}
+	case midiTargetBackground:
+		if i, ok := midiIndex(b, e, len(in.backgrounds)); ok {
+			in.state.Update(func(data *dmx.DMXData) { data.BackgroundMode = byte(i) })
+		}
+	case midiTargetPalette:
+		if i, ok := midiIndex(b, e, len(palette.Names)); ok {
+			in.state.Update(func(data *dmx.DMXData) { data.ColorPalette = byte(i) })
+		}
+	case midiTargetBrightness:
+		if e.Type == midi.ControlChange {
+			in.setBrightness(float64(e.Value) * maxBrightness / 127)
+		} else {
+			in.setBrightness(float64(b.Value))
+		}
+	case midiTargetBeat:
+		// The control changes fire from the value of 64 up like the buttons sending 127 when pressed
+		if e.Type == midi.NoteOn || e.Value >= 64 {
+			drawloops.TriggerBeat()
+		}
+	}
+}
+
+// midiIndex returns the index of a mode out of n selected by the event
+func midiIndex(b *midi.Binding, e midi.Event, n int) (int, bool) {
+	if e.Type == midi.ControlChange {
+		return e.Value * n / 128, n > 0
+	}
+	return b.Value, b.Value < n
+}
+
+// openMIDI opens the MIDI source of the config
+func openMIDI(mc midiConfig) (midi.Source, error) {
+	switch mc.Input {
+	case "seq":
+		s, err := midi.OpenSequencer(mc.Name, mc.Connect)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("Listening for MIDI on the sequencer port", s.Addr())
+		return s, nil
+	case "raw":
+		s, err := midi.OpenRaw(mc.Device)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("Reading MIDI from", mc.Device)
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown MIDI input %q", mc.Input)
+}
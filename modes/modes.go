@@ -0,0 +1,25 @@
+// Package modes holds the descriptions of the wave and background modes that are shared
+// between the draw loop packages and anything that wants to list, select or adjust them
+package modes
+
+// Info describes a single registered mode or a preset of one
+// the name is stable and can be used to refer to the mode from the config or any control interface
+// Type is the name of the registered mode implementing it, which is the same as Name for everything but presets
+type Info struct {
+	Name        string
+	Type        string
+	Description string
+	Params      []Param
+}
+
+// Preset defines a named instance of a registered mode with its own parameter values
+// the values are applied on top of the ones from the config section of the mode it's based on
+type Preset struct {
+	Name        string                 `yaml:"name"`
+	Type        string                 `yaml:"type"`
+	Description string                 `yaml:"description,omitempty"`
+	Params      map[string]interface{} `yaml:"params,omitempty"`
+}
+
+// Config holds the parameter values of every mode decoded from the config file, keyed by the mode name
+type Config map[string]map[string]interface{}
@@ -0,0 +1,260 @@
+package modes
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Kind defines the type of the value held by a parameter
+type Kind int
+
+// Definition of the parameter kinds
+const (
+	Float Kind = iota
+	Int
+	Duration
+	Bool
+	Choice
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Float:
+		return "float"
+	case Int:
+		return "int"
+	case Duration:
+		return "duration"
+	case Bool:
+		return "bool"
+	case Choice:
+		return "choice"
+	}
+	return "unknown"
+}
+
+// Param describes a single adjustable parameter of a mode
+// Default is written in the same form as the value would be written in the config file
+// Min and Max limit the numeric values (in seconds for durations), the range is not checked if both are zero
+type Param struct {
+	Name        string
+	Description string
+	Kind        Kind
+	Default     string
+	Min, Max    float64
+	Choices     []string
+}
+
+// parse converts the text form of the value into the type defined by the parameter kind and validates it
+func (p Param) parse(text string) (interface{}, error) {
+	var num float64
+	var out interface{}
+
+	switch p.Kind {
+	case Float:
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %q is not a number", p.Name, text)
+		}
+		num, out = v, v
+	case Int:
+		v, err := strconv.Atoi(text)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %q is not an integer", p.Name, text)
+		}
+		num, out = float64(v), v
+	case Duration:
+		// Plain numbers are treated as seconds
+		v, err := time.ParseDuration(text)
+		if err != nil {
+			secs, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parameter %s: %q is not a duration", p.Name, text)
+			}
+			v = time.Duration(secs * float64(time.Second))
+		}
+		num, out = v.Seconds(), v
+	case Bool:
+		v, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %q is not a boolean", p.Name, text)
+		}
+		return v, nil
+	case Choice:
+		for _, c := range p.Choices {
+			if c == text {
+				return text, nil
+			}
+		}
+		return nil, fmt.Errorf("parameter %s: %q is not one of %v", p.Name, text, p.Choices)
+	default:
+		return nil, fmt.Errorf("parameter %s: unknown kind %v", p.Name, p.Kind)
+	}
+
+	if (p.Min != 0 || p.Max != 0) && (num < p.Min || num > p.Max) {
+		return nil, fmt.Errorf("parameter %s: %s is out of the range %v..%v", p.Name, text, p.Min, p.Max)
+	}
+	return out, nil
+}
+
+// Params holds the current values of the parameters of a single mode instance
+// it's safe to read and change the values from multiple goroutines
+type Params struct {
+	mu      sync.RWMutex
+	schema  []Param
+	values  map[string]interface{}
+	version uint64
+}
+
+// NewParams creates a parameter set with every value set to its default
+func NewParams(schema []Param) *Params {
+	p := &Params{
+		schema: schema,
+		values: make(map[string]interface{}, len(schema)),
+	}
+	for _, s := range schema {
+		v, err := s.parse(s.Default)
+		if err != nil {
+			panic("modes: invalid default of " + err.Error())
+		}
+		p.values[s.Name] = v
+	}
+	return p
+}
+
+// Schema returns the descriptions of the parameters in the set
+func (p *Params) Schema() []Param {
+	return p.schema
+}
+
+// Has checks if the set contains a parameter with the given name
+func (p *Params) Has(name string) bool {
+	_, ok := p.param(name)
+	return ok
+}
+
+func (p *Params) param(name string) (Param, bool) {
+	for _, s := range p.schema {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Param{}, false
+}
+
+// Set validates and changes a single parameter value given in its text form
+func (p *Params) Set(name, value string) error {
+	s, ok := p.param(name)
+	if !ok {
+		return fmt.Errorf("unknown parameter %q", name)
+	}
+	v, err := s.parse(value)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.values[name] = v
+	p.version++
+	p.mu.Unlock()
+	return nil
+}
+
+// Load sets all of the parameters from the values decoded from a config section, the ones missing from it go back
+// to their defaults so a reloaded config doesn't keep the values of the removed keys
+// the values are validated first so that nothing is changed if any of them is invalid
+func (p *Params) Load(values map[string]interface{}) error {
+	parsed := make(map[string]interface{}, len(p.schema))
+	for _, s := range p.schema {
+		// The defaults were already checked by NewParams
+		parsed[s.Name], _ = s.parse(s.Default)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	// Sorted so that the reported error doesn't depend on the map order
+	sort.Strings(names)
+
+	for _, name := range names {
+		s, ok := p.param(name)
+		if !ok {
+			return fmt.Errorf("unknown parameter %q", name)
+		}
+		v, err := s.parse(fmt.Sprint(values[name]))
+		if err != nil {
+			return err
+		}
+		parsed[name] = v
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	changed := false
+	for name, v := range parsed {
+		if p.values[name] != v {
+			p.values[name] = v
+			changed = true
+		}
+	}
+	// The version only goes up with a real change so the modes don't start over on every reload
+	if changed {
+		p.version++
+	}
+	return nil
+}
+
+// Version returns a counter which is increased with every change of the values
+// modes use it to find out when they have to recalculate the values derived from the parameters
+func (p *Params) Version() uint64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.version
+}
+
+// Get returns the value of the parameter in its text form
+func (p *Params) Get(name string) string {
+	v := p.value(name)
+	if d, ok := v.(time.Duration); ok {
+		return d.String()
+	}
+	return fmt.Sprint(v)
+}
+
+func (p *Params) value(name string) interface{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	v, ok := p.values[name]
+	if !ok {
+		panic(fmt.Sprintf("modes: unknown parameter %q", name))
+	}
+	return v
+}
+
+// Float returns the value of a float parameter
+func (p *Params) Float(name string) float64 {
+	return p.value(name).(float64)
+}
+
+// Int returns the value of an int parameter
+func (p *Params) Int(name string) int {
+	return p.value(name).(int)
+}
+
+// Duration returns the value of a duration parameter
+func (p *Params) Duration(name string) time.Duration {
+	return p.value(name).(time.Duration)
+}
+
+// Bool returns the value of a bool parameter
+func (p *Params) Bool(name string) bool {
+	return p.value(name).(bool)
+}
+
+// String returns the value of a choice parameter
+func (p *Params) String(name string) string {
+	return p.value(name).(string)
+}
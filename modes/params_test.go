@@ -0,0 +1,109 @@
+package modes
+
+import (
+	"testing"
+	"time"
+)
+
+var testSchema = []Param{
+	{Name: "speed", Kind: Float, Default: "1.5", Min: 0, Max: 10},
+	{Name: "count", Kind: Int, Default: "3", Min: 1, Max: 5},
+	{Name: "span", Kind: Duration, Default: "20ms", Min: 0.001, Max: 1},
+	{Name: "enabled", Kind: Bool, Default: "true"},
+	{Name: "mode", Kind: Choice, Default: "a", Choices: []string{"a", "b"}},
+}
+
+func TestDefaults(t *testing.T) {
+	p := NewParams(testSchema)
+	if have := p.Float("speed"); have != 1.5 {
+		t.Errorf("speed mismatch. Want: %v, Have: %v\n", 1.5, have)
+	}
+	if have := p.Int("count"); have != 3 {
+		t.Errorf("count mismatch. Want: %v, Have: %v\n", 3, have)
+	}
+	if have := p.Duration("span"); have != 20*time.Millisecond {
+		t.Errorf("span mismatch. Want: %v, Have: %v\n", 20*time.Millisecond, have)
+	}
+	if have := p.Bool("enabled"); !have {
+		t.Errorf("enabled mismatch. Want: %v, Have: %v\n", true, have)
+	}
+	if have := p.String("mode"); have != "a" {
+		t.Errorf("mode mismatch. Want: %v, Have: %v\n", "a", have)
+	}
+}
+
+func TestSet(t *testing.T) {
+	p := NewParams(testSchema)
+	version := p.Version()
+
+	for _, bad := range [][2]string{
+		{"speed", "fast"},
+		{"speed", "11"},
+		{"count", "0"},
+		{"count", "2.5"},
+		{"span", "2s"},
+		{"enabled", "maybe"},
+		{"mode", "c"},
+		{"missing", "1"},
+	} {
+		if err := p.Set(bad[0], bad[1]); err == nil {
+			t.Errorf("expected an error when setting %s to %q\n", bad[0], bad[1])
+		}
+	}
+	if p.Version() != version {
+		t.Errorf("invalid values should not change the version\n")
+	}
+
+	if err := p.Set("span", "0.5"); err != nil {
+		t.Fatal(err)
+	}
+	if have := p.Duration("span"); have != 500*time.Millisecond {
+		t.Errorf("span mismatch. Want: %v, Have: %v\n", 500*time.Millisecond, have)
+	}
+	if p.Version() == version {
+		t.Errorf("a change should increase the version\n")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	p := NewParams(testSchema)
+
+	// Values as decoded by the yaml package
+	err := p.Load(map[string]interface{}{"speed": 2, "count": 5, "mode": "b", "enabled": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Float("speed") != 2 || p.Int("count") != 5 || p.String("mode") != "b" || p.Bool("enabled") {
+		t.Errorf("loaded values mismatch: %v %v %v %v\n", p.Float("speed"), p.Int("count"), p.String("mode"), p.Bool("enabled"))
+	}
+
+	// Nothing should be applied when one of the values is invalid
+	err = p.Load(map[string]interface{}{"speed": 3, "count": 9})
+	if err == nil {
+		t.Errorf("expected an error for an out of range value\n")
+	}
+	if have := p.Float("speed"); have != 2 {
+		t.Errorf("speed mismatch. Want: %v, Have: %v\n", 2, have)
+	}
+
+	// The values left out of a reload go back to their defaults
+	version := p.Version()
+	err = p.Load(map[string]interface{}{"speed": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := NewParams(testSchema)
+	if p.Int("count") != defaults.Int("count") || p.String("mode") != defaults.String("mode") || p.Bool("enabled") != defaults.Bool("enabled") {
+		t.Errorf("reset values mismatch. Want: %v %v %v, Have: %v %v %v\n", defaults.Int("count"), defaults.String("mode"), defaults.Bool("enabled"),
+			p.Int("count"), p.String("mode"), p.Bool("enabled"))
+	}
+	if p.Version() == version {
+		t.Errorf("a reset should increase the version\n")
+	}
+
+	// Loading the same values again isn't a change
+	version = p.Version()
+	if err := p.Load(map[string]interface{}{"speed": 2}); err != nil || p.Version() != version {
+		t.Errorf("version mismatch. Want: %v, Have: %v %v\n", version, p.Version(), err)
+	}
+}
@@ -0,0 +1,246 @@
+// Package osc implements the Open Sound Control 1.0 messages and a UDP server receiving them
+package osc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// bundleID starts every OSC bundle
+const bundleID = "#bundle\x00"
+
+// Message is an OSC message, the arguments are int32, float32, string, []byte (blob), int64, float64,
+// bool (true, false and impulse), nil and color.RGBA values
+type Message struct {
+	Address string
+	Args    []interface{}
+}
+
+// ErrMalformed is returned for the packets which aren't valid OSC messages or bundles
+var ErrMalformed = errors.New("osc: malformed packet")
+
+// ParsePacket decodes a message or a bundle, the messages of the bundles are returned in their order
+// with the time tags ignored, the blobs refer to the packet buffer
+func ParsePacket(packet []byte) ([]Message, error) {
+	if len(packet) == 0 || len(packet)%4 != 0 {
+		return nil, ErrMalformed
+	}
+	if packet[0] == '#' {
+		return parseBundle(packet, nil)
+	}
+	m, err := parseMessage(packet)
+	if err != nil {
+		return nil, err
+	}
+	return []Message{m}, nil
+}
+
+func parseBundle(packet []byte, out []Message) ([]Message, error) {
+	// The bundle ID is followed by the 8 byte time tag
+	if len(packet) < 16 || string(packet[:8]) != bundleID {
+		return nil, ErrMalformed
+	}
+	b := packet[16:]
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, ErrMalformed
+		}
+		size := int(binary.BigEndian.Uint32(b))
+		b = b[4:]
+		if size <= 0 || size%4 != 0 || size > len(b) {
+			return nil, ErrMalformed
+		}
+		element := b[:size]
+		b = b[size:]
+
+		if element[0] == '#' {
+			var err error
+			if out, err = parseBundle(element, out); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		m, err := parseMessage(element)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+func parseMessage(b []byte) (Message, error) {
+	var m Message
+	address, b, err := readString(b)
+	if err != nil || len(address) == 0 || address[0] != '/' {
+		return m, ErrMalformed
+	}
+	m.Address = address
+
+	// The old senders leave out the type tags of the messages without arguments
+	if len(b) == 0 {
+		return m, nil
+	}
+	tags, b, err := readString(b)
+	if err != nil || len(tags) == 0 || tags[0] != ',' {
+		return m, ErrMalformed
+	}
+
+	for _, tag := range tags[1:] {
+		var arg interface{}
+		switch tag {
+		case 'i', 'f', 'r':
+			if len(b) < 4 {
+				return m, ErrMalformed
+			}
+			v := binary.BigEndian.Uint32(b)
+			b = b[4:]
+			switch tag {
+			case 'i':
+				arg = int32(v)
+			case 'f':
+				arg = math.Float32frombits(v)
+			case 'r':
+				arg = color.RGBA{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+			}
+		case 'h', 'd':
+			if len(b) < 8 {
+				return m, ErrMalformed
+			}
+			v := binary.BigEndian.Uint64(b)
+			b = b[8:]
+			if tag == 'h' {
+				arg = int64(v)
+			} else {
+				arg = math.Float64frombits(v)
+			}
+		case 's', 'S':
+			if arg, b, err = readString(b); err != nil {
+				return m, err
+			}
+		case 'b':
+			if len(b) < 4 {
+				return m, ErrMalformed
+			}
+			size := int(binary.BigEndian.Uint32(b))
+			b = b[4:]
+			if size < 0 || pad(size) > len(b) {
+				return m, ErrMalformed
+			}
+			arg = b[:size]
+			b = b[pad(size):]
+		case 'T', 'I':
+			arg = true
+		case 'F':
+			arg = false
+		case 'N':
+		default:
+			return m, fmt.Errorf("osc: unsupported argument type %q in %s", tag, address)
+		}
+		m.Args = append(m.Args, arg)
+	}
+	return m, nil
+}
+
+// readString reads a null terminated string padded to 4 bytes and returns the rest of the buffer
+func readString(b []byte) (string, []byte, error) {
+	for i, c := range b {
+		if c == 0 {
+			n := pad(i + 1)
+			if n > len(b) {
+				return "", nil, ErrMalformed
+			}
+			return string(b[:i]), b[n:], nil
+		}
+	}
+	return "", nil, ErrMalformed
+}
+
+// pad rounds the length up to the 4 byte boundary of the OSC fields
+func pad(n int) int {
+	return (n + 3) &^ 3
+}
+
+// AppendMessage appends the encoded message to buf
+func AppendMessage(buf []byte, m Message) ([]byte, error) {
+	if len(m.Address) == 0 || m.Address[0] != '/' {
+		return buf, fmt.Errorf("osc: address %q doesn't start with a slash", m.Address)
+	}
+	tags := []byte{','}
+	var args []byte
+	for _, arg := range m.Args {
+		switch v := arg.(type) {
+		case int32:
+			tags = append(tags, 'i')
+			args = appendUint32(args, uint32(v))
+		case float32:
+			tags = append(tags, 'f')
+			args = appendUint32(args, math.Float32bits(v))
+		case string:
+			tags = append(tags, 's')
+			args = appendString(args, v)
+		case []byte:
+			tags = append(tags, 'b')
+			args = appendUint32(args, uint32(len(v)))
+			args = append(args, v...)
+			for i := len(v); i < pad(len(v)); i++ {
+				args = append(args, 0)
+			}
+		case int64:
+			tags = append(tags, 'h')
+			args = appendUint32(args, uint32(v>>32))
+			args = appendUint32(args, uint32(v))
+		case float64:
+			tags = append(tags, 'd')
+			bits := math.Float64bits(v)
+			args = appendUint32(args, uint32(bits>>32))
+			args = appendUint32(args, uint32(bits))
+		case bool:
+			if v {
+				tags = append(tags, 'T')
+			} else {
+				tags = append(tags, 'F')
+			}
+		case nil:
+			tags = append(tags, 'N')
+		case color.RGBA:
+			tags = append(tags, 'r')
+			args = append(args, v.R, v.G, v.B, v.A)
+		default:
+			return buf, fmt.Errorf("osc: unsupported argument type %T in %s", arg, m.Address)
+		}
+	}
+	buf = appendString(buf, m.Address)
+	buf = appendString(buf, string(tags))
+	return append(buf, args...), nil
+}
+
+// AppendBundle appends a bundle of the messages to buf with the time tag of the immediate execution
+func AppendBundle(buf []byte, msgs ...Message) ([]byte, error) {
+	buf = append(buf, bundleID...)
+	buf = append(buf, 0, 0, 0, 0, 0, 0, 0, 1)
+	for _, m := range msgs {
+		element, err := AppendMessage(nil, m)
+		if err != nil {
+			return buf, err
+		}
+		buf = appendUint32(buf, uint32(len(element)))
+		buf = append(buf, element...)
+	}
+	return buf, nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = append(buf, s...)
+	for i := len(s); i < pad(len(s)+1); i++ {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
@@ -0,0 +1,101 @@
+package osc
+
+import (
+	"image/color"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMessage(t *testing.T) {
+	want := Message{
+		Address: "/fftwave/color",
+		Args: []interface{}{int32(-5), float32(0.5), "red", []byte{1, 2, 3}, int64(1) << 40, 0.25,
+			true, false, nil, color.RGBA{1, 2, 3, 4}},
+	}
+	packet, err := AppendMessage(nil, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packet)%4 != 0 {
+		t.Errorf("packet length mismatch. Want: %v, Have: %v\n", "multiple of 4", len(packet))
+	}
+
+	msgs, err := ParsePacket(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || !reflect.DeepEqual(msgs[0], want) {
+		t.Errorf("message mismatch. Want: %v, Have: %v\n", want, msgs)
+	}
+
+	// A message cut in the middle of its arguments is rejected
+	if _, err := ParsePacket(packet[:len(packet)-4]); err == nil {
+		t.Errorf("truncated message error mismatch. Want: %v, Have: %v\n", ErrMalformed, err)
+	}
+	if _, err := ParsePacket([]byte("fftw")); err != ErrMalformed {
+		t.Errorf("no address error mismatch. Want: %v, Have: %v\n", ErrMalformed, err)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	a := Message{Address: "/fftwave/wave", Args: []interface{}{int32(2)}}
+	b := Message{Address: "/fftwave/brightness", Args: []interface{}{float32(0.25)}}
+	packet, err := AppendBundle(nil, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A nested bundle is flattened in its place
+	nested, err := AppendBundle(nil, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet = appendUint32(packet, uint32(len(nested)))
+	packet = append(packet, nested...)
+
+	msgs, err := ParsePacket(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(msgs, []Message{a, b}) {
+		t.Errorf("bundle mismatch. Want: %v, Have: %v\n", []Message{a, b}, msgs)
+	}
+}
+
+func TestServer(t *testing.T) {
+	s, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan Message, 2)
+	var wg sync.WaitGroup
+	quit := make(chan struct{})
+	wg.Add(1)
+	go s.Run(func(m Message) { received <- m }, &wg, quit)
+
+	conn, err := net.Dial("udp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// A malformed packet is skipped without stopping the server
+	conn.Write([]byte{1, 2, 3})
+	want := Message{Address: "/fftwave/palette", Args: []interface{}{"rainbow"}}
+	packet, _ := AppendMessage(nil, want)
+	conn.Write(packet)
+
+	select {
+	case m := <-received:
+		if !reflect.DeepEqual(m, want) {
+			t.Errorf("message mismatch. Want: %v, Have: %v\n", want, m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+	close(quit)
+	wg.Wait()
+}
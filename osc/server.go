@@ -0,0 +1,72 @@
+package osc
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultPort is the port the server listens on when the address has none
+const DefaultPort = 9000
+
+// readTimeout is how often the server checks the quit channel while no packets are coming
+const readTimeout = 250 * time.Millisecond
+
+// Server receives the OSC packets over UDP
+type Server struct {
+	conn net.PacketConn
+}
+
+// Listen opens the UDP socket of the server on the address
+func Listen(address string) (*Server, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, fmt.Sprint(DefaultPort))
+	}
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{conn: conn}, nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Run passes every received message to the handler until the quit signal, the malformed packets are logged and skipped
+func (s *Server) Run(handle func(Message), wg *sync.WaitGroup, quit <-chan struct{}) {
+	defer wg.Done()
+	defer s.conn.Close()
+
+	buf := make([]byte, 65536)
+	for {
+		select {
+		case <-quit:
+			log.Println("Stopping OSC thread")
+			return
+		default:
+		}
+
+		s.conn.SetReadDeadline(time.Now().Add(readTimeout))
+		n, _, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			var ne net.Error
+			if !errors.As(err, &ne) || !ne.Timeout() {
+				log.Println(err)
+			}
+			continue
+		}
+		msgs, err := ParsePacket(buf[:n])
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		for _, m := range msgs {
+			handle(m)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/TFK1410/go-rpi-fftwave/dmx"
+	"github.com/TFK1410/go-rpi-fftwave/osc"
+	"github.com/TFK1410/go-rpi-fftwave/palette"
+)
+
+// oscPrefix starts the addresses of all of the OSC controls
+const oscPrefix = "/fftwave/"
+
+// oscInput maps the OSC messages onto the controls
+type oscInput struct {
+	*controls
+}
+
+// handle applies the message, the invalid ones are logged and skipped
+func (o *oscInput) handle(m osc.Message) {
+	if err := o.apply(m); err != nil {
+		log.Println("osc:", err)
+	}
+}
+
+func (o *oscInput) apply(m osc.Message) error {
+	if !strings.HasPrefix(m.Address, oscPrefix) {
+		return fmt.Errorf("unknown address %s", m.Address)
+	}
+	if len(m.Args) == 0 {
+		return fmt.Errorf("%s needs a value", m.Address)
+	}
+
+	switch strings.TrimPrefix(m.Address, oscPrefix) {
+	case "wave":
+		i, err := oscIndex(m.Args[0], o.waves)
+		if err != nil {
+			return fmt.Errorf("%s: %v", m.Address, err)
+		}
+		o.state.Update(func(data *dmx.DMXData) { data.DisplayMode = byte(i) })
+	case "background":
+		i, err := oscIndex(m.Args[0], o.backgrounds)
+		if err != nil {
+			return fmt.Errorf("%s: %v", m.Address, err)
+		}
+		o.state.Update(func(data *dmx.DMXData) { data.BackgroundMode = byte(i) })
+	case "palette":
+		i, err := oscIndex(m.Args[0], palette.Names)
+		if err != nil {
+			return fmt.Errorf("%s: %v", m.Address, err)
+		}
+		o.state.Update(func(data *dmx.DMXData) { data.ColorPalette = byte(i) })
+	case "whitedots":
+		v, _, ok := oscNumber(m.Args[0])
+		if !ok {
+			return fmt.Errorf("%s takes a number or a boolean", m.Address)
+		}
+		o.state.Update(func(data *dmx.DMXData) { data.WhiteDots = v != 0 })
+	case "color":
+		c, err := oscColor(m.Args)
+		if err != nil {
+			return fmt.Errorf("%s: %v", m.Address, err)
+		}
+		o.state.Update(func(data *dmx.DMXData) { data.Color = c })
+	case "brightness":
+		// The floats are in the range 0..1 and the integers in percent
+		v, isFloat, ok := oscNumber(m.Args[0])
+		if !ok {
+			return fmt.Errorf("%s takes a number", m.Address)
+		}
+		if isFloat {
+			v *= 100
+		}
+		o.setBrightness(v)
+	case "lyric":
+		// The lyric ID comes with an optional progress the same way as the DMX channels pass them
+		id, _, ok := oscNumber(m.Args[0])
+		progress := 0.0
+		if ok && len(m.Args) > 1 {
+			progress, _, ok = oscNumber(m.Args[1])
+		}
+		if !ok || id < 0 || id > 0xffffff || progress < 0 || progress > 255 {
+			return fmt.Errorf("%s takes an ID up to 16777215 and a progress up to 255", m.Address)
+		}
+		o.state.Update(func(data *dmx.DMXData) { data.LyricsDMXInfo = uint(id)<<8 + uint(progress) })
+	default:
+		return fmt.Errorf("unknown address %s", m.Address)
+	}
+	return nil
+}
+
+// oscNumber returns the numeric value of the argument and whether it was sent as a float
+func oscNumber(arg interface{}) (float64, bool, bool) {
+	switch v := arg.(type) {
+	case int32:
+		return float64(v), false, true
+	case int64:
+		return float64(v), false, true
+	case float32:
+		return float64(v), true, true
+	case float64:
+		return v, true, true
+	case bool:
+		if v {
+			return 1, false, true
+		}
+		return 0, false, true
+	}
+	return 0, false, false
+}
+
+// oscIndex returns the index of a mode given by its name or its index
+func oscIndex(arg interface{}, names []string) (int, error) {
+	if name, ok := arg.(string); ok {
+		for i, n := range names {
+			if n == name {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("unknown name %q", name)
+	}
+	v, _, ok := oscNumber(arg)
+	if !ok {
+		return 0, fmt.Errorf("takes a name or an index")
+	}
+	i := int(math.Round(v))
+	if i < 0 || i >= len(names) || i > math.MaxUint8 {
+		return 0, fmt.Errorf("index %d is out of the range 0..%d", i, len(names)-1)
+	}
+	return i, nil
+}
+
+// oscColor takes the color from an OSC color argument or from the red, green and blue values
+// the floats are in the range 0..1 and the integers in 0..255
+func oscColor(args []interface{}) (color.RGBA, error) {
+	var c color.RGBA
+	if v, ok := args[0].(color.RGBA); ok {
+		c = v
+	} else {
+		if len(args) < 3 {
+			return c, fmt.Errorf("takes a color or the red, green and blue values")
+		}
+		var rgb [3]uint8
+		for i := range rgb {
+			v, isFloat, ok := oscNumber(args[i])
+			if !ok {
+				return c, fmt.Errorf("takes a color or the red, green and blue values")
+			}
+			if isFloat {
+				v *= 255
+			}
+			rgb[i] = uint8(math.Round(math.Max(0, math.Min(v, 255))))
+		}
+		c.R, c.G, c.B = rgb[0], rgb[1], rgb[2]
+	}
+
+	// Same as with the DMX channels the color is only used when it isn't black
+	if c.R > 0 || c.G > 0 || c.B > 0 {
+		c.A = 255
+	} else {
+		c.A = 0
+	}
+	return c, nil
+}
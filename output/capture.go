@@ -0,0 +1,220 @@
+package output
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"sync"
+	"time"
+)
+
+// CaptureConfig holds the settings of the frame capture
+type CaptureConfig struct {
+	// Format is one of gif, png or pipe
+	Format string
+	// Path is the directory the gif files and the png sequence directories are created in
+	Path string
+	// Command is the shell command receiving the raw RGB24 frames on its standard input in the pipe format
+	// {width}, {height}, {fps} and {time} are replaced with the values of the capture
+	Command string
+	// Duration is the length of a single capture
+	Duration time.Duration
+	// Scale is the integer scale up factor of the captured frames
+	Scale int
+	// FrameRate is the frame rate of the capture, at most the rate of the frames passed to Send
+	FrameRate int
+	// Wait makes Send wait for the writer instead of dropping the frames when it can't keep up
+	// which is only useful when the frames are rendered offline
+	Wait bool
+}
+
+// Capture is a sink that records the frames to an animated gif, a png sequence or an external encoder
+// it only records after Start is called and stops on its own after the configured duration
+type Capture struct {
+	cfg        CaptureConfig
+	sourceRate int
+
+	mu        sync.Mutex
+	frames    chan *image.RGBA
+	done      chan error
+	remaining int
+	acc       float64
+	dropped   int
+}
+
+// NewCapture creates a capture of the frames coming in with the given rate
+func NewCapture(cfg CaptureConfig, sourceRate int) (*Capture, error) {
+	switch cfg.Format {
+	case "gif", "png":
+	case "pipe":
+		if cfg.Command == "" {
+			return nil, fmt.Errorf("capture: the pipe format needs a command")
+		}
+	default:
+		return nil, fmt.Errorf("capture: unknown format %q", cfg.Format)
+	}
+	if cfg.Scale < 1 {
+		cfg.Scale = 1
+	}
+	if cfg.FrameRate <= 0 || cfg.FrameRate > sourceRate {
+		cfg.FrameRate = sourceRate
+	}
+	if cfg.Duration <= 0 {
+		return nil, fmt.Errorf("capture: the duration has to be positive")
+	}
+	return &Capture{cfg: cfg, sourceRate: sourceRate}, nil
+}
+
+// Start begins a new capture, it fails if the previous one is still being recorded or written
+func (c *Capture) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.done != nil {
+		select {
+		case err := <-c.done:
+			if err != nil {
+				log.Println("Previous capture failed:", err)
+			}
+		default:
+			return fmt.Errorf("capture: the previous capture is still running")
+		}
+	}
+
+	c.frames = make(chan *image.RGBA, c.cfg.FrameRate)
+	c.done = make(chan error, 1)
+	c.remaining = int(c.cfg.Duration.Seconds() * float64(c.cfg.FrameRate))
+	c.acc = 1
+	c.dropped = 0
+	go c.run(c.frames, c.done, time.Now())
+	log.Println("Capture started")
+	return nil
+}
+
+// Stop ends the current capture early, the captured frames are still written
+func (c *Capture) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stop()
+}
+
+func (c *Capture) stop() {
+	if c.frames != nil {
+		close(c.frames)
+		c.frames = nil
+		if c.dropped > 0 {
+			log.Printf("Capture dropped %d frames, the writer couldn't keep up\n", c.dropped)
+		}
+	}
+}
+
+// Recording checks if a capture is currently taking the frames
+func (c *Capture) Recording() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.frames != nil
+}
+
+// Send takes the frame when a capture is running and it falls on the capture frame rate
+func (c *Capture) Send(frame *image.RGBA) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.frames == nil {
+		return nil
+	}
+
+	// Only every n-th frame is kept when the capture frame rate is lower, counted in frames so that it also works offline
+	c.acc += float64(c.cfg.FrameRate) / float64(c.sourceRate)
+	if c.acc < 1 {
+		return nil
+	}
+	c.acc--
+
+	scaled := scaleFrame(frame, c.cfg.Scale)
+	if c.cfg.Wait {
+		c.frames <- scaled
+	} else {
+		select {
+		case c.frames <- scaled:
+		default:
+			c.dropped++
+		}
+	}
+
+	c.remaining--
+	if c.remaining <= 0 {
+		c.stop()
+	}
+	return nil
+}
+
+// Close stops the current capture and waits for it to be written
+func (c *Capture) Close() error {
+	c.mu.Lock()
+	c.stop()
+	done := c.done
+	c.done = nil
+	c.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+	return <-done
+}
+
+// run writes the frames until the channel is closed, the writer is created with the first frame since its size isn't known before
+func (c *Capture) run(frames <-chan *image.RGBA, done chan<- error, start time.Time) {
+	var w frameWriter
+	var err error
+	for frame := range frames {
+		if err != nil {
+			// Keep taking the frames so that Send isn't blocked
+			continue
+		}
+		if w == nil {
+			w, err = newFrameWriter(c.cfg, frame.Bounds(), start)
+			if err != nil {
+				continue
+			}
+		}
+		err = w.WriteFrame(frame)
+	}
+
+	if w != nil {
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		log.Println("Capture failed:", err)
+	} else if w != nil {
+		log.Println("Capture saved to", w.Name())
+	}
+	done <- err
+}
+
+// scaleFrame returns an opaque copy of the frame scaled up with the nearest neighbor method
+// the alpha channel is dropped the same way the matrix does it
+func scaleFrame(frame *image.RGBA, scale int) *image.RGBA {
+	return scaleFrameInto(nil, frame, scale)
+}
+
+// scaleFrameInto is scaleFrame reusing the out image when it has the right size
+func scaleFrameInto(out *image.RGBA, frame *image.RGBA, scale int) *image.RGBA {
+	b := frame.Bounds()
+	if r := image.Rect(0, 0, b.Dx()*scale, b.Dy()*scale); out == nil || out.Bounds() != r {
+		out = image.NewRGBA(r)
+	}
+	for y := 0; y < out.Bounds().Dy(); y++ {
+		src := frame.Pix[frame.PixOffset(b.Min.X, b.Min.Y+y/scale):]
+		dst := out.Pix[out.PixOffset(0, y):]
+		for x := 0; x < out.Bounds().Dx(); x++ {
+			s := 4 * (x / scale)
+			dst[4*x] = src[s]
+			dst[4*x+1] = src[s+1]
+			dst[4*x+2] = src[s+2]
+			dst[4*x+3] = 255
+		}
+	}
+	return out
+}
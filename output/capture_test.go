@@ -0,0 +1,68 @@
+package output
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCapturePNG(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewCapture(CaptureConfig{Format: "png", Path: dir, Duration: time.Second, Scale: 3, FrameRate: 30, Wait: true}, 120)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	frame.SetRGBA(1, 1, color.RGBA{10, 20, 30, 255})
+
+	// Frames sent before the start aren't captured
+	c.Send(frame)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(); err == nil {
+		t.Errorf("second start mismatch. Want: error, Have: %v\n", err)
+	}
+	// Two seconds of frames at the source rate, the capture stops on its own after one
+	for i := 0; i < 240; i++ {
+		c.Send(frame)
+	}
+	if c.Recording() {
+		t.Errorf("recording mismatch. Want: %v, Have: %v\n", false, true)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*", "frame-*.png"))
+	if len(files) != 30 {
+		t.Fatalf("frame count mismatch. Want: %v, Have: %v\n", 30, len(files))
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := image.Rect(0, 0, 12, 6); img.Bounds() != want {
+		t.Errorf("size mismatch. Want: %v, Have: %v\n", want, img.Bounds())
+	}
+	for _, p := range []image.Point{{3, 3}, {5, 5}} {
+		if have := color.RGBAModel.Convert(img.At(p.X, p.Y)); have != (color.RGBA{10, 20, 30, 255}) {
+			t.Errorf("pixel %v mismatch. Want: %v, Have: %v\n", p, color.RGBA{10, 20, 30, 255}, have)
+		}
+	}
+	// Empty pixels are captured as opaque black
+	if have := color.RGBAModel.Convert(img.At(0, 0)); have != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("empty pixel mismatch. Want: %v, Have: %v\n", color.RGBA{0, 0, 0, 255}, have)
+	}
+}
@@ -0,0 +1,164 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// frameWriter stores the captured frames in one of the capture formats
+type frameWriter interface {
+	WriteFrame(frame *image.RGBA) error
+	Close() error
+	// Name returns the path or the command the frames were written to
+	Name() string
+}
+
+func newFrameWriter(cfg CaptureConfig, bounds image.Rectangle, start time.Time) (frameWriter, error) {
+	name := "capture-" + start.Format("20060102-150405")
+	switch cfg.Format {
+	case "gif":
+		if err := os.MkdirAll(cfg.Path, 0755); err != nil {
+			return nil, err
+		}
+		return &gifWriter{path: filepath.Join(cfg.Path, name+".gif"), delay: (100 + cfg.FrameRate/2) / cfg.FrameRate}, nil
+	case "png":
+		dir := filepath.Join(cfg.Path, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		return &pngWriter{dir: dir}, nil
+	case "pipe":
+		command := strings.NewReplacer(
+			"{width}", strconv.Itoa(bounds.Dx()),
+			"{height}", strconv.Itoa(bounds.Dy()),
+			"{fps}", strconv.Itoa(cfg.FrameRate),
+			"{time}", start.Format("20060102-150405"),
+		).Replace(cfg.Command)
+		return newPipeWriter(command)
+	}
+	return nil, fmt.Errorf("capture: unknown format %q", cfg.Format)
+}
+
+// gifWriter keeps all of the frames in memory since the gif is encoded as a whole
+type gifWriter struct {
+	path  string
+	delay int
+	anim  gif.GIF
+}
+
+func (w *gifWriter) WriteFrame(frame *image.RGBA) error {
+	// No dithering so that the pixels keep their flat colors
+	p := image.NewPaletted(frame.Bounds(), palette.Plan9)
+	draw.Draw(p, p.Bounds(), frame, frame.Bounds().Min, draw.Src)
+	w.anim.Image = append(w.anim.Image, p)
+	w.anim.Delay = append(w.anim.Delay, w.delay)
+	return nil
+}
+
+func (w *gifWriter) Close() error {
+	if len(w.anim.Image) == 0 {
+		return nil
+	}
+	f, err := os.Create(w.path)
+	if err != nil {
+		return err
+	}
+	if err := gif.EncodeAll(f, &w.anim); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (w *gifWriter) Name() string {
+	return w.path
+}
+
+// pngWriter saves every frame to a numbered file in its own directory
+type pngWriter struct {
+	dir   string
+	count int
+}
+
+func (w *pngWriter) WriteFrame(frame *image.RGBA) error {
+	f, err := os.Create(filepath.Join(w.dir, fmt.Sprintf("frame-%05d.png", w.count)))
+	if err != nil {
+		return err
+	}
+	w.count++
+	if err := png.Encode(f, frame); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (w *pngWriter) Close() error {
+	return nil
+}
+
+func (w *pngWriter) Name() string {
+	return w.dir
+}
+
+// pipeWriter passes the frames as raw RGB24 data to the standard input of an external encoder
+type pipeWriter struct {
+	command string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	buf     *bufio.Writer
+	rgb     []byte
+}
+
+func newPipeWriter(command string) (*pipeWriter, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("capture: starting %q: %v", command, err)
+	}
+	return &pipeWriter{command: command, cmd: cmd, stdin: stdin, buf: bufio.NewWriter(stdin)}, nil
+}
+
+func (w *pipeWriter) WriteFrame(frame *image.RGBA) error {
+	n := frame.Bounds().Dx() * frame.Bounds().Dy()
+	if len(w.rgb) != 3*n {
+		w.rgb = make([]byte, 3*n)
+	}
+	for i := 0; i < n; i++ {
+		copy(w.rgb[3*i:3*i+3], frame.Pix[4*i:4*i+3])
+	}
+	_, err := w.buf.Write(w.rgb)
+	return err
+}
+
+func (w *pipeWriter) Close() error {
+	err := w.buf.Flush()
+	if cerr := w.stdin.Close(); err == nil {
+		err = cerr
+	}
+	if werr := w.cmd.Wait(); err == nil && werr != nil {
+		err = fmt.Errorf("capture: %q: %v", w.command, werr)
+	}
+	return err
+}
+
+func (w *pipeWriter) Name() string {
+	return w.command
+}
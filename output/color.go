@@ -0,0 +1,151 @@
+package output
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sync"
+)
+
+// Calibration describes the color correction of the displays
+// the gamma curve is applied first, then the panel matrices and the white balance gains in the linear light
+type Calibration struct {
+	Gamma float64
+	// Gains of the red, green and blue channels
+	Gains  [3]float64
+	Panels []PanelCalibration
+}
+
+// PanelCalibration corrects the colors of one panel of the display
+type PanelCalibration struct {
+	// Rect is the part of the canvas shown by the panel
+	Rect image.Rectangle
+	// Matrix mixes the linear red, green and blue values of the panel pixels, each row gives one output channel
+	Matrix [3][3]float64
+}
+
+// IdentityCalibration doesn't change the colors
+var IdentityCalibration = Calibration{Gamma: 1, Gains: [3]float64{1, 1, 1}}
+
+// colorTable holds the precomputed curves of a calibration
+type colorTable struct {
+	// linear converts the 8 bit values into the linear light
+	linear [256]float64
+	// out converts the linear light of each channel back into 8 bit values with the gains applied
+	// it has 4096 steps so that the matrices don't lose the dark shades
+	out    [3][4096]byte
+	lut    [3][256]byte
+	panels []PanelCalibration
+}
+
+func newColorTable(c Calibration) (*colorTable, error) {
+	if c.Gamma <= 0 {
+		return nil, fmt.Errorf("calibration: gamma %v has to be positive", c.Gamma)
+	}
+	for _, g := range c.Gains {
+		if g < 0 {
+			return nil, fmt.Errorf("calibration: gains %v can't be negative", c.Gains)
+		}
+	}
+	t := &colorTable{panels: c.Panels}
+	for v := range t.linear {
+		t.linear[v] = math.Pow(float64(v)/255, c.Gamma)
+	}
+	for ch := 0; ch < 3; ch++ {
+		for i := range t.out[ch] {
+			t.out[ch][i] = clampByte(255 * c.Gains[ch] * float64(i) / float64(len(t.out[ch])-1))
+		}
+		for v := range t.lut[ch] {
+			t.lut[ch][v] = clampByte(255 * c.Gains[ch] * t.linear[v])
+		}
+	}
+	return t, nil
+}
+
+func clampByte(v float64) byte {
+	if v >= 255 {
+		return 255
+	}
+	if v <= 0 {
+		return 0
+	}
+	return byte(v + 0.5)
+}
+
+// apply writes the corrected frame into out
+func (t *colorTable) apply(out, frame *image.RGBA) {
+	copy(out.Pix, frame.Pix)
+	for i := 0; i < len(out.Pix); i += 4 {
+		out.Pix[i] = t.lut[0][out.Pix[i]]
+		out.Pix[i+1] = t.lut[1][out.Pix[i+1]]
+		out.Pix[i+2] = t.lut[2][out.Pix[i+2]]
+	}
+
+	// The panels go over the pixels again starting from the original colors
+	for _, p := range t.panels {
+		r := p.Rect.Intersect(frame.Bounds())
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				i := frame.PixOffset(x, y)
+				in := [3]float64{t.linear[frame.Pix[i]], t.linear[frame.Pix[i+1]], t.linear[frame.Pix[i+2]]}
+				for ch := 0; ch < 3; ch++ {
+					m := p.Matrix[ch]
+					v := m[0]*in[0] + m[1]*in[1] + m[2]*in[2]
+					steps := len(t.out[ch]) - 1
+					switch {
+					case v <= 0:
+						out.Pix[i+ch] = t.out[ch][0]
+					case v >= 1:
+						out.Pix[i+ch] = t.out[ch][steps]
+					default:
+						out.Pix[i+ch] = t.out[ch][int(v*float64(steps)+0.5)]
+					}
+				}
+			}
+		}
+	}
+}
+
+// Corrected applies the color calibration to the frames before passing them on to the sink
+type Corrected struct {
+	s   Sink
+	mu  sync.Mutex
+	t   *colorTable
+	out *image.RGBA
+}
+
+func NewCorrected(s Sink, c Calibration) (*Corrected, error) {
+	cs := &Corrected{s: s}
+	if err := cs.SetCalibration(c); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// SetCalibration replaces the calibration, the next frame is sent with the new one
+func (cs *Corrected) SetCalibration(c Calibration) error {
+	t, err := newColorTable(c)
+	if err != nil {
+		return err
+	}
+	cs.mu.Lock()
+	cs.t = t
+	cs.mu.Unlock()
+	return nil
+}
+
+func (cs *Corrected) Send(frame *image.RGBA) error {
+	cs.mu.Lock()
+	t := cs.t
+	cs.mu.Unlock()
+
+	if cs.out == nil || cs.out.Bounds() != frame.Bounds() {
+		cs.out = image.NewRGBA(frame.Bounds())
+	}
+	t.apply(cs.out, frame)
+	return cs.s.Send(cs.out)
+}
+
+func (cs *Corrected) Close() error {
+	return cs.s.Close()
+}
@@ -0,0 +1,65 @@
+package output
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// recorder keeps the last frame it was sent
+type recorder struct {
+	frame *image.RGBA
+}
+
+func (r *recorder) Send(frame *image.RGBA) error {
+	r.frame = frame
+	return nil
+}
+
+func (r *recorder) Close() error {
+	return nil
+}
+
+func TestCorrected(t *testing.T) {
+	frame := image.NewRGBA(image.Rect(0, 0, 4, 1))
+	for x := 0; x < 4; x++ {
+		frame.SetRGBA(x, 0, color.RGBA{255, 128, 0, 255})
+	}
+
+	rec := &recorder{}
+	cs, err := NewCorrected(rec, IdentityCalibration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs.Send(frame)
+	if have := rec.frame.RGBAAt(0, 0); have != (color.RGBA{255, 128, 0, 255}) {
+		t.Errorf("identity mismatch. Want: %v, Have: %v\n", color.RGBA{255, 128, 0, 255}, have)
+	}
+
+	// The panel on the right half swaps the red and green channels
+	err = cs.SetCalibration(Calibration{
+		Gamma: 2,
+		Gains: [3]float64{0.5, 1, 1},
+		Panels: []PanelCalibration{
+			{Rect: image.Rect(2, 0, 4, 1), Matrix: [3][3]float64{{0, 1, 0}, {1, 0, 0}, {0, 0, 1}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs.Send(frame)
+	// 128 is 0.252 in the linear light with the gamma of 2
+	for x, want := range []color.RGBA{{128, 64, 0, 255}, {128, 64, 0, 255}, {32, 255, 0, 255}, {32, 255, 0, 255}} {
+		if have := rec.frame.RGBAAt(x, 0); have != want {
+			t.Errorf("pixel %d mismatch. Want: %v, Have: %v\n", x, want, have)
+		}
+	}
+	// The source frame is left as it was
+	if have := frame.RGBAAt(0, 0); have != (color.RGBA{255, 128, 0, 255}) {
+		t.Errorf("source frame mismatch. Want: %v, Have: %v\n", color.RGBA{255, 128, 0, 255}, have)
+	}
+
+	if err := cs.SetCalibration(Calibration{Gamma: 0}); err == nil {
+		t.Errorf("zero gamma mismatch. Want: error, Have: %v\n", err)
+	}
+}
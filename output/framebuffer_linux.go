@@ -0,0 +1,169 @@
+package output
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Definition of the framebuffer ioctl requests from linux/fb.h
+const (
+	fbioGetVScreenInfo = 0x4600
+	fbioGetFScreenInfo = 0x4602
+)
+
+type fbBitfield struct {
+	Offset, Length, MsbRight uint32
+}
+
+// fbVarScreenInfo is the fb_var_screeninfo struct
+type fbVarScreenInfo struct {
+	XRes, YRes, XResVirtual, YResVirtual, XOffset, YOffset uint32
+	BitsPerPixel, Grayscale                                uint32
+	Red, Green, Blue, Transp                               fbBitfield
+	NonStd, Activate, Height, Width, AccelFlags, PixClock  uint32
+	Margins                                                [4]uint32
+	HSyncLen, VSyncLen, Sync, VMode, Rotate, Colorspace    uint32
+	Reserved                                               [4]uint32
+}
+
+// fbFixScreenInfo is the fb_fix_screeninfo struct, the unsigned longs have the size of a pointer
+type fbFixScreenInfo struct {
+	ID                             [16]byte
+	SmemStart                      uintptr
+	SmemLen, Type, TypeAux, Visual uint32
+	XPanStep, YPanStep, YWrapStep  uint16
+	LineLength                     uint32
+	MmioStart                      uintptr
+	MmioLen, Accel                 uint32
+	Capabilities                   uint16
+	Reserved                       [2]uint16
+}
+
+// FramebufferSink draws the frames scaled up in the middle of a Linux framebuffer device
+type FramebufferSink struct {
+	f *os.File
+	// mapping is the whole mapped memory and mem starts at the visible part of it
+	mapping []byte
+	mem     []byte
+	stride  int
+	// bytes per pixel
+	bpp              int
+	red, green, blue fbBitfield
+	scale            int
+	offset           image.Point
+	scaled           *image.RGBA
+}
+
+// NewFramebuffer opens the framebuffer device for frames with the given bounds
+// a scale of zero picks the largest integer scale which fits on the screen
+func NewFramebuffer(device string, scale int, bounds image.Rectangle) (*FramebufferSink, error) {
+	f, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	var vinfo fbVarScreenInfo
+	var finfo fbFixScreenInfo
+	if err := ioctl(f, fbioGetVScreenInfo, unsafe.Pointer(&vinfo)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("framebuffer %s: %v", device, err)
+	}
+	if err := ioctl(f, fbioGetFScreenInfo, unsafe.Pointer(&finfo)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("framebuffer %s: %v", device, err)
+	}
+
+	s, err := newFramebufferSink(vinfo, finfo, scale, bounds)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("framebuffer %s: %v", device, err)
+	}
+	s.f = f
+	mem, err := syscall.Mmap(int(f.Fd()), 0, int(finfo.SmemLen), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("framebuffer %s: %v", device, err)
+	}
+	// Start drawing from the visible part of the virtual screen
+	s.mapping = mem
+	s.mem = mem[int(vinfo.YOffset)*s.stride+int(vinfo.XOffset)*s.bpp:]
+	s.clear()
+	return s, nil
+}
+
+// newFramebufferSink checks the screen format and computes the placement of the frames without touching the device
+func newFramebufferSink(vinfo fbVarScreenInfo, finfo fbFixScreenInfo, scale int, bounds image.Rectangle) (*FramebufferSink, error) {
+	switch vinfo.BitsPerPixel {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("%d bits per pixel aren't supported", vinfo.BitsPerPixel)
+	}
+	screenW, screenH := int(vinfo.XRes), int(vinfo.YRes)
+	if scale == 0 {
+		scale = screenW / bounds.Dx()
+		if s := screenH / bounds.Dy(); s < scale {
+			scale = s
+		}
+	}
+	w, h := bounds.Dx()*scale, bounds.Dy()*scale
+	if scale < 1 || w > screenW || h > screenH {
+		return nil, fmt.Errorf("%dx%d frames don't fit on the %dx%d screen", bounds.Dx(), bounds.Dy(), screenW, screenH)
+	}
+	return &FramebufferSink{
+		stride: int(finfo.LineLength),
+		bpp:    int(vinfo.BitsPerPixel) / 8,
+		red:    vinfo.Red,
+		green:  vinfo.Green,
+		blue:   vinfo.Blue,
+		scale:  scale,
+		offset: image.Point{(screenW - w) / 2, (screenH - h) / 2},
+	}, nil
+}
+
+// Send converts the scaled frame into the pixel format of the screen
+func (s *FramebufferSink) Send(frame *image.RGBA) error {
+	s.scaled = scaleFrameInto(s.scaled, frame, s.scale)
+	b := s.scaled.Bounds()
+	for y := 0; y < b.Dy(); y++ {
+		src := s.scaled.Pix[s.scaled.PixOffset(0, y):]
+		dst := s.mem[(s.offset.Y+y)*s.stride+s.offset.X*s.bpp:]
+		for x := 0; x < b.Dx(); x++ {
+			v := channel(src[4*x], s.red) | channel(src[4*x+1], s.green) | channel(src[4*x+2], s.blue)
+			// The pixels are stored in the little endian byte order
+			for i := 0; i < s.bpp; i++ {
+				dst[x*s.bpp+i] = byte(v >> (8 * i))
+			}
+		}
+	}
+	return nil
+}
+
+// channel places the top bits of the color value in its bitfield of the pixel
+func channel(v byte, bf fbBitfield) uint32 {
+	return uint32(v) >> (8 - bf.Length) << bf.Offset
+}
+
+func (s *FramebufferSink) clear() {
+	for i := range s.mem {
+		s.mem[i] = 0
+	}
+}
+
+// Close blanks the screen and releases the device
+func (s *FramebufferSink) Close() error {
+	s.clear()
+	if err := syscall.Munmap(s.mapping); err != nil {
+		s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
+
+func ioctl(f *os.File, request uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package output
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func TestFramebufferFormat(t *testing.T) {
+	// A 16 bit RGB565 screen which fits the 4x2 frame scaled three times in the middle
+	vinfo := fbVarScreenInfo{XRes: 14, YRes: 10, BitsPerPixel: 16,
+		Red: fbBitfield{Offset: 11, Length: 5}, Green: fbBitfield{Offset: 5, Length: 6}, Blue: fbBitfield{Length: 5}}
+	finfo := fbFixScreenInfo{LineLength: 28}
+	s, err := newFramebufferSink(vinfo, finfo, 0, image.Rect(0, 0, 4, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.scale != 3 || s.offset != (image.Point{1, 2}) {
+		t.Errorf("placement mismatch. Want: %v %v, Have: %v %v\n", 3, image.Point{1, 2}, s.scale, s.offset)
+	}
+	s.mem = make([]byte, 28*10)
+
+	frame := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	frame.SetRGBA(1, 1, color.RGBA{255, 128, 8, 255})
+	s.Send(frame)
+	// Pixel 1, 1 of the frame covers the screen pixels 4..6, 5..7
+	want := uint16(31<<11 | 32<<5 | 1)
+	for _, p := range []image.Point{{4, 5}, {6, 7}, {7, 7}, {3, 5}} {
+		i := p.Y*28 + 2*p.X
+		have := uint16(s.mem[i]) | uint16(s.mem[i+1])<<8
+		w := want
+		if p.X > 6 || p.X < 4 {
+			w = 0
+		}
+		if have != w {
+			t.Errorf("pixel %v mismatch. Want: %#04x, Have: %#04x\n", p, w, have)
+		}
+	}
+
+	if _, err := newFramebufferSink(vinfo, finfo, 5, image.Rect(0, 0, 4, 2)); err == nil {
+		t.Errorf("oversized scale mismatch. Want: error, Have: %v\n", err)
+	}
+}
+
+func TestRing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring")
+	s, err := NewRing(path, 3, 2, image.Rect(0, 0, 3, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	r, err := OpenRing(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	dst := image.NewRGBA(r.Bounds())
+	if n, _, err := r.Read(dst); n != 0 || err != nil {
+		t.Errorf("empty ring mismatch. Want: %v, Have: %v %v\n", 0, n, err)
+	}
+
+	frame := image.NewRGBA(image.Rect(0, 0, 3, 1))
+	for i := 1; i <= 5; i++ {
+		frame.SetRGBA(2, 0, color.RGBA{byte(i), 0, 0, 255})
+		s.Send(frame)
+	}
+	n, _, err := r.Read(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Errorf("sequence mismatch. Want: %v, Have: %v\n", 5, n)
+	}
+	if want := image.Rect(0, 0, 6, 2); dst.Bounds() != want {
+		t.Errorf("bounds mismatch. Want: %v, Have: %v\n", want, dst.Bounds())
+	}
+	if have := dst.RGBAAt(5, 1); have != (color.RGBA{5, 0, 0, 255}) {
+		t.Errorf("pixel mismatch. Want: %v, Have: %v\n", color.RGBA{5, 0, 0, 255}, have)
+	}
+}
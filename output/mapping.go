@@ -0,0 +1,86 @@
+package output
+
+import (
+	"fmt"
+	"image"
+)
+
+// Mapping describes which canvas pixels are sent to a pixel target, in the order of the target pixel indexes
+// the pixels are either listed one by one or taken from a rectangle of the canvas which defaults to the whole canvas
+type Mapping struct {
+	X      int `yaml:"x"`
+	Y      int `yaml:"y"`
+	Width  int `yaml:"width"`
+	Height int `yaml:"height"`
+	// Step takes every n-th pixel of the rectangle in both directions
+	Step int `yaml:"step,omitempty"`
+	// Vertical walks the rectangle column by column instead of row by row
+	Vertical bool `yaml:"vertical,omitempty"`
+	// Serpentine reverses every other row (or column) for the zig-zag wired matrices
+	Serpentine bool `yaml:"serpentine,omitempty"`
+	// Pixels lists the canvas pixels one by one instead of the rectangle
+	Pixels []image.Point `yaml:"pixels,omitempty"`
+	// Start is the index of the first target pixel
+	Start int `yaml:"start,omitempty"`
+}
+
+// Points returns the canvas positions of the target pixels starting from the Start index
+func (m Mapping) Points(bounds image.Rectangle) ([]image.Point, error) {
+	if len(m.Pixels) > 0 {
+		for _, p := range m.Pixels {
+			if !p.In(bounds) {
+				return nil, fmt.Errorf("mapping: pixel %v is outside of the canvas", p)
+			}
+		}
+		return m.Pixels, nil
+	}
+
+	r := image.Rect(m.X, m.Y, m.X+m.Width, m.Y+m.Height)
+	if m.Width == 0 && m.Height == 0 {
+		r = bounds
+	}
+	if r.Empty() || !r.In(bounds) {
+		return nil, fmt.Errorf("mapping: rectangle %v doesn't fit on the canvas %v", r, bounds)
+	}
+	step := m.Step
+	if step < 1 {
+		step = 1
+	}
+
+	// outer and inner are the row and the column or the other way around for the vertical walk
+	outerMin, outerMax, innerMin, innerMax := r.Min.Y, r.Max.Y, r.Min.X, r.Max.X
+	if m.Vertical {
+		outerMin, outerMax, innerMin, innerMax = r.Min.X, r.Max.X, r.Min.Y, r.Max.Y
+	}
+	innerCount := (innerMax - innerMin + step - 1) / step
+
+	var out []image.Point
+	for line, o := 0, outerMin; o < outerMax; line, o = line+1, o+step {
+		for n := 0; n < innerCount; n++ {
+			i := innerMin + n*step
+			if m.Serpentine && line%2 == 1 {
+				i = innerMin + (innerCount-1-n)*step
+			}
+			if m.Vertical {
+				out = append(out, image.Point{o, i})
+			} else {
+				out = append(out, image.Point{i, o})
+			}
+		}
+	}
+	return out, nil
+}
+
+// packRGB copies the colors of the mapped pixels into buf as consecutive RGB triplets
+// the order lists the frame channel sent in each position of the triplet, RGB is {0, 1, 2} and GRB is {1, 0, 2}
+func packRGB(buf []byte, frame *image.RGBA, points []image.Point, order [3]int) []byte {
+	buf = buf[:0]
+	for _, p := range points {
+		i := frame.PixOffset(p.X, p.Y)
+		buf = append(buf, frame.Pix[i+order[0]], frame.Pix[i+order[1]], frame.Pix[i+order[2]])
+	}
+	return buf
+}
+
+// rgbOrder is the channel order of the pixels which don't have it configurable
+var rgbOrder = [3]int{0, 1, 2}
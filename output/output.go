@@ -0,0 +1,38 @@
+// Package output holds the destinations of the rendered frames
+package output
+
+import (
+	"image"
+)
+
+// Sink receives every finished frame of the display
+// the frame is reused for the next one so the sink must not keep it after Send returns
+type Sink interface {
+	Send(frame *image.RGBA) error
+	Close() error
+}
+
+// Multi sends every frame to all of the sinks in the order they were given
+type Multi []Sink
+
+// Send passes the frame to every sink, a failing sink doesn't stop the others from getting the frame
+func (m Multi) Send(frame *image.RGBA) error {
+	var firstErr error
+	for _, s := range m {
+		if err := s.Send(frame); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close closes every sink and returns the first error
+func (m Multi) Close() error {
+	var firstErr error
+	for _, s := range m {
+		if err := s.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
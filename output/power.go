@@ -0,0 +1,152 @@
+package output
+
+import (
+	"image"
+	"math"
+	"sync"
+)
+
+// PowerConfig describes the current draw of the panels and the budget of the power supply
+type PowerConfig struct {
+	// Limit is the current budget in milliamps, zero only estimates the load
+	Limit float64
+	// ChannelCurrent is the average current of a red, green and blue pixel channel at full value and full brightness
+	ChannelCurrent [3]float64
+	// Gamma approximates the luminance correction of the panel driver, the current follows the corrected values
+	Gamma float64
+	// Release is the time constant in seconds of the scale going back up, it goes down right away
+	Release float64
+}
+
+// PowerReport holds the load estimates since the previous report
+type PowerReport struct {
+	// Average and Peak are the estimated currents in milliamps of the frames sent to the sink
+	Average, Peak float64
+	// PeakUnlimited is the highest estimated current before the frames were scaled down
+	PeakUnlimited float64
+	// MinScale is the lowest scale applied to the current of the frames
+	MinScale float64
+	Frames   int
+}
+
+// PowerLimiter estimates the current draw of every frame and scales the frames down when they go over the limit
+type PowerLimiter struct {
+	s          Sink
+	cfg        PowerConfig
+	brightness func() int
+	// linear is the share of the full channel current for every 8 bit value
+	linear  [256]float64
+	release float64
+	scale   float64
+	lut     [256]byte
+	out     *image.RGBA
+
+	mu     sync.Mutex
+	report PowerReport
+	sum    float64
+}
+
+// NewPowerLimiter wraps the sink, the brightness function returns the panel brightness in percent
+func NewPowerLimiter(s Sink, cfg PowerConfig, sourceRate int, brightness func() int) *PowerLimiter {
+	if cfg.Gamma <= 0 {
+		cfg.Gamma = 1
+	}
+	p := &PowerLimiter{s: s, cfg: cfg, brightness: brightness, scale: 1, report: PowerReport{MinScale: 1}}
+	for v := range p.linear {
+		p.linear[v] = math.Pow(float64(v)/255, cfg.Gamma)
+	}
+	// The smoothing factor per frame of the exponential move back up towards the target scale
+	p.release = smoothing(cfg.Release, sourceRate)
+	return p
+}
+
+func smoothing(seconds float64, rate int) float64 {
+	if seconds <= 0 || rate <= 0 {
+		return 1
+	}
+	return 1 - math.Exp(-1/(seconds*float64(rate)))
+}
+
+// Estimate returns the current draw of the frame in milliamps at the current brightness
+func (p *PowerLimiter) Estimate(frame *image.RGBA) float64 {
+	var sum [3]float64
+	b := frame.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := frame.Pix[frame.PixOffset(b.Min.X, y):frame.PixOffset(b.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			sum[0] += p.linear[row[i]]
+			sum[1] += p.linear[row[i+1]]
+			sum[2] += p.linear[row[i+2]]
+		}
+	}
+	brightness := 100
+	if p.brightness != nil {
+		brightness = p.brightness()
+	}
+	return float64(brightness) / 100 * (sum[0]*p.cfg.ChannelCurrent[0] + sum[1]*p.cfg.ChannelCurrent[1] + sum[2]*p.cfg.ChannelCurrent[2])
+}
+
+func (p *PowerLimiter) Send(frame *image.RGBA) error {
+	estimate := p.Estimate(frame)
+	target := 1.0
+	if p.cfg.Limit > 0 && estimate > p.cfg.Limit {
+		target = p.cfg.Limit / estimate
+	}
+	// The scale goes down right away so no frame over the limit is sent and comes back slowly
+	// so the beats don't make the display pump
+	if target < p.scale {
+		p.scale = target
+	} else {
+		p.scale += (target - p.scale) * p.release
+	}
+	if p.scale > 0.9999 {
+		p.scale = 1
+	}
+
+	out := frame
+	if p.scale < 1 {
+		// The current follows the corrected values so the values are scaled by the inverse of the gamma
+		k := math.Pow(p.scale, 1/p.cfg.Gamma)
+		for v := range p.lut {
+			p.lut[v] = byte(float64(v)*k + 0.5)
+		}
+		if p.out == nil || p.out.Bounds() != frame.Bounds() {
+			p.out = image.NewRGBA(frame.Bounds())
+		}
+		for i := 0; i < len(frame.Pix); i += 4 {
+			p.out.Pix[i] = p.lut[frame.Pix[i]]
+			p.out.Pix[i+1] = p.lut[frame.Pix[i+1]]
+			p.out.Pix[i+2] = p.lut[frame.Pix[i+2]]
+			p.out.Pix[i+3] = frame.Pix[i+3]
+		}
+		out = p.out
+	}
+
+	p.mu.Lock()
+	current := estimate * p.scale
+	p.sum += current
+	p.report.Frames++
+	p.report.Peak = math.Max(p.report.Peak, current)
+	p.report.PeakUnlimited = math.Max(p.report.PeakUnlimited, estimate)
+	p.report.MinScale = math.Min(p.report.MinScale, p.scale)
+	p.mu.Unlock()
+
+	return p.s.Send(out)
+}
+
+// Report returns the load estimates since the previous report
+func (p *PowerLimiter) Report() PowerReport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r := p.report
+	if r.Frames > 0 {
+		r.Average = p.sum / float64(r.Frames)
+	}
+	p.report = PowerReport{MinScale: 1}
+	p.sum = 0
+	return r
+}
+
+func (p *PowerLimiter) Close() error {
+	return p.s.Close()
+}
@@ -0,0 +1,60 @@
+package output
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func TestPowerLimiter(t *testing.T) {
+	white := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	draw.Draw(white, white.Bounds(), image.NewUniform(color.RGBA{255, 255, 255, 255}), image.Point{}, draw.Src)
+
+	rec := &recorder{}
+	p := NewPowerLimiter(rec, PowerConfig{Limit: 30, ChannelCurrent: [3]float64{10, 10, 10}, Gamma: 1, Release: 1}, 10, func() int { return 50 })
+	// 4 pixels with 3 channels of 10 mA at the half brightness
+	if have := p.Estimate(white); have != 60 {
+		t.Errorf("estimate mismatch. Want: %v, Have: %v\n", 60, have)
+	}
+
+	// The frame over the limit is scaled down right away
+	p.Send(white)
+	if have := rec.frame.RGBAAt(1, 1); have != (color.RGBA{128, 128, 128, 255}) {
+		t.Errorf("limited pixel mismatch. Want: %v, Have: %v\n", color.RGBA{128, 128, 128, 255}, have)
+	}
+	if have := p.Estimate(rec.frame); math.Abs(have-30) > 0.5 {
+		t.Errorf("limited estimate mismatch. Want: %v, Have: %v\n", 30, have)
+	}
+
+	// The scale comes back slowly after the load drops
+	p.Send(image.NewRGBA(white.Bounds()))
+	if want := 0.5 + 0.5*(1-math.Exp(-0.1)); math.Abs(p.scale-want) > 1e-9 {
+		t.Errorf("released scale mismatch. Want: %v, Have: %v\n", want, p.scale)
+	}
+
+	r := p.Report()
+	if r.Frames != 2 || r.PeakUnlimited != 60 || r.Peak != 30 || r.MinScale != 0.5 || r.Average != 15 {
+		t.Errorf("report mismatch. Want: %+v, Have: %+v\n", PowerReport{Average: 15, Peak: 30, PeakUnlimited: 60, MinScale: 0.5, Frames: 2}, r)
+	}
+	if r := p.Report(); r.Frames != 0 {
+		t.Errorf("reset report mismatch. Want: %v, Have: %v\n", 0, r.Frames)
+	}
+}
+
+func TestPowerLimiterNeverOver(t *testing.T) {
+	rec := &recorder{}
+	p := NewPowerLimiter(rec, PowerConfig{Limit: 30, ChannelCurrent: [3]float64{10, 10, 10}, Gamma: 1, Release: 0.5}, 60, nil)
+	// A load rising and dropping with every frame like the beats of a song
+	for i := 0; i < 120; i++ {
+		frame := image.NewRGBA(image.Rect(0, 0, 2, 2))
+		v := uint8(i * 37 % 256)
+		draw.Draw(frame, frame.Bounds(), image.NewUniform(color.RGBA{v, v, 255 - v, 255}), image.Point{}, draw.Src)
+		p.Send(frame)
+		// The rounding of the scaled values is allowed to go over by a fraction of a value
+		if have := p.Estimate(rec.frame); have > p.cfg.Limit+0.5 {
+			t.Fatalf("frame %d over the limit. Want: <= %v, Have: %v\n", i, p.cfg.Limit, have)
+		}
+	}
+}
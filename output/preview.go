@@ -0,0 +1,180 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"sync"
+)
+
+// mjpegBoundary separates the frames of the MJPEG stream
+const mjpegBoundary = "fftwaveframe"
+
+// PreviewConfig describes the frames served by the preview
+type PreviewConfig struct {
+	// Scale is the integer scale up factor of the served frames
+	Scale int
+	// Quality of the JPEG frames from 1 to 100
+	Quality int
+	// Status returns the values served as JSON and shown under the stream, nil leaves the status out
+	Status func() interface{}
+}
+
+// Preview is a sink keeping the latest frame for the HTTP clients, the frames are encoded by the client handlers
+// so the only work done in the render loop is a copy of the frame
+type Preview struct {
+	cfg PreviewConfig
+
+	mu    sync.Mutex
+	frame *image.RGBA
+	// updated is closed and replaced on every new frame to wake up the streams
+	updated chan struct{}
+	closed  bool
+}
+
+func NewPreview(cfg PreviewConfig) *Preview {
+	if cfg.Scale < 1 {
+		cfg.Scale = 1
+	}
+	if cfg.Quality < 1 || cfg.Quality > 100 {
+		cfg.Quality = jpeg.DefaultQuality
+	}
+	return &Preview{cfg: cfg, updated: make(chan struct{})}
+}
+
+func (p *Preview) Send(frame *image.RGBA) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	// A new copy every time since the handlers keep using the previous one without the lock
+	p.frame = image.NewRGBA(frame.Bounds())
+	copy(p.frame.Pix, frame.Pix)
+	close(p.updated)
+	p.updated = make(chan struct{})
+	return nil
+}
+
+// Close ends the running streams
+func (p *Preview) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		close(p.updated)
+	}
+	return nil
+}
+
+// latest returns the latest frame and the channel closed on the next one
+func (p *Preview) latest() (*image.RGBA, chan struct{}, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.frame, p.updated, p.closed
+}
+
+// Handler serves a small page with the stream, the MJPEG stream at /stream.mjpg, the latest frame at /snapshot.png
+// and the status at /status.json
+func (p *Preview) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", p.serveIndex)
+	mux.HandleFunc("/stream.mjpg", p.serveStream)
+	mux.HandleFunc("/snapshot.png", p.serveSnapshot)
+	mux.HandleFunc("/status.json", p.serveStatus)
+	return mux
+}
+
+func (p *Preview) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, `<!DOCTYPE html>
+<html><head><title>go-rpi-fftwave</title></head>
+<body style="margin:0;background:#000;display:flex;flex-direction:column;align-items:center;justify-content:center;height:100vh">
+<img src="stream.mjpg" style="max-width:100%;image-rendering:pixelated">
+<pre id="status" style="color:#888;font-size:small"></pre>
+<script>
+function status() {
+  fetch("status.json").then(r => r.ok ? r.json() : null).then(s => {
+    if (s) document.getElementById("status").textContent = Object.entries(s).map(e => e.join(": ")).join("   ");
+  }).catch(() => {});
+}
+status();
+setInterval(status, 1000);
+</script>
+</body></html>
+`)
+}
+
+func (p *Preview) serveStatus(w http.ResponseWriter, r *http.Request) {
+	if p.cfg.Status == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(p.cfg.Status())
+}
+
+func (p *Preview) serveSnapshot(w http.ResponseWriter, r *http.Request) {
+	frame, _, _ := p.latest()
+	if frame == nil {
+		http.Error(w, "no frame rendered yet", http.StatusServiceUnavailable)
+		return
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scaleFrame(frame, p.cfg.Scale)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+}
+
+// serveStream sends every new frame as a part of a multipart response which the browsers show as a video
+// a slow client skips the frames rendered while its previous frame was sent
+func (p *Preview) serveStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming isn't supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mjpegBoundary)
+	w.Header().Set("Cache-Control", "no-store")
+
+	var buf bytes.Buffer
+	var scaled *image.RGBA
+	for {
+		frame, updated, closed := p.latest()
+		if closed {
+			return
+		}
+		if frame != nil {
+			buf.Reset()
+			scaled = scaleFrameInto(scaled, frame, p.cfg.Scale)
+			if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: p.cfg.Quality}); err != nil {
+				return
+			}
+			fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", mjpegBoundary, buf.Len())
+			buf.WriteString("\r\n")
+			if _, err := w.Write(buf.Bytes()); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+
+		select {
+		case <-updated:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package output
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPreview(t *testing.T) {
+	p := NewPreview(PreviewConfig{Scale: 2, Status: func() interface{} { return map[string]bool{"dmxPresent": true} }})
+	srv := httptest.NewServer(p.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/status.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status map[string]bool
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	resp.Body.Close()
+	if err != nil || !status["dmxPresent"] {
+		t.Errorf("status mismatch. Want: %v, Have: %v %v\n", map[string]bool{"dmxPresent": true}, status, err)
+	}
+
+	resp, err = http.Get(srv.URL + "/snapshot.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("snapshot before the first frame mismatch. Want: %v, Have: %v\n", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+	frame := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	frame.SetRGBA(3, 1, color.RGBA{200, 100, 50, 255})
+	p.Send(frame)
+
+	resp, err = http.Get(srv.URL + "/snapshot.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := image.Rect(0, 0, 8, 4); img.Bounds() != want {
+		t.Errorf("snapshot size mismatch. Want: %v, Have: %v\n", want, img.Bounds())
+	}
+	if have := color.RGBAModel.Convert(img.At(7, 3)); have != (color.RGBA{200, 100, 50, 255}) {
+		t.Errorf("snapshot pixel mismatch. Want: %v, Have: %v\n", color.RGBA{200, 100, 50, 255}, have)
+	}
+
+	resp, err = http.Get(srv.URL + "/stream.mjpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+	for i := 0; i < 2; i++ {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := jpeg.Decode(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := image.Rect(0, 0, 8, 4); img.Bounds() != want {
+			t.Errorf("stream frame size mismatch. Want: %v, Have: %v\n", want, img.Bounds())
+		}
+		// The second part comes after the next frame
+		p.Send(frame)
+	}
+
+	// Closing the preview ends the stream
+	p.Close()
+	if _, err := mr.NextPart(); err == nil {
+		t.Errorf("stream end mismatch. Want: error, Have: %v\n", err)
+	}
+}
@@ -0,0 +1,198 @@
+package output
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// The ring is a file in the shared memory with a header followed by the frame slots, all values are little endian
+//
+//	 0 magic "FFTWRING"
+//	 8 version uint32
+//	12 width, height, slot count and slot size uint32
+//	32 sequence uint64, number of the last finished frame, zero before the first one
+//	64 slots, each one is a sequence uint64, a unix time in nanoseconds int64 and the RGBA pixels
+//
+// The frame number n is written to the slot (n-1) % slots, the slot sequence is zero while the slot is written
+const (
+	ringMagic      = "FFTWRING"
+	ringVersion    = 1
+	ringHeaderLen  = 64
+	ringSlotHeader = 16
+	ringSequence   = 32
+)
+
+// RingSink publishes the frames to a shared memory ring which other processes can read
+type RingSink struct {
+	path     string
+	f        *os.File
+	mem      []byte
+	slots    int
+	slotSize int
+	scale    int
+	sequence uint64
+	scaled   *image.RGBA
+}
+
+// ringPath returns the path of the ring, plain names are placed in /dev/shm
+func ringPath(name string) string {
+	if filepath.Base(name) == name {
+		return filepath.Join("/dev/shm", name)
+	}
+	return name
+}
+
+// NewRing creates the ring file for frames with the given bounds scaled up by the scale
+func NewRing(name string, slots, scale int, bounds image.Rectangle) (*RingSink, error) {
+	if slots < 2 {
+		return nil, fmt.Errorf("ring: at least 2 slots are needed, got %d", slots)
+	}
+	if scale < 1 {
+		scale = 1
+	}
+	width, height := bounds.Dx()*scale, bounds.Dy()*scale
+	// The slots are kept 8 byte aligned for the atomic sequence numbers
+	slotSize := (ringSlotHeader + 4*width*height + 7) &^ 7
+	size := ringHeaderLen + slots*slotSize
+
+	path := ringPath(name)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(int64(size)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	mem, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("ring %s: %v", path, err)
+	}
+
+	copy(mem, ringMagic)
+	binary.LittleEndian.PutUint32(mem[8:], ringVersion)
+	binary.LittleEndian.PutUint32(mem[12:], uint32(width))
+	binary.LittleEndian.PutUint32(mem[16:], uint32(height))
+	binary.LittleEndian.PutUint32(mem[20:], uint32(slots))
+	binary.LittleEndian.PutUint32(mem[24:], uint32(slotSize))
+	return &RingSink{path: path, f: f, mem: mem, slots: slots, slotSize: slotSize, scale: scale}, nil
+}
+
+func (s *RingSink) Send(frame *image.RGBA) error {
+	s.scaled = scaleFrameInto(s.scaled, frame, s.scale)
+	s.sequence++
+	slot := s.mem[ringHeaderLen+int((s.sequence-1)%uint64(s.slots))*s.slotSize:]
+
+	// The readers skip the slot while its sequence doesn't match the one in the header
+	atomic.StoreUint64(word(slot), 0)
+	binary.LittleEndian.PutUint64(slot[8:], uint64(time.Now().UnixNano()))
+	copy(slot[ringSlotHeader:s.slotSize], s.scaled.Pix)
+	atomic.StoreUint64(word(slot), s.sequence)
+	atomic.StoreUint64(word(s.mem[ringSequence:]), s.sequence)
+	return nil
+}
+
+// Close unmaps and removes the ring, the readers which have it mapped keep the last frames
+func (s *RingSink) Close() error {
+	err := syscall.Munmap(s.mem)
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	if rerr := os.Remove(s.path); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// RingReader reads the latest frames from a ring created by another process
+type RingReader struct {
+	mem           []byte
+	width, height int
+	slots         int
+	slotSize      int
+}
+
+// OpenRing maps an existing ring for reading
+func OpenRing(name string) (*RingReader, error) {
+	f, err := os.Open(ringPath(name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if st.Size() < ringHeaderLen {
+		return nil, errors.New("ring: the file is too small")
+	}
+	mem, err := syscall.Mmap(int(f.Fd()), 0, int(st.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &RingReader{
+		mem:      mem,
+		width:    int(binary.LittleEndian.Uint32(mem[12:])),
+		height:   int(binary.LittleEndian.Uint32(mem[16:])),
+		slots:    int(binary.LittleEndian.Uint32(mem[20:])),
+		slotSize: int(binary.LittleEndian.Uint32(mem[24:])),
+	}
+	if string(mem[:8]) != ringMagic || binary.LittleEndian.Uint32(mem[8:]) != ringVersion {
+		r.Close()
+		return nil, errors.New("ring: unknown file format")
+	}
+	if r.slots < 1 || ringHeaderLen+r.slots*r.slotSize > len(mem) || r.slotSize < ringSlotHeader+4*r.width*r.height {
+		r.Close()
+		return nil, errors.New("ring: the header doesn't match the file size")
+	}
+	return r, nil
+}
+
+// Bounds returns the size of the frames in the ring
+func (r *RingReader) Bounds() image.Rectangle {
+	return image.Rect(0, 0, r.width, r.height)
+}
+
+// Read copies the latest frame into dst which has to have the ring bounds
+// it returns the frame sequence number and time, a zero sequence means that no frame was written yet
+func (r *RingReader) Read(dst *image.RGBA) (uint64, time.Time, error) {
+	if dst.Bounds() != r.Bounds() {
+		return 0, time.Time{}, fmt.Errorf("ring: the frame bounds %v don't match the ring %v", dst.Bounds(), r.Bounds())
+	}
+	// The writer can overwrite the slot during the copy, in that case the next latest frame is tried
+	for try := 0; try < 4; try++ {
+		sequence := atomic.LoadUint64(word(r.mem[ringSequence:]))
+		if sequence == 0 {
+			return 0, time.Time{}, nil
+		}
+		slot := r.mem[ringHeaderLen+int((sequence-1)%uint64(r.slots))*r.slotSize:]
+		if atomic.LoadUint64(word(slot)) != sequence {
+			continue
+		}
+		tm := time.Unix(0, int64(binary.LittleEndian.Uint64(slot[8:])))
+		copy(dst.Pix, slot[ringSlotHeader:ringSlotHeader+4*r.width*r.height])
+		if atomic.LoadUint64(word(slot)) == sequence {
+			return sequence, tm, nil
+		}
+	}
+	return 0, time.Time{}, errors.New("ring: the writer is too fast to read a frame")
+}
+
+func (r *RingReader) Close() error {
+	return syscall.Munmap(r.mem)
+}
+
+// word returns the 8 byte aligned value at the start of the slice for the atomic access
+func word(b []byte) *uint64 {
+	return (*uint64)(unsafe.Pointer(&b[0]))
+}
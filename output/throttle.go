@@ -0,0 +1,30 @@
+package output
+
+import (
+	"image"
+)
+
+// throttle passes only some of the frames on so that the sink gets them with a lower frame rate
+type throttle struct {
+	Sink
+	ratio float64
+	acc   float64
+}
+
+// Throttle limits the rate of the frames sent to the sink, the frames are counted so that it also works offline
+// the sink is returned as it is when the rate isn't lower than the rate of the incoming frames
+func Throttle(s Sink, sourceRate, rate int) Sink {
+	if rate <= 0 || rate >= sourceRate {
+		return s
+	}
+	return &throttle{Sink: s, ratio: float64(rate) / float64(sourceRate), acc: 1}
+}
+
+func (t *throttle) Send(frame *image.RGBA) error {
+	t.acc += t.ratio
+	if t.acc < 1 {
+		return nil
+	}
+	t.acc--
+	return t.Sink.Send(frame)
+}
@@ -0,0 +1,164 @@
+package output
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"net"
+)
+
+// Definition of the DDP protocol values
+const (
+	ddpPort       = 4048
+	ddpHeaderLen  = 10
+	ddpMaxData    = 1440 // 480 RGB pixels, fits in a single ethernet frame
+	ddpVersion1   = 0x40
+	ddpFlagPush   = 0x01
+	ddpTypeRGB8   = 0x0b // RGB with 8 bits per channel
+	ddpIDDisplay  = 0x01
+	wledPort      = 21324
+	wledDRGB      = 2
+	wledDNRGB     = 4
+	wledDRGBMax   = 490
+	wledDNRGBMax  = 489
+	wledNoTimeout = 255
+)
+
+// udpTarget sends the packets from an unconnected socket so that a target which is down doesn't cause errors
+type udpTarget struct {
+	conn net.PacketConn
+	addr *net.UDPAddr
+}
+
+// newUDPTarget resolves the address, the default port is used when the address doesn't have one
+func newUDPTarget(address string, port int) (udpTarget, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, fmt.Sprint(port))
+	}
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return udpTarget{}, err
+	}
+	conn, err := net.ListenPacket("udp", ":0")
+	if err != nil {
+		return udpTarget{}, err
+	}
+	return udpTarget{conn: conn, addr: addr}, nil
+}
+
+func (t udpTarget) Close() error {
+	return t.conn.Close()
+}
+
+// DDPSink sends the mapped pixels with the Distributed Display Protocol
+type DDPSink struct {
+	udpTarget
+	points   []image.Point
+	start    int
+	sequence byte
+	rgb      []byte
+	packet   []byte
+}
+
+// NewDDP creates a DDP sink sending the pixels selected by the mapping from a canvas with the given bounds
+func NewDDP(address string, m Mapping, bounds image.Rectangle) (*DDPSink, error) {
+	points, err := m.Points(bounds)
+	if err != nil {
+		return nil, err
+	}
+	t, err := newUDPTarget(address, ddpPort)
+	if err != nil {
+		return nil, err
+	}
+	return &DDPSink{udpTarget: t, points: points, start: m.Start, packet: make([]byte, ddpHeaderLen+ddpMaxData)}, nil
+}
+
+// Send splits the pixels into packets, the last one has the push flag set which makes the target display the frame
+func (s *DDPSink) Send(frame *image.RGBA) error {
+	s.rgb = packRGB(s.rgb, frame, s.points, rgbOrder)
+	// The sequence number is in range 1..15, zero means it's not used
+	s.sequence = s.sequence%15 + 1
+
+	for offset := 0; offset < len(s.rgb); offset += ddpMaxData {
+		end := offset + ddpMaxData
+		if end > len(s.rgb) {
+			end = len(s.rgb)
+		}
+
+		p := s.packet[:ddpHeaderLen+end-offset]
+		p[0] = ddpVersion1
+		if end == len(s.rgb) {
+			p[0] |= ddpFlagPush
+		}
+		p[1] = s.sequence
+		p[2] = ddpTypeRGB8
+		p[3] = ddpIDDisplay
+		binary.BigEndian.PutUint32(p[4:], uint32(3*s.start+offset))
+		binary.BigEndian.PutUint16(p[8:], uint16(end-offset))
+		copy(p[ddpHeaderLen:], s.rgb[offset:end])
+
+		if _, err := s.conn.WriteTo(p, s.addr); err != nil {
+			return fmt.Errorf("ddp: %v", err)
+		}
+	}
+	return nil
+}
+
+// WLEDSink sends the mapped pixels with the WLED realtime UDP protocol
+// DRGB is used when all of the pixels fit in a single packet, otherwise the pixels are split into DNRGB packets
+type WLEDSink struct {
+	udpTarget
+	points  []image.Point
+	start   int
+	timeout byte
+	rgb     []byte
+	packet  []byte
+}
+
+// NewWLED creates a WLED sink, the timeout is the number of seconds before WLED goes back to its own effects
+// when the frames stop coming, zero keeps the realtime mode until WLED is restarted
+func NewWLED(address string, m Mapping, bounds image.Rectangle, timeout int) (*WLEDSink, error) {
+	points, err := m.Points(bounds)
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 || timeout > 254 {
+		timeout = wledNoTimeout
+	}
+	t, err := newUDPTarget(address, wledPort)
+	if err != nil {
+		return nil, err
+	}
+	return &WLEDSink{udpTarget: t, points: points, start: m.Start, timeout: byte(timeout), packet: make([]byte, 4+3*wledDRGBMax)}, nil
+}
+
+func (s *WLEDSink) Send(frame *image.RGBA) error {
+	s.rgb = packRGB(s.rgb, frame, s.points, rgbOrder)
+
+	if s.start == 0 && len(s.points) <= wledDRGBMax {
+		p := s.packet[:2+len(s.rgb)]
+		p[0] = wledDRGB
+		p[1] = s.timeout
+		copy(p[2:], s.rgb)
+		if _, err := s.conn.WriteTo(p, s.addr); err != nil {
+			return fmt.Errorf("wled: %v", err)
+		}
+		return nil
+	}
+
+	for first := 0; first < len(s.points); first += wledDNRGBMax {
+		last := first + wledDNRGBMax
+		if last > len(s.points) {
+			last = len(s.points)
+		}
+		p := s.packet[:4+3*(last-first)]
+		p[0] = wledDNRGB
+		p[1] = s.timeout
+		binary.BigEndian.PutUint16(p[2:], uint16(s.start+first))
+		copy(p[4:], s.rgb[3*first:3*last])
+		if _, err := s.conn.WriteTo(p, s.addr); err != nil {
+			return fmt.Errorf("wled: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package output
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"net"
+	"testing"
+	"time"
+)
+
+// listen starts a local UDP listener and returns its address
+func listen(t *testing.T) (*net.UDPConn, string) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, conn.LocalAddr().String()
+}
+
+func receive(t *testing.T, conn *net.UDPConn) []byte {
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf[:n]
+}
+
+// testFrame returns a frame with every pixel colored by its position
+func testFrame(width, height int) *image.RGBA {
+	frame := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			frame.SetRGBA(x, y, color.RGBA{byte(x), byte(y), byte(x + y), 255})
+		}
+	}
+	return frame
+}
+
+func TestMappingPoints(t *testing.T) {
+	bounds := image.Rect(0, 0, 8, 4)
+	for _, tc := range []struct {
+		mapping Mapping
+		want    []image.Point
+	}{
+		{Mapping{X: 1, Y: 1, Width: 3, Height: 2}, []image.Point{{1, 1}, {2, 1}, {3, 1}, {1, 2}, {2, 2}, {3, 2}}},
+		{Mapping{X: 1, Y: 1, Width: 3, Height: 2, Serpentine: true}, []image.Point{{1, 1}, {2, 1}, {3, 1}, {3, 2}, {2, 2}, {1, 2}}},
+		{Mapping{X: 1, Y: 1, Width: 3, Height: 2, Vertical: true}, []image.Point{{1, 1}, {1, 2}, {2, 1}, {2, 2}, {3, 1}, {3, 2}}},
+		{Mapping{Width: 8, Height: 4, Step: 4}, []image.Point{{0, 0}, {4, 0}}},
+		{Mapping{Pixels: []image.Point{{7, 3}, {0, 0}}}, []image.Point{{7, 3}, {0, 0}}},
+	} {
+		have, err := tc.mapping.Points(bounds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(have) != len(tc.want) {
+			t.Errorf("%+v: points mismatch. Want: %v, Have: %v\n", tc.mapping, tc.want, have)
+			continue
+		}
+		for i := range have {
+			if have[i] != tc.want[i] {
+				t.Errorf("%+v: points mismatch. Want: %v, Have: %v\n", tc.mapping, tc.want, have)
+				break
+			}
+		}
+	}
+
+	if _, err := (Mapping{X: 4, Width: 8, Height: 1}).Points(bounds); err == nil {
+		t.Errorf("rectangle outside of the canvas mismatch. Want: error, Have: %v\n", err)
+	}
+}
+
+func TestDDP(t *testing.T) {
+	conn, addr := listen(t)
+	// 600 pixels take two packets
+	s, err := NewDDP(addr, Mapping{Start: 10}, image.Rect(0, 0, 100, 6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Send(testFrame(100, 6)); err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := receive(t, conn), receive(t, conn)
+	if first[0] != ddpVersion1 || second[0] != ddpVersion1|ddpFlagPush {
+		t.Errorf("flags mismatch. Want: %x %x, Have: %x %x\n", ddpVersion1, ddpVersion1|ddpFlagPush, first[0], second[0])
+	}
+	if have := binary.BigEndian.Uint32(first[4:]); have != 30 {
+		t.Errorf("first offset mismatch. Want: %v, Have: %v\n", 30, have)
+	}
+	if have := binary.BigEndian.Uint32(second[4:]); have != 30+ddpMaxData {
+		t.Errorf("second offset mismatch. Want: %v, Have: %v\n", 30+ddpMaxData, have)
+	}
+	if have := int(binary.BigEndian.Uint16(second[8:])); have != 600*3-ddpMaxData || len(second) != ddpHeaderLen+have {
+		t.Errorf("second length mismatch. Want: %v, Have: %v\n", 600*3-ddpMaxData, have)
+	}
+	// Pixel 481 is the second one of the second packet at x 81, y 4
+	if have := second[ddpHeaderLen+3 : ddpHeaderLen+6]; have[0] != 81 || have[1] != 4 || have[2] != 85 {
+		t.Errorf("pixel mismatch. Want: %v, Have: %v\n", []byte{81, 4, 85}, have)
+	}
+}
+
+func TestWLED(t *testing.T) {
+	conn, addr := listen(t)
+	frame := testFrame(10, 2)
+
+	s, err := NewWLED(addr, Mapping{}, frame.Bounds(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Send(frame); err != nil {
+		t.Fatal(err)
+	}
+	p := receive(t, conn)
+	if p[0] != wledDRGB || p[1] != 2 || len(p) != 2+20*3 {
+		t.Errorf("DRGB header mismatch. Want: %v %v %v, Have: %v %v %v\n", wledDRGB, 2, 2+20*3, p[0], p[1], len(p))
+	}
+
+	// A start index needs the DNRGB packets
+	s, err = NewWLED(addr, Mapping{Y: 1, Width: 10, Height: 1, Start: 300}, frame.Bounds(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Send(frame); err != nil {
+		t.Fatal(err)
+	}
+	p = receive(t, conn)
+	if p[0] != wledDNRGB || p[1] != wledNoTimeout || binary.BigEndian.Uint16(p[2:]) != 300 || len(p) != 4+10*3 {
+		t.Errorf("DNRGB header mismatch. Have: %v\n", p[:4])
+	}
+	if have := p[4:7]; have[0] != 0 || have[1] != 1 || have[2] != 1 {
+		t.Errorf("pixel mismatch. Want: %v, Have: %v\n", []byte{0, 1, 1}, have)
+	}
+}
@@ -0,0 +1,183 @@
+package output
+
+import (
+	"fmt"
+	"image"
+	"net"
+	"strings"
+
+	"github.com/TFK1410/go-rpi-fftwave/dmxnet"
+)
+
+// maxPixelsPerUniverse is the number of RGB pixels fitting in the 512 channels of a universe
+const maxPixelsPerUniverse = 170
+
+// UniverseConfig describes how the mapped pixels are split into the DMX universes
+type UniverseConfig struct {
+	// Protocol is either e131 or artnet
+	Protocol string
+	// Universe is the universe of the first pixel, E1.31 universes start at 1 and Art-Net ones at 0
+	Universe          int
+	PixelsPerUniverse int
+	// Order is the channel order of the pixels, like RGB or GRB
+	Order string
+	// Priority of the E1.31 source, the receivers take the highest priority source
+	Priority int
+	// SourceName is the name the E1.31 receivers show for this source
+	SourceName string
+}
+
+// UniverseSink sends the mapped pixels as consecutive DMX universes with E1.31 or Art-Net
+type UniverseSink struct {
+	cfg       UniverseConfig
+	conn      net.PacketConn
+	addrs     []*net.UDPAddr
+	source    dmxnet.E131Source
+	points    []image.Point
+	start     int
+	order     [3]int
+	sequences []byte
+	pixels    []byte
+	rgb       []byte
+	packet    []byte
+}
+
+// NewUniverses creates a sink sending the pixels selected by the mapping to the address
+// without the address E1.31 universes are sent to their multicast groups and Art-Net ones are broadcast
+func NewUniverses(address string, m Mapping, bounds image.Rectangle, cfg UniverseConfig) (*UniverseSink, error) {
+	points, err := m.Points(bounds)
+	if err != nil {
+		return nil, err
+	}
+	order, err := parseOrder(cfg.Order)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PixelsPerUniverse == 0 {
+		cfg.PixelsPerUniverse = maxPixelsPerUniverse
+	}
+	if cfg.PixelsPerUniverse < 1 || cfg.PixelsPerUniverse > maxPixelsPerUniverse {
+		return nil, fmt.Errorf("universes: %d pixels per universe is out of the range 1..%d", cfg.PixelsPerUniverse, maxPixelsPerUniverse)
+	}
+
+	s := &UniverseSink{cfg: cfg, points: points, start: m.Start, order: order}
+	var port int
+	switch cfg.Protocol {
+	case "e131":
+		port = dmxnet.E131Port
+		if s.cfg.Universe == 0 {
+			s.cfg.Universe = 1
+		}
+		if s.cfg.Priority == 0 {
+			s.cfg.Priority = dmxnet.E131DefaultPriority
+		}
+		s.source = dmxnet.E131Source{CID: dmxnet.NewCID(cfg.SourceName), Name: cfg.SourceName, Priority: s.cfg.Priority}
+	case "artnet":
+		port = dmxnet.ArtNetPort
+		if address == "" {
+			address = "255.255.255.255"
+		}
+	default:
+		return nil, fmt.Errorf("universes: unknown protocol %q", cfg.Protocol)
+	}
+
+	// Check that the last universe is valid before anything is sent
+	first, count := s.universes()
+	if _, err := s.appendPacket(nil, 0, first+count-1, nil); err != nil {
+		return nil, err
+	}
+	s.sequences = make([]byte, count)
+
+	if address != "" {
+		t, err := newUDPTarget(address, port)
+		if err != nil {
+			return nil, err
+		}
+		s.conn = t.conn
+		for i := 0; i < count; i++ {
+			s.addrs = append(s.addrs, t.addr)
+		}
+		return s, nil
+	}
+
+	// Every E1.31 universe has its own multicast group
+	for i := 0; i < count; i++ {
+		s.addrs = append(s.addrs, &net.UDPAddr{IP: dmxnet.E131Multicast(first + i), Port: port})
+	}
+	s.conn, err = net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// universes returns the first universe with mapped pixels and the number of universes
+func (s *UniverseSink) universes() (int, int) {
+	ppu := s.cfg.PixelsPerUniverse
+	first := s.start / ppu
+	last := (s.start + len(s.points) - 1) / ppu
+	return s.cfg.Universe + first, last - first + 1
+}
+
+func (s *UniverseSink) appendPacket(buf []byte, sequence byte, universe int, data []byte) ([]byte, error) {
+	if s.cfg.Protocol == "artnet" {
+		return dmxnet.AppendArtDmx(buf, sequence, universe, data)
+	}
+	return s.source.AppendData(buf, sequence, universe, data)
+}
+
+// Send sends every universe in its own packet, the channels before the Start pixel are sent as zeros
+func (s *UniverseSink) Send(frame *image.RGBA) error {
+	s.rgb = append(s.rgb[:0], make([]byte, 3*(s.start%s.cfg.PixelsPerUniverse))...)
+	s.pixels = packRGB(s.pixels, frame, s.points, s.order)
+	s.rgb = append(s.rgb, s.pixels...)
+	first, _ := s.universes()
+
+	channels := 3 * s.cfg.PixelsPerUniverse
+	for i := range s.sequences {
+		// Art-Net uses zero to disable the sequencing, E1.31 receivers only check the order
+		s.sequences[i]++
+		if s.sequences[i] == 0 && s.cfg.Protocol == "artnet" {
+			s.sequences[i] = 1
+		}
+
+		from, to := i*channels, (i+1)*channels
+		if to > len(s.rgb) {
+			to = len(s.rgb)
+		}
+		var err error
+		s.packet, err = s.appendPacket(s.packet[:0], s.sequences[i], first+i, s.rgb[from:to])
+		if err != nil {
+			return err
+		}
+		if _, err := s.conn.WriteTo(s.packet, s.addrs[i]); err != nil {
+			return fmt.Errorf("%s: %v", s.cfg.Protocol, err)
+		}
+	}
+	return nil
+}
+
+func (s *UniverseSink) Close() error {
+	return s.conn.Close()
+}
+
+// parseOrder converts a channel order like GRB into the frame channel of each position, empty is RGB
+func parseOrder(order string) ([3]int, error) {
+	if order == "" {
+		return rgbOrder, nil
+	}
+	var out [3]int
+	var seen [3]bool
+	if len(order) != 3 {
+		return out, fmt.Errorf("channel order %q isn't a permutation of RGB", order)
+	}
+	for i, c := range strings.ToUpper(order) {
+		n := strings.IndexRune("RGB", c)
+		if n < 0 || seen[n] {
+			return out, fmt.Errorf("channel order %q isn't a permutation of RGB", order)
+		}
+		seen[n] = true
+		out[i] = n
+	}
+	return out, nil
+}
@@ -0,0 +1,88 @@
+package output
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestUniversesArtNet(t *testing.T) {
+	conn, addr := listen(t)
+	// 10 pixels with 5 per universe starting at pixel 3 take universes 0 to 2
+	s, err := NewUniverses(addr, Mapping{Width: 10, Height: 1, Start: 3}, image.Rect(0, 0, 12, 1), UniverseConfig{Protocol: "artnet", PixelsPerUniverse: 5, Order: "GRB"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Send(testFrame(12, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The pixels before the start are sent as zeros, the pixel x is {x, 0, x} which is {0, x, x} in GRB
+	for i, want := range []struct {
+		length int
+		data   []byte
+	}{
+		{16, []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 0}},
+		{16, []byte{0, 2, 2, 0, 3, 3, 0, 4, 4, 0, 5, 5, 0, 6, 6, 0}},
+		{10, []byte{0, 7, 7, 0, 8, 8, 0, 9, 9, 0}},
+	} {
+		p := receive(t, conn)
+		if !bytes.HasPrefix(p, []byte("Art-Net\x00\x00\x50\x00\x0e")) {
+			t.Fatalf("header mismatch. Have: %v\n", p[:12])
+		}
+		if p[12] != 1 || int(p[14]) != i {
+			t.Errorf("packet %d sequence and universe mismatch. Want: %v %v, Have: %v %v\n", i, 1, i, p[12], p[14])
+		}
+		// The Art-Net length is always even
+		if have := int(p[16])<<8 | int(p[17]); have != want.length {
+			t.Errorf("packet %d length mismatch. Want: %v, Have: %v\n", i, want.length, have)
+		}
+		if have := p[18:]; !bytes.Equal(have, want.data) {
+			t.Errorf("packet %d data mismatch. Want: %v, Have: %v\n", i, want.data, have)
+		}
+	}
+}
+
+func TestUniversesE131(t *testing.T) {
+	conn, addr := listen(t)
+	s, err := NewUniverses(addr, Mapping{}, image.Rect(0, 0, 4, 1), UniverseConfig{Protocol: "e131", Universe: 7, SourceName: "fftwave"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Send(testFrame(4, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	p := receive(t, conn)
+	if len(p) != 126+12 {
+		t.Fatalf("length mismatch. Want: %v, Have: %v\n", 126+12, len(p))
+	}
+	if !bytes.Equal(p[4:16], []byte("ASC-E1.17\x00\x00\x00")) {
+		t.Errorf("identifier mismatch. Have: %q\n", p[4:16])
+	}
+	if have := string(bytes.TrimRight(p[44:108], "\x00")); have != "fftwave" {
+		t.Errorf("source name mismatch. Want: %v, Have: %v\n", "fftwave", have)
+	}
+	if p[108] != 100 || int(p[113])<<8|int(p[114]) != 7 {
+		t.Errorf("priority and universe mismatch. Want: %v %v, Have: %v %v\n", 100, 7, p[108], int(p[113])<<8|int(p[114]))
+	}
+	if have := int(p[123])<<8 | int(p[124]); have != 13 || p[125] != 0 {
+		t.Errorf("property count mismatch. Want: %v, Have: %v\n", 13, have)
+	}
+	if have := p[126+9:]; !bytes.Equal(have, []byte{3, 0, 3}) {
+		t.Errorf("last pixel mismatch. Want: %v, Have: %v\n", []byte{3, 0, 3}, have)
+	}
+}
+
+func TestParseOrder(t *testing.T) {
+	if have, err := parseOrder("grb"); err != nil || have != [3]int{1, 0, 2} {
+		t.Errorf("GRB order mismatch. Want: %v, Have: %v %v\n", [3]int{1, 0, 2}, have, err)
+	}
+	for _, order := range []string{"RGBW", "RRB", "RGX"} {
+		if _, err := parseOrder(order); err == nil {
+			t.Errorf("%s order mismatch. Want: error, Have: %v\n", order, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/TFK1410/go-rpi-fftwave/dmx"
+	"github.com/TFK1410/go-rpi-fftwave/output"
+)
+
+// newCapture creates the capture sink from the config, wait is set for the offline rendering
+func newCapture(cc captureConfig, wait bool) (*output.Capture, error) {
+	return output.NewCapture(output.CaptureConfig{
+		Format:    cc.Format,
+		Path:      cc.Path,
+		Command:   cc.Command,
+		Duration:  time.Duration(cc.Duration * float64(time.Second)),
+		Scale:     cc.Scale,
+		FrameRate: cc.FrameRate,
+		Wait:      wait,
+	}, cfg.Display.RefreshRate)
+}
+
+// newOutputs creates the additional outputs from the config for a canvas of the given size
+func newOutputs(width, height int) (output.Multi, error) {
+	var sinks output.Multi
+	bounds := image.Rect(0, 0, width, height)
+
+	for _, nc := range cfg.Network {
+		var s output.Sink
+		var err error
+		switch nc.Protocol {
+		case "ddp":
+			s, err = output.NewDDP(nc.Address, nc.Mapping, bounds)
+		case "wled":
+			s, err = output.NewWLED(nc.Address, nc.Mapping, bounds, nc.Timeout)
+		case "e131", "artnet":
+			s, err = output.NewUniverses(nc.Address, nc.Mapping, bounds, output.UniverseConfig{
+				Protocol:          nc.Protocol,
+				Universe:          nc.Universe,
+				PixelsPerUniverse: nc.PixelsPerUniverse,
+				Order:             nc.Order,
+				Priority:          nc.Priority,
+				SourceName:        "go-rpi-fftwave",
+			})
+		default:
+			err = fmt.Errorf("unknown protocol %q", nc.Protocol)
+		}
+		if err != nil {
+			sinks.Close()
+			return nil, fmt.Errorf("network output %s %s: %v", nc.Protocol, nc.Address, err)
+		}
+		sinks = append(sinks, output.Throttle(s, cfg.Display.RefreshRate, nc.FrameRate))
+	}
+
+	if lc := cfg.Local; lc.Framebuffer.Device != "" {
+		s, err := output.NewFramebuffer(lc.Framebuffer.Device, lc.Framebuffer.Scale, bounds)
+		if err != nil {
+			sinks.Close()
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if lc := cfg.Local; lc.SharedMemory.Name != "" {
+		s, err := output.NewRing(lc.SharedMemory.Name, lc.SharedMemory.Slots, lc.SharedMemory.Scale, bounds)
+		if err != nil {
+			sinks.Close()
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+// startPreview starts the HTTP preview server and returns the sink which feeds it
+// the frame rate cap keeps the copies of the frames out of most of the render loop ticks
+func startPreview(pc previewConfig, status func() interface{}) (output.Sink, *http.Server, error) {
+	l, err := net.Listen("tcp", pc.Address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("preview: %v", err)
+	}
+	preview := output.NewPreview(output.PreviewConfig{Scale: pc.Scale, Quality: pc.Quality, Status: status})
+	srv := &http.Server{Handler: preview.Handler()}
+	go func() {
+		if err := srv.Serve(l); err != http.ErrServerClosed {
+			log.Println("Preview server:", err)
+		}
+	}()
+	log.Println("Preview available at", "http://"+l.Addr().String())
+	return output.Throttle(preview, cfg.Display.RefreshRate, pc.FrameRate), srv, nil
+}
+
+// dmxStatus returns the status of the DMX input for the preview, the failsafe mode is given while there's no signal
+func dmxStatus(state *dmx.State, dc dmxConfig) func() interface{} {
+	return func() interface{} {
+		status := struct {
+			Input    string `json:"dmxInput"`
+			Present  bool   `json:"dmxPresent"`
+			Failsafe string `json:"failsafe,omitempty"`
+		}{Input: dc.Input, Present: state.Present()}
+		if !status.Present && dc.Failsafe.Timeout > 0 {
+			status.Failsafe = dc.Failsafe.Mode
+		}
+		return status
+	}
+}
+
+// newPowerLimiter wraps the matrix sink with the current estimation and limiting
+func newPowerLimiter(s output.Sink, pc powerLimitConfig, brightness *int32) *output.PowerLimiter {
+	return output.NewPowerLimiter(s, output.PowerConfig{
+		Limit:          pc.MaxCurrent * 1000,
+		ChannelCurrent: [3]float64{pc.ChannelCurrent.Red, pc.ChannelCurrent.Green, pc.ChannelCurrent.Blue},
+		Gamma:          pc.Gamma,
+		Release:        pc.Release,
+	}, cfg.Display.RefreshRate, func() int { return int(atomic.LoadInt32(brightness)) })
+}
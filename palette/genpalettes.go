@@ -189,8 +189,10 @@ func exportToGo() error {
 	fmt.Fprintln(&buf, `var Palettes = [][]color.RGBA{`)
 
 	// first the default palette
+	var names []string
 	for key, element := range palettes {
 		if key == "default" {
+			names = append(names, key)
 			fmt.Fprintf(&buf, "{ // %s\n", key)
 			for _, c := range element {
 				fmt.Fprintf(&buf, "\tcolor.RGBA{0x%02x, 0x%02x, 0x%02x, 0xff},\n", c.R, c.G, c.B)
@@ -203,6 +205,7 @@ func exportToGo() error {
 	// then the rest
 	for key, element := range palettes {
 		if key != "default" {
+			names = append(names, key)
 			fmt.Fprintf(&buf, "{ // %s\n", key)
 			for _, c := range element {
 				fmt.Fprintf(&buf, "\tcolor.RGBA{0x%02x, 0x%02x, 0x%02x, 0xff},\n", c.R, c.G, c.B)
@@ -213,6 +216,14 @@ func exportToGo() error {
 	fmt.Fprintln(&buf, `}`)
 	fmt.Fprintln(&buf)
 
+	// the names in the same order for the fixture definitions and the mode lists
+	fmt.Fprintln(&buf, `// Names are the names of the Palettes at the same indexes`)
+	fmt.Fprintln(&buf, `var Names = []string{`)
+	for _, name := range names {
+		fmt.Fprintf(&buf, "\t%q,\n", name)
+	}
+	fmt.Fprintln(&buf, `}`)
+
 	data, err := format.Source(buf.Bytes())
 	if err != nil {
 		return err
@@ -16005,3 +16005,69 @@ var Palettes = [][]color.RGBA{
 		color.RGBA{0xbb, 0xa6, 0x63, 0xff},
 	},
 }
+
+// Names are the names of the Palettes at the same indexes
+var Names = []string{
+	"default",
+	"autumnrose",
+	"beautifuldreams",
+	"bluefly",
+	"serendil",
+	"wildwinds",
+	"celticsun",
+	"cloud",
+	"faecat",
+	"patriot",
+	"angelrerepose",
+	"aquamarinemermaid",
+	"titannightfall",
+	"bambooblossom",
+	"fairygarden",
+	"leo",
+	"scoutie",
+	"springbird",
+	"butterflyfairy",
+	"hopegoddess",
+	"mistressnight",
+	"pinkchampagne",
+	"purplefly",
+	"carousel",
+	"hangonfatboy",
+	"praire",
+	"quagga",
+	"trove",
+	"flesher",
+	"rainbow",
+	"catfairy",
+	"healingangel",
+	"shyviolet",
+	"springangel",
+	"autumnasrai",
+	"friendshipfairy",
+	"halloween",
+	"scarletdragon",
+	"snodraegon",
+	"angelrepose",
+	"fireandice",
+	"jeweleddragon",
+	"otis",
+	"pinkfairyrose",
+	"renmaiden",
+	"angelcompassion",
+	"blackhorse",
+	"daisyfae",
+	"impatientfairy",
+	"desertjewel",
+	"tashangel",
+	"flame",
+	"ildwinds",
+	"lailah1",
+	"spellbound",
+	"teabearrose",
+	"butterflytalker",
+	"girlcat",
+	"liahlah2",
+	"parrot",
+	"sunlitwave",
+	"tubepreview",
+}
@@ -0,0 +1,173 @@
+package render
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TFK1410/go-rpi-fftwave/backgroundloops"
+	"github.com/TFK1410/go-rpi-fftwave/dmx"
+	"github.com/TFK1410/go-rpi-fftwave/drawloops"
+)
+
+var update = flag.Bool("update", false, "Update the golden images in testdata")
+
+const (
+	testWidth, testHeight = 128, 64
+	testFrames            = 60
+	testRefreshRate       = 120
+	// The FFT and the sound energy value ranges from the default config
+	testMinVal, testMaxVal   = 110.0, 155.0
+	testMinBand, testMaxBand = 100.0, 200.0
+)
+
+var testConfig = Config{
+	SmoothCurve:  0.75,
+	DotHangTime:  500 * time.Millisecond,
+	DotDropSpeed: 40,
+	HistoryCount: 128,
+}
+
+// testCheckpoints are the frame counts at which the frames are compared to the golden images
+// so the time dependent modes are checked at their start and after they got going
+var testCheckpoints = []int{20, testFrames, 2 * testFrames}
+
+// testStart is the fixed time of the first frame so that the time based colors don't change between the runs
+var testStart = time.Date(2021, 4, 4, 12, 0, 0, 0, time.UTC)
+
+func initModes(t *testing.T) {
+	if err := drawloops.InitWaves(testWidth, testHeight, testMinVal, testMaxVal, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	shared := map[string]interface{}{"saturation": 1.0, "hueRotation": 10}
+	if err := backgroundloops.InitBackgroundLoops(testWidth, testHeight, testMinBand, testMaxBand, shared, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testFFT generates the FFT data of the given frame, a few peaks moving across the spectrum over a falling slope
+func testFFT(frame int, out []float64) {
+	phase := float64(frame) / testFrames
+	for i := range out {
+		x := float64(i) / float64(len(out))
+		v := testMaxVal - (testMaxVal-testMinVal)*0.8*x
+		for p := 0; p < 3; p++ {
+			center := math.Mod(phase+float64(p)/3, 1)
+			v += 20 * math.Exp(-math.Pow((x-center)*20, 2))
+		}
+		v += 10 * math.Sin(float64(frame)/4+x*30)
+		out[i] = v
+	}
+}
+
+// renderFrames renders the fixed sequence of frames and returns copies of the frames at the checkpoints
+func renderFrames(t *testing.T, wave, background string) []*image.RGBA {
+	w, err := drawloops.GetWaveByName(wave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := backgroundloops.GetBackgroundLoopByName(background)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dmxData dmx.DMXData
+	dmx.ResetDMX(&dmxData)
+
+	r := New(testWidth, testHeight, testConfig)
+	fft := make([]float64, testWidth)
+	var frames []*image.RGBA
+	for i := 0; len(frames) < len(testCheckpoints); i++ {
+		testFFT(i, fft)
+		frame := r.Render(testStart.Add(time.Duration(i)*time.Second/testRefreshRate), fft, w, b, dmxData, nil)
+		if i+1 == testCheckpoints[len(frames)] {
+			// The renderer reuses its frame
+			c := image.NewRGBA(frame.Bounds())
+			copy(c.Pix, frame.Pix)
+			frames = append(frames, c)
+		}
+	}
+	return frames
+}
+
+// checkGoldens compares the frames at the checkpoints to their golden images
+func checkGoldens(t *testing.T, name string, frames []*image.RGBA) {
+	for i, frame := range frames {
+		checkGolden(t, fmt.Sprintf("%s-%03d", name, testCheckpoints[i]), frame)
+	}
+}
+
+// checkGolden compares the frame to the golden image with a small tolerance for the floating point differences between platforms
+func checkGolden(t *testing.T, name string, frame *image.RGBA) {
+	path := filepath.Join("testdata", name+".png")
+	if *update {
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := png.Encode(f, frame); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("%v, run the tests with -update to create the golden images\n", err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != frame.Bounds() {
+		t.Fatalf("%s size mismatch. Want: %v, Have: %v\n", name, img.Bounds(), frame.Bounds())
+	}
+
+	var differ int
+	for y := 0; y < testHeight; y++ {
+		for x := 0; x < testWidth; x++ {
+			r1, g1, b1, a1 := img.At(x, y).RGBA()
+			r2, g2, b2, a2 := frame.At(x, y).RGBA()
+			if channelDiff(r1, r2) > 2 || channelDiff(g1, g2) > 2 || channelDiff(b1, b2) > 2 || channelDiff(a1, a2) > 2 {
+				differ++
+			}
+		}
+	}
+	if limit := testWidth * testHeight / 200; differ > limit {
+		t.Errorf("%s differs from the golden image in %d pixels, at most %d are allowed\n", name, differ, limit)
+	}
+}
+
+func channelDiff(a, b uint32) uint32 {
+	a, b = a>>8, b>>8
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestWavesGolden(t *testing.T) {
+	initModes(t)
+	for _, info := range drawloops.Available() {
+		t.Run(info.Name, func(t *testing.T) {
+			checkGoldens(t, "wave-"+info.Name, renderFrames(t, info.Name, "none"))
+		})
+	}
+}
+
+func TestBackgroundsGolden(t *testing.T) {
+	initModes(t)
+	for _, info := range backgroundloops.Available() {
+		t.Run(info.Name, func(t *testing.T) {
+			checkGoldens(t, "background-"+info.Name, renderFrames(t, "single", info.Name))
+		})
+	}
+}
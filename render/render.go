@@ -0,0 +1,142 @@
+// Package render turns the FFT data into finished frames
+// it doesn't depend on any hardware so it's used both for the matrix and for the offscreen rendering
+package render
+
+import (
+	"image"
+	"image/draw"
+	"time"
+
+	"github.com/TFK1410/go-rpi-fftwave/backgroundloops"
+	"github.com/TFK1410/go-rpi-fftwave/dmx"
+	"github.com/TFK1410/go-rpi-fftwave/drawloops"
+)
+
+// Config holds the settings of the FFT smoothing, the white dots and the sound energy history
+type Config struct {
+	// SmoothCurve is the part of the previous value kept in the smoothed FFT, in range 0..1
+	SmoothCurve float64
+	// DotHangTime is the time the white dots stay at their peak
+	DotHangTime time.Duration
+	// DotDropSpeed is the fall speed of the white dots in FFT values per second
+	DotDropSpeed float64
+	// HistoryCount is the number of frames kept in the sound energy history for the backgrounds
+	HistoryCount int
+}
+
+// Renderer holds the frame buffer and the state carried over between the frames
+type Renderer struct {
+	cfg   Config
+	frame *image.RGBA
+
+	smoothFFT    []float64
+	dotsValue    []float64
+	dotsTimeLeft []time.Duration
+	history      []backgroundloops.SoundEnergyTriBand
+	last         time.Time
+}
+
+// New creates a renderer with a frame buffer of the given size
+// the FFT data passed to Render has to have one value per column
+func New(width, height int, cfg Config) *Renderer {
+	if cfg.HistoryCount < 2 {
+		cfg.HistoryCount = 2
+	}
+	return &Renderer{
+		cfg:          cfg,
+		frame:        image.NewRGBA(image.Rect(0, 0, width, height)),
+		smoothFFT:    make([]float64, width),
+		dotsValue:    make([]float64, width),
+		dotsTimeLeft: make([]time.Duration, width),
+		history:      make([]backgroundloops.SoundEnergyTriBand, cfg.HistoryCount),
+	}
+}
+
+// Frame returns the frame buffer, it's reused by every call to Render
+func (r *Renderer) Frame() *image.RGBA {
+	return r.frame
+}
+
+// Render draws the frame for the time now from the latest FFT data
+// the time doesn't have to follow the wall clock which allows rendering faster than real time
+// the overlay is drawn over the wave and the background when it's not nil
+func (r *Renderer) Render(now time.Time, fft []float64, wave drawloops.Wave, background backgroundloops.BackgroundLoop, dmxData dmx.DMXData, overlay *image.RGBA) *image.RGBA {
+	var elapsed time.Duration
+	if !r.last.IsZero() {
+		elapsed = now.Sub(r.last)
+	}
+	r.last = now
+
+	// Calculate the smoothed FFT values and the sound energy
+	var triBand backgroundloops.SoundEnergyTriBand
+	for i := range r.smoothFFT {
+		r.smoothFFT[i] = r.cfg.SmoothCurve*r.smoothFFT[i] + (1-r.cfg.SmoothCurve)*fft[i]
+		switch 3 * i / len(r.smoothFFT) {
+		case 0:
+			if triBand.Bass < r.smoothFFT[i] {
+				triBand.Bass = r.smoothFFT[i]
+			}
+		case 1:
+			if triBand.Mid < r.smoothFFT[i] {
+				triBand.Mid = r.smoothFFT[i]
+			}
+		case 2:
+			if triBand.Treble < r.smoothFFT[i] {
+				triBand.Treble = r.smoothFFT[i]
+			}
+		}
+	}
+	triBand.Tm = now
+
+	// Add the current sound energy to the history buffer
+	copy(r.history[1:], r.history[0:len(r.history)-2])
+	r.history[0] = triBand
+
+	// Calculate the current state of the white dots
+	r.whiteDots(elapsed)
+
+	// Start from an empty frame, the transparent pixels are the ones left for the background
+	for i := range r.frame.Pix {
+		r.frame.Pix[i] = 0
+	}
+
+	drawloops.SetFrameTime(now)
+	wave.Draw(r.frame, dmxData, r.smoothFFT, r.dotsValue)
+	background.Draw(r.frame, dmxData, r.history)
+
+	if overlay != nil {
+		overlayImage(r.frame, overlay)
+	}
+	return r.frame
+}
+
+// whiteDots calculates the elapsed time for the white dots hang and lowers the values if necessary
+func (r *Renderer) whiteDots(elapsed time.Duration) {
+	for i := range r.dotsValue {
+		if r.dotsValue[i] < r.smoothFFT[i] {
+			r.dotsValue[i] = r.smoothFFT[i]
+			r.dotsTimeLeft[i] = r.cfg.DotHangTime
+		} else {
+			if r.dotsTimeLeft[i] > 0 {
+				r.dotsTimeLeft[i] -= elapsed
+			}
+			if r.dotsTimeLeft[i] <= 0 {
+				r.dotsValue[i] -= elapsed.Seconds() * r.cfg.DotDropSpeed
+			}
+		}
+	}
+}
+
+// overlayImage copies every non transparent pixel of the overlay onto the frame
+func overlayImage(c draw.Image, overlay *image.RGBA) {
+	bounds := overlay.Bounds()
+	sizeX, sizeY := bounds.Dx(), bounds.Dy()
+
+	for x := 0; x < sizeX; x++ {
+		for y := 0; y < sizeY; y++ {
+			if overlay.Pix[overlay.PixOffset(x, y)+3] > 0 {
+				c.Set(x, y, overlay.At(x, y))
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/TFK1410/go-rpi-fftwave/dmx"
+)
+
+var dmxRecord = flag.String("dmx-record", "", "Record the looks of the DMX input to a show file while running")
+var headlessShow = flag.String("headless-dmx", "", "Play a recorded DMX show file along with the input in the headless mode")
+
+// loadShow reads a show file recorded with -dmx-record
+func loadShow(path string) (*dmx.Show, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the show: %v", err)
+	}
+	defer f.Close()
+	return dmx.ReadShow(f)
+}
+
+// showStart returns the next wall clock time of the day given as HH:MM:SS, an empty one starts right away
+func showStart(at string, now time.Time) (time.Time, error) {
+	if at == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation("15:04:05", at, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("show start %q is not HH:MM:SS", at)
+	}
+	start := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location())
+	if start.Before(now) {
+		start = start.AddDate(0, 0, 1)
+	}
+	return start, nil
+}
+
+// showAudio runs the audio player command of the show, every start of the show restarts it
+type showAudio struct {
+	command string
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	done    chan struct{}
+}
+
+func (a *showAudio) start() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.kill()
+	// The command runs in a process group of its own so that the player started by the shell is killed with it
+	a.cmd = exec.Command("sh", "-c", a.command)
+	a.cmd.Stdout = os.Stdout
+	a.cmd.Stderr = os.Stderr
+	a.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := a.cmd.Start(); err != nil {
+		log.Println("Starting the show audio failed:", err)
+		a.cmd = nil
+		return
+	}
+	cmd, done := a.cmd, make(chan struct{})
+	a.done = done
+	go func() {
+		cmd.Wait()
+		close(done)
+	}()
+}
+
+func (a *showAudio) stop() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.kill()
+}
+
+// kill stops the whole process group of the command and waits for the shell to be reaped
+func (a *showAudio) kill() {
+	if a.cmd != nil {
+		syscall.Kill(-a.cmd.Process.Pid, syscall.SIGKILL)
+		<-a.done
+		a.cmd = nil
+	}
+}
+
+// newShowPlayer loads the show of the show input, the audio player is nil without the command
+func newShowPlayer(sc showPlaybackConfig) (*dmx.ShowPlayer, *showAudio, error) {
+	show, err := loadShow(sc.File)
+	if err != nil {
+		return nil, nil, err
+	}
+	start, err := showStart(sc.StartAt, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	var audio *showAudio
+	var onStart func()
+	if sc.Command != "" {
+		audio = &showAudio{command: sc.Command}
+		onStart = audio.start
+	}
+	return dmx.NewShowPlayer(show, sc.Loop, start, onStart), audio, nil
+}